@@ -0,0 +1,5 @@
+module reflective
+
+go 1.23.3
+
+require golang.org/x/sys v0.33.0
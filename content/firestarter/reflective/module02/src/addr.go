@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"reflective/pe"
+)
+
+// runAddr implements "peparser addr": translate one address between RVA, VA
+// and file offset so the result can be checked against PE-bear.
+func runAddr(args []string) {
+	fs := flag.NewFlagSet("addr", flag.ExitOnError)
+	from := fs.String("from", "rva", "how to read <address>: rva, va or offset")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s addr [-from rva|va|offset] <path_to_dll> <address>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	// Accepts 0x-prefixed hex as well as plain decimal
+	value, err := strconv.ParseUint(fs.Arg(1), 0, 64)
+	if err != nil {
+		log.Fatalf("[-] Invalid address '%s': %v\n", fs.Arg(1), err)
+	}
+
+	peFile := loadPE(fs.Arg(0), true)
+
+	var addr pe.Address
+	switch *from {
+	case "rva":
+		if value > 0xFFFFFFFF {
+			log.Fatalf("[-] RVA 0x%X does not fit in 32 bits\n", value)
+		}
+		addr = peFile.TranslateRVA(uint32(value))
+	case "va":
+		addr = peFile.TranslateVA(value)
+	case "offset":
+		if value > 0xFFFFFFFF {
+			log.Fatalf("[-] File offset 0x%X does not fit in 32 bits\n", value)
+		}
+		addr = peFile.TranslateOffset(uint32(value))
+	default:
+		log.Fatalf("[-] Unknown -from value '%s' (want rva, va or offset)\n", *from)
+	}
+
+	fmt.Printf("[+] Translating %s 0x%X (ImageBase 0x%X)\n", *from, value, peFile.ImageBase())
+	if addr.Location == pe.LocationOutside {
+		if addr.HasOffset {
+			fmt.Printf("  File Offset: 0x%X\n", addr.Offset)
+		}
+		fmt.Println("[!] Address is outside the mapped image.")
+		return
+	}
+	fmt.Printf("  RVA:         0x%X\n", addr.RVA)
+	fmt.Printf("  VA:          0x%X\n", addr.VA)
+	if addr.HasOffset {
+		fmt.Printf("  File Offset: 0x%X\n", addr.Offset)
+	} else {
+		fmt.Printf("  File Offset: none\n")
+	}
+	if addr.Section != nil {
+		fmt.Printf("  Section:     %s\n", addr.Section.Name)
+	}
+	fmt.Printf("  Location:    %s\n", addr.Location)
+	switch addr.Location {
+	case pe.LocationHeaders:
+		fmt.Println("[*] Address is in header space (before the first section).")
+	case pe.LocationZeroFill:
+		fmt.Println("[*] Address is past the section's raw data: the loader zero-fills it, nothing backs it on disk.")
+	}
+}
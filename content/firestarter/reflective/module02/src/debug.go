@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"reflective/pe"
+)
+
+// printDebugDirectory lists the IMAGE_DEBUG_DIRECTORY entries. The CodeView
+// entry is the interesting one: besides the GUID/age a debugger uses to find
+// the matching PDB, it records the path the linker wrote the PDB to, which
+// routinely gives away the build machine's user name and project layout.
+func printDebugDirectory(peFile *pe.File) {
+	entries, err := peFile.DebugDirectory()
+	if err != nil {
+		log.Printf("[!] Warning: Failed to fully parse debug directory: %v\n", err)
+	}
+	if len(entries) == 0 {
+		fmt.Printf("--- Debug Directory (none) ---\n")
+		return
+	}
+	fmt.Printf("--- Debug Directory (%d entries) ---\n", len(entries))
+	repro := peFile.IsReproducible()
+	for _, e := range entries {
+		fmt.Printf("  %s: Size 0x%X, RVA 0x%X, File Offset 0x%X", pe.DebugTypeToString(e.Type), e.SizeOfData, e.AddressOfRawData, e.PointerToRawData)
+		if repro {
+			fmt.Printf(", TimeDateStamp 0x%08X (hash)\n", e.TimeDateStamp)
+		} else {
+			fmt.Printf(", TimeDateStamp 0x%08X (%s)\n", e.TimeDateStamp, time.Unix(int64(e.TimeDateStamp), 0).UTC().Format(time.DateTime))
+		}
+		switch {
+		case e.CodeView != nil:
+			cv := e.CodeView
+			if cv.Signature == "RSDS" {
+				fmt.Printf("    %s GUID %s Age %d\n", cv.Signature, cv.GUIDString(), cv.Age)
+			} else {
+				fmt.Printf("    %s Age %d\n", cv.Signature, cv.Age)
+			}
+			if cv.PDBPath != "" {
+				fmt.Printf("    PDB: %s\n", cv.PDBPath)
+			}
+			if pdbPathIsAbsolute(cv.PDBPath) {
+				fmt.Printf("    [!] Absolute PDB path leaks the build machine's directory layout\n")
+			}
+		case e.POGO != nil:
+			fmt.Printf("    Signature: %s (%d entries)\n", e.POGO.Signature, len(e.POGO.Entries))
+			for _, p := range e.POGO.Entries {
+				fmt.Printf("    RVA 0x%08X Size 0x%-6X %s\n", p.RVA, p.Size, p.Name)
+			}
+		case e.VCFeature != nil:
+			vc := e.VCFeature
+			fmt.Printf("    Pre-VC++ 11.00: %d, C/C++: %d, /GS: %d, /sdl: %d, guardN: %d\n", vc.PreVC11, vc.CCpp, vc.GS, vc.SDL, vc.GuardN)
+		case e.Type == pe.IMAGE_DEBUG_TYPE_REPRO:
+			fmt.Printf("    Hash: %X\n", e.ReproHash)
+		case e.Type == pe.IMAGE_DEBUG_TYPE_EX_DLLCHARACTERISTICS:
+			fmt.Printf("    0x%X %s\n", e.ExDllCharacteristics, flagList(pe.ExDllCharacteristicsToStrings(e.ExDllCharacteristics)))
+		}
+	}
+	if repro {
+		fmt.Printf("  [!] Reproducible build (/Brepro): TimeDateStamp fields are a content hash, not a date\n")
+	}
+}
+
+// pdbPathIsAbsolute reports whether a PDB path names a drive, a UNC share or
+// a rooted Unix path rather than just a file name.
+func pdbPathIsAbsolute(path string) bool {
+	if len(path) >= 3 && path[1] == ':' && (path[2] == '\\' || path[2] == '/') {
+		return true
+	}
+	return strings.HasPrefix(path, `\\`) || strings.HasPrefix(path, "/")
+}
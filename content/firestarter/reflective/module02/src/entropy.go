@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"reflective/pe"
+)
+
+// runEntropy implements "peparser entropy": Shannon entropy for the whole
+// file, each section and the overlay, packer heuristics, and a sliding-window
+// profile. It also accepts files that don't parse as PE, such as the XOR
+// output of the Module 6 obfuscator, so the two can be compared.
+func runEntropy(args []string) {
+	fs := flag.NewFlagSet("entropy", flag.ExitOnError)
+	window := fs.Int("window", 256, "sliding window size in `bytes`")
+	step := fs.Int("step", 128, "distance between window starts in `bytes`, at most the window size")
+	csvOut := fs.String("csv", "", "write the sliding-window profile as CSV to this `file`")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s entropy [-window n] [-step n] [-csv <out.csv>] <file>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	// A step past the window would leave bytes between samples unmeasured.
+	if fs.NArg() != 1 || *window <= 0 || *step <= 0 || *step > *window {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	path := fs.Arg(0)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("[-] Failed to read file '%s': %v\n", path, err)
+	}
+	fmt.Printf("[+] File: %s (%d bytes)\n", path, len(data))
+	fmt.Printf("  Whole file: %.4f bits/byte\n", pe.Entropy(data))
+
+	peFile, err := pe.Parse(data)
+	if err != nil {
+		// Expected for encrypted payloads: the headers are scrambled too.
+		fmt.Printf("[!] Not a parseable PE (%v); only whole-file figures are available.\n", err)
+		peFile = nil
+	} else {
+		printSectionEntropy(peFile)
+		printPackerIndicators(peFile)
+	}
+
+	profile := pe.EntropyProfile(data, *window, *step)
+	printProfileSummary(profile, *window, *step)
+	if *csvOut != "" {
+		if err := writeProfileCSV(*csvOut, profile, peFile); err != nil {
+			log.Fatalf("[-] Failed to write CSV to '%s': %v\n", *csvOut, err)
+		}
+		fmt.Printf("[+] Wrote %d windows to %s\n", len(profile), *csvOut)
+	}
+}
+
+// printSectionEntropy lists the entropy of each section's raw data and of
+// the overlay.
+func printSectionEntropy(peFile *pe.File) {
+	fmt.Printf("--- Section Entropy ---\n")
+	for _, s := range peFile.Sections {
+		if s.SizeOfRawData == 0 {
+			fmt.Printf("  %-8s (no raw data, 0x%X bytes virtual)\n", s.Name, s.VirtualSize)
+			continue
+		}
+		e := pe.Entropy(peFile.SectionData(s))
+		marker := ""
+		if e > pe.HighEntropyThreshold {
+			marker = " [HIGH]"
+		}
+		fmt.Printf("  %-8s 0x%-8X %.4f bits/byte%s\n", s.Name, s.SizeOfRawData, e, marker)
+	}
+	if o := peFile.Overlay(); o != nil {
+		fmt.Printf("  %-8s 0x%-8X %.4f bits/byte\n", "overlay", o.Size, o.Entropy)
+	}
+}
+
+// printPackerIndicators prints the packer heuristics, one [!] line each.
+func printPackerIndicators(peFile *pe.File) {
+	found := peFile.PackerIndicators()
+	if len(found) == 0 {
+		fmt.Printf("--- Packer Indicators (none) ---\n")
+		return
+	}
+	fmt.Printf("--- Packer Indicators (%d) ---\n", len(found))
+	for _, p := range found {
+		fmt.Printf("  [!] '%s': %s\n", p.Section.Name, p.Reason)
+	}
+}
+
+// printProfileSummary condenses the sliding-window profile to its range
+// and how much of the file is above the high-entropy threshold.
+func printProfileSummary(profile []pe.EntropyWindow, window, step int) {
+	if len(profile) == 0 {
+		fmt.Printf("--- Entropy Profile (none) ---\n")
+		return
+	}
+	fmt.Printf("--- Entropy Profile (%d windows of %d bytes, step %d) ---\n", len(profile), window, step)
+	lo, hi, high := profile[0], profile[0], 0
+	for _, w := range profile {
+		if w.Entropy < lo.Entropy {
+			lo = w
+		}
+		if w.Entropy > hi.Entropy {
+			hi = w
+		}
+		if w.Entropy > pe.HighEntropyThreshold {
+			high++
+		}
+	}
+	fmt.Printf("  Min: %.4f at offset 0x%X\n", lo.Entropy, lo.Offset)
+	fmt.Printf("  Max: %.4f at offset 0x%X\n", hi.Entropy, hi.Offset)
+	fmt.Printf("  Above %.1f: %d of %d windows\n", pe.HighEntropyThreshold, high, len(profile))
+}
+
+// writeProfileCSV writes one row per window. The section column names the
+// section holding the window's first byte when the file parsed as PE.
+func writeProfileCSV(path string, profile []pe.EntropyWindow, peFile *pe.File) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	w := csv.NewWriter(out)
+	w.Write([]string{"offset", "size", "entropy", "section"})
+	for _, win := range profile {
+		section := ""
+		if peFile != nil {
+			a := peFile.TranslateOffset(win.Offset)
+			switch {
+			case a.Section != nil:
+				section = a.Section.Name
+			case a.Location == pe.LocationHeaders:
+				section = "headers"
+			case a.Offset >= peFile.OverlayOffset():
+				section = "overlay"
+			}
+		}
+		w.Write([]string{strconv.FormatUint(uint64(win.Offset), 10), strconv.FormatUint(uint64(win.Size), 10),
+			strconv.FormatFloat(win.Entropy, 'f', 4, 64), section})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"reflective/pe"
+)
+
+// printExceptionTable lists the x64 RUNTIME_FUNCTION entries with their
+// unwind information. Every non-leaf function has one, so this doubles as
+// the list of function boundaries, and the unwind codes spell out each
+// prolog: which registers are saved and how much stack is allocated.
+func printExceptionTable(peFile *pe.File) {
+	funcs, err := peFile.ExceptionTable()
+	if err != nil {
+		log.Printf("[!] Warning: Failed to fully parse exception directory: %v\n", err)
+	}
+	if len(funcs) == 0 {
+		fmt.Printf("--- Exception Directory (none) ---\n")
+		return
+	}
+	fmt.Printf("--- Exception Directory (%d functions) ---\n", len(funcs))
+	for _, rf := range funcs {
+		u := rf.Unwind
+		fmt.Printf("  0x%08X-0x%08X (0x%X bytes) UNWIND_INFO 0x%X v%d prolog 0x%X", rf.BeginAddress, rf.EndAddress, rf.Size(), u.RVA, u.Version, u.SizeOfProlog)
+		if u.Flags != 0 {
+			fmt.Printf(" %s", flagList(pe.UnwindFlagsToStrings(u.Flags)))
+		}
+		if reg := u.FrameRegisterName(); reg != "" {
+			fmt.Printf(" frame %s+0x%X", reg, uint32(u.FrameOffset)*16)
+		}
+		fmt.Println()
+		if len(u.Codes) != 0 {
+			fmt.Printf("      %s\n", unwindCodes(u))
+		}
+		if u.HandlerRVA != 0 {
+			fmt.Printf("      Handler: RVA 0x%X\n", u.HandlerRVA)
+		}
+		if u.Chained != nil {
+			fmt.Printf("      Chained to: 0x%08X-0x%08X\n", u.Chained.BeginAddress, u.Chained.EndAddress)
+		}
+	}
+	for _, a := range peFile.CheckRuntimeFunctions(funcs) {
+		fmt.Printf("  [!] Function %d (0x%X-0x%X) %s\n", a.Index, a.Function.BeginAddress, a.Function.EndAddress, a.Reason)
+	}
+}
+
+// unwindCodes joins the unwind codes of u in prolog order (the table lists
+// them last instruction first).
+func unwindCodes(u *pe.UnwindInfo) string {
+	codes := make([]string, len(u.Codes))
+	for i, c := range u.Codes {
+		codes[len(codes)-1-i] = c.String()
+	}
+	return strings.Join(codes, "; ")
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"reflective/pe"
+)
+
+// mitigation is one exploit mitigation the image can opt into, either
+// through DllCharacteristics or through its load config directory.
+type mitigation struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Detail  string `json:"detail"`
+}
+
+// mitigations summarises which mitigations the image opted into. The
+// optional header bits only ask the loader for something; /GS, SafeSEH and
+// CFG also need the load config data the compiler emitted to back them.
+func mitigations(peFile *pe.File, lc *pe.LoadConfig) []mitigation {
+	dllChars := peFile.DllCharacteristics()
+	has := func(flag uint16) bool { return dllChars&flag != 0 }
+	var guardFlags uint32
+	if lc != nil {
+		guardFlags = lc.GuardFlags
+	}
+
+	list := []mitigation{
+		{"ASLR", has(pe.IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE), "DYNAMIC_BASE"},
+		{"DEP", has(pe.IMAGE_DLLCHARACTERISTICS_NX_COMPAT), "NX_COMPAT"},
+	}
+	if peFile.Is64() {
+		list = append(list, mitigation{"High Entropy ASLR", has(pe.IMAGE_DLLCHARACTERISTICS_HIGH_ENTROPY_VA), "HIGH_ENTROPY_VA"})
+	}
+	list = append(list, mitigation{"/GS", lc != nil && lc.SecurityCookie != 0, "SecurityCookie in load config"})
+	if !peFile.Is64() {
+		// x64 exception handling is table based (.pdata), so SafeSEH only exists on x86.
+		safeSEH := mitigation{"SafeSEH", lc != nil && lc.SEHandlerTable != 0, "SEHandlerTable in load config"}
+		if has(pe.IMAGE_DLLCHARACTERISTICS_NO_SEH) {
+			safeSEH = mitigation{"SafeSEH", true, "NO_SEH: image has no SEH handlers at all"}
+		}
+		list = append(list, safeSEH)
+	}
+	list = append(list,
+		mitigation{"CFG", peFile.GuardCF(), "GUARD_CF and CF_INSTRUMENTED"},
+		mitigation{"CFG export suppression", guardFlags&pe.IMAGE_GUARD_CF_ENABLE_EXPORT_SUPPRESSION != 0, "CF_ENABLE_EXPORT_SUPPRESSION"},
+		mitigation{"CFG longjmp targets", guardFlags&pe.IMAGE_GUARD_CF_LONGJUMP_TABLE_PRESENT != 0, "CF_LONGJUMP_TABLE_PRESENT"},
+		mitigation{"EH continuation (CET)", guardFlags&pe.IMAGE_GUARD_EH_CONTINUATION_TABLE_PRESENT != 0, "EH_CONTINUATION_TABLE_PRESENT"},
+		mitigation{"XFG", guardFlags&pe.IMAGE_GUARD_XFG_ENABLED != 0, "XFG_ENABLED"},
+		mitigation{"Return Flow Guard", guardFlags&pe.IMAGE_GUARD_RF_INSTRUMENTED != 0, "RF_INSTRUMENTED"},
+		mitigation{"Retpoline", guardFlags&pe.IMAGE_GUARD_RETPOLINE_PRESENT != 0, "RETPOLINE_PRESENT"},
+	)
+	return list
+}
+
+// printLoadConfig decodes IMAGE_LOAD_CONFIG_DIRECTORY and the mitigation
+// summary built from it.
+func printLoadConfig(peFile *pe.File) {
+	lc, err := peFile.LoadConfig()
+	if err != nil {
+		log.Printf("[!] Warning: Failed to parse load config directory: %v\n", err)
+	}
+	if lc == nil {
+		fmt.Printf("--- Load Config (none) ---\n")
+	} else {
+		fmt.Printf("--- Load Config ---\n")
+		fmt.Printf("  Size: 0x%X (fields through %s)\n", lc.Size, lc.LastField)
+		if lc.Truncated {
+			fmt.Printf("  [!] Directory runs past the section's raw data; missing fields read as zero\n")
+		}
+		fmt.Printf("  SecurityCookie: 0x%X\n", lc.SecurityCookie)
+		if !peFile.Is64() {
+			fmt.Printf("  SEHandlerTable: 0x%X (%d handlers)\n", lc.SEHandlerTable, lc.SEHandlerCount)
+		}
+		fmt.Printf("  GuardCFCheckFunctionPointer: 0x%X\n", lc.GuardCFCheckFunctionPointer)
+		fmt.Printf("  GuardCFFunctionTable: 0x%X (%d entries, %d bytes each)\n", lc.GuardCFFunctionTable, lc.GuardCFFunctionCount, lc.GuardCFFunctionTableEntrySize())
+		fmt.Printf("  GuardFlags: 0x%X %s\n", lc.GuardFlags, flagList(pe.GuardFlagsToStrings(lc.GuardFlags)))
+		if lc.DynamicValueRelocTableSection != 0 {
+			fmt.Printf("  Dynamic Relocations: section %d, offset 0x%X\n", lc.DynamicValueRelocTableSection, lc.DynamicValueRelocTableOffset)
+		}
+		if _, err := peFile.GuardCFFunctions(lc); err != nil {
+			log.Printf("[!] Warning: Failed to read GuardCFFunctionTable: %v\n", err)
+		}
+		if _, err := peFile.SEHandlers(lc); err != nil {
+			log.Printf("[!] Warning: Failed to read SEHandlerTable: %v\n", err)
+		}
+	}
+
+	fmt.Printf("--- Mitigations ---\n")
+	for _, m := range mitigations(peFile, lc) {
+		mark := "[-]"
+		if m.Enabled {
+			mark = "[+]"
+		}
+		fmt.Printf("  %s %-24s (%s)\n", mark, m.Name, m.Detail)
+	}
+	if warning := peFile.CFGWarning(); warning != "" {
+		fmt.Printf("  [!] %s\n", warning)
+	}
+}
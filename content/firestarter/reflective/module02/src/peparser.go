@@ -1,131 +1,61 @@
-//go:build windows
-// +build windows
-
 package main
 
 import (
-	"bytes"
-	"encoding/binary"
+	"encoding/pem"
+	"flag"
 	"fmt"
 	"log"
 	"os"
-)
-
-// --- PE Structures ---
-
-type IMAGE_DOS_HEADER struct {
-	Magic    uint16     // Magic number (MZ)
-	Cblp     uint16     // Bytes on last page of file
-	Cp       uint16     // Pages in file
-	Crlc     uint16     // Relocations
-	Cparhdr  uint16     // Size of header in paragraphs
-	MinAlloc uint16     // Minimum extra paragraphs needed
-	MaxAlloc uint16     // Maximum extra paragraphs needed
-	Ss       uint16     // Initial (relative) SS value
-	Sp       uint16     // Initial SP value
-	Csum     uint16     // Checksum
-	Ip       uint16     // Initial IP value
-	Cs       uint16     // Initial (relative) CS value
-	Lfarlc   uint16     // File address of relocation table
-	Ovno     uint16     // Overlay number
-	Res      [4]uint16  // Reserved words
-	Oemid    uint16     // OEM identifier (for e_oeminfo)
-	Oeminfo  uint16     // OEM information; e_oemid specific
-	Res2     [10]uint16 // Reserved words
-	Lfanew   int32      // File address of new exe header (PE header offset)
-}
-
-type IMAGE_FILE_HEADER struct {
-	Machine              uint16 // Architecture type
-	NumberOfSections     uint16 // Number of sections
-	TimeDateStamp        uint32 // Time and date stamp
-	PointerToSymbolTable uint32 // Pointer to symbol table
-	NumberOfSymbols      uint32 // Number of symbols
-	SizeOfOptionalHeader uint16 // Size of optional header
-	Characteristics      uint16 // File characteristics
-}
-
-type IMAGE_DATA_DIRECTORY struct {
-	VirtualAddress uint32 // RVA of the directory
-	Size           uint32 // Size of the directory
-}
-
-// Note: This is the 64-bit version
-type IMAGE_OPTIONAL_HEADER64 struct {
-	Magic                       uint16 // Magic number (0x20b for PE32+)
-	MajorLinkerVersion          uint8
-	MinorLinkerVersion          uint8
-	SizeOfCode                  uint32
-	SizeOfInitializedData       uint32
-	SizeOfUninitializedData     uint32
-	AddressOfEntryPoint         uint32 // RVA of the entry point
-	BaseOfCode                  uint32
-	ImageBase                   uint64 // Preferred base address
-	SectionAlignment            uint32
-	FileAlignment               uint32
-	MajorOperatingSystemVersion uint16
-	MinorOperatingSystemVersion uint16
-	MajorImageVersion           uint16
-	MinorImageVersion           uint16
-	MajorSubsystemVersion       uint16
-	MinorSubsystemVersion       uint16
-	Win32VersionValue           uint32
-	SizeOfImage                 uint32 // Total size of the image in memory
-	SizeOfHeaders               uint32 // Size of headers (DOS + PE + Section Headers)
-	CheckSum                    uint32
-	Subsystem                   uint16
-	DllCharacteristics          uint16
-	SizeOfStackReserve          uint64
-	SizeOfStackCommit           uint64
-	SizeOfHeapReserve           uint64
-	SizeOfHeapCommit            uint64
-	LoaderFlags                 uint32
-	NumberOfRvaAndSizes         uint32
-	DataDirectory               [16]IMAGE_DATA_DIRECTORY // Array of data directories
-}
+	"strings"
+	"time"
 
-// Note: This is the 64-bit version
-// We don't define IMAGE_NT_HEADERS64 directly as we read Signature, FileHeader,
-// and OptionalHeader sequentially after seeking.
-
-type IMAGE_SECTION_HEADER struct {
-	Name                 [8]byte // Section name (null-padded)
-	VirtualSize          uint32  // Actual size used in memory
-	VirtualAddress       uint32  // RVA of the section
-	SizeOfRawData        uint32  // Size of section data on disk
-	PointerToRawData     uint32  // File offset of section data
-	PointerToRelocations uint32  // File offset of relocations
-	PointerToLinenumbers uint32  // File offset of line numbers
-	NumberOfRelocations  uint16  // Number of relocations
-	NumberOfLinenumbers  uint16  // Number of line numbers
-	Characteristics      uint32  // Section characteristics (flags like executable, readable, writable)
-}
-
-// --- Constants ---
-const (
-	IMAGE_DOS_SIGNATURE = 0x5A4D     // "MZ"
-	IMAGE_NT_SIGNATURE  = 0x00004550 // "PE\0\0"
+	"reflective/pe"
 )
 
-// Helper function to convert null-padded byte array to string
-func sectionNameToString(nameBytes [8]byte) string {
-	n := bytes.IndexByte(nameBytes[:], 0)
-	if n == -1 {
-		n = 8
-	}
-	return string(nameBytes[:n])
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  %s [--format text|json] [--extract-overlay <out>] [--export-certs <out.pem>] [--fix-checksum <out>] <path_to_dll>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "      Dump headers, sections, overlay, signatures, resources, imports and exports\n")
+	fmt.Fprintf(os.Stderr, "  %s addr [-from rva|va|offset] <path_to_dll> <address>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "      Translate an address between RVA, VA and file offset\n")
+	fmt.Fprintf(os.Stderr, "  %s resource <path_to_dll> [<type/name[/lang]> <out_file>]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "      List resources, or extract one by path\n")
+	fmt.Fprintf(os.Stderr, "  %s entropy [-window n] [-step n] [-csv <out.csv>] <file>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "      Entropy per section, overlay and file, packer heuristics and a sliding-window profile\n")
+	fmt.Fprintf(os.Stderr, "  %s compare <dll_a> <dll_b>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "      TLSH distance between two DLLs, overall and per section\n")
 }
 
 func main() {
-	fmt.Println("[+] Starting PE Header Parser...")
-
 	// Check for command line argument
 	if len(os.Args) < 2 {
-		log.Fatalf("[-] Usage: %s <path_to_dll>\n", os.Args[0])
+		usage()
+		os.Exit(2)
 	}
-	dllPath := os.Args[1]
 
-	fmt.Printf("[+] Reading file: %s\n", dllPath)
+	// Subcommands come first; anything else is the DLL to dump
+	switch os.Args[1] {
+	case "addr":
+		runAddr(os.Args[2:])
+	case "resource":
+		runResource(os.Args[2:])
+	case "entropy":
+		runEntropy(os.Args[2:])
+	case "compare":
+		runCompare(os.Args[2:])
+	case "-h", "-help", "--help":
+		usage()
+	default:
+		runDump(os.Args[1:])
+	}
+}
+
+// loadPE reads and parses the file at dllPath, exiting on failure. Progress
+// is only printed when verbose is set, so JSON output stays clean.
+func loadPE(dllPath string, verbose bool) *pe.File {
+	if verbose {
+		fmt.Printf("[+] Reading file: %s\n", dllPath)
+	}
 
 	// Read the entire DLL file into memory
 	dllBytes, err := os.ReadFile(dllPath)
@@ -133,130 +63,403 @@ func main() {
 		log.Fatalf("[-] Failed to read file '%s': %v\n", dllPath, err)
 	}
 
-	// Create a reader for easier parsing with encoding/binary
-	reader := bytes.NewReader(dllBytes)
-
-	// Parse IMAGE_DOS_HEADER
-	var dosHeader IMAGE_DOS_HEADER
-	err = binary.Read(reader, binary.LittleEndian, &dosHeader)
+	// Parse the DOS header, NT headers and section table
+	peFile, err := pe.Parse(dllBytes)
 	if err != nil {
-		log.Fatalf("[-] Failed to read DOS header: %v\n", err)
+		log.Fatalf("[-] Failed to parse PE file: %v\n", err)
 	}
+	return peFile
+}
 
-	// Validate DOS signature ("MZ")
-	if dosHeader.Magic != IMAGE_DOS_SIGNATURE {
-		log.Fatalf("[-] Invalid DOS signature (Expected 0x%X, Got 0x%X)\n", IMAGE_DOS_SIGNATURE, dosHeader.Magic)
+// runDump prints the full header dump for one DLL, as text or JSON.
+func runDump(args []string) {
+	fs := flag.NewFlagSet("peparser", flag.ExitOnError)
+	format := fs.String("format", "text", "output format: text or json")
+	overlayOut := fs.String("extract-overlay", "", "write any data appended after the last section to this `file`")
+	certsOut := fs.String("export-certs", "", "write the Authenticode certificates as PEM to this `file`")
+	fixedOut := fs.String("fix-checksum", "", "write a copy with the optional header CheckSum corrected to this `file`")
+	fs.Usage = usage
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+		os.Exit(2)
 	}
-	fmt.Printf("[+] DOS Signature: MZ (0x%X)\n", dosHeader.Magic)
-	fmt.Printf("[+] Offset to NT Headers (e_lfanew): 0x%X (%d)\n", dosHeader.Lfanew, dosHeader.Lfanew)
+	dllPath := fs.Arg(0)
 
-	// Seek to the NT Headers offset specified in the DOS header
-	_, err = reader.Seek(int64(dosHeader.Lfanew), 0) // 0 means relative to start of file
-	if err != nil {
-		log.Fatalf("[-] Failed to seek to NT Headers offset (0x%X): %v\n", dosHeader.Lfanew, err)
+	var peFile *pe.File
+	switch *format {
+	case "text":
+		fmt.Println("[+] Starting PE Header Parser...")
+		peFile = loadPE(dllPath, true)
+		printText(dllPath, peFile)
+	case "json":
+		peFile = loadPE(dllPath, false)
+		if err := writeJSONReport(os.Stdout, dllPath, peFile); err != nil {
+			log.Fatalf("[-] Failed to write JSON report: %v\n", err)
+		}
+	default:
+		log.Fatalf("[-] Unknown format '%s' (want text or json)\n", *format)
 	}
 
-	// Read and validate PE signature ("PE\0\0")
-	var peSignature uint32
-	err = binary.Read(reader, binary.LittleEndian, &peSignature)
-	if err != nil {
-		log.Fatalf("[-] Failed to read PE signature: %v\n", err)
+	if *overlayOut != "" {
+		extractOverlay(peFile, *overlayOut)
 	}
-	if peSignature != IMAGE_NT_SIGNATURE {
-		log.Fatalf("[-] Invalid PE signature (Expected 0x%X, Got 0x%X)\n", IMAGE_NT_SIGNATURE, peSignature)
+	if *certsOut != "" {
+		exportCertificates(peFile, *certsOut)
 	}
-	fmt.Printf("[+] PE Signature: PE\\0\\0 (0x%X)\n", peSignature)
+	if *fixedOut != "" {
+		fixChecksum(peFile, *fixedOut)
+	}
+}
 
-	// Read IMAGE_FILE_HEADER
-	var fileHeader IMAGE_FILE_HEADER
-	err = binary.Read(reader, binary.LittleEndian, &fileHeader)
-	if err != nil {
-		log.Fatalf("[-] Failed to read File Header: %v\n", err)
+// extractOverlay writes the overlay to outPath. Status goes to stderr so
+// it can be combined with --format json.
+func extractOverlay(peFile *pe.File, outPath string) {
+	data := peFile.OverlayData()
+	if data == nil {
+		log.Fatalf("[-] No overlay to extract: the file ends with the last section.\n")
+	}
+	if err := os.WriteFile(outPath, data, 0644); err != nil {
+		log.Fatalf("[-] Failed to write overlay to '%s': %v\n", outPath, err)
+	}
+	fmt.Fprintf(os.Stderr, "[+] Extracted %d overlay bytes from offset 0x%X to %s\n", len(data), peFile.OverlayOffset(), outPath)
+}
+
+// fixChecksum writes a copy of the image with a correct CheckSum to outPath.
+// Like extractOverlay, status goes to stderr.
+func fixChecksum(peFile *pe.File, outPath string) {
+	if err := os.WriteFile(outPath, peFile.WithFixedChecksum(), 0644); err != nil {
+		log.Fatalf("[-] Failed to write fixed copy to '%s': %v\n", outPath, err)
 	}
+	fmt.Fprintf(os.Stderr, "[+] Wrote %s with CheckSum 0x%08X (was 0x%08X)\n", outPath, peFile.ComputeChecksum(), peFile.CheckSum())
+}
+
+// printChecksum compares the stored CheckSum with the CheckSumMappedFile
+// value. The loader only enforces it for drivers and a few system DLLs,
+// but a stale non-zero value means the file was modified after linking.
+func printChecksum(peFile *pe.File) {
+	stored, computed := peFile.CheckSum(), peFile.ComputeChecksum()
+	switch {
+	case stored == computed:
+		fmt.Printf("  CheckSum: 0x%08X (valid)\n", stored)
+	case stored == 0:
+		fmt.Printf("  CheckSum: 0x00000000 (not set, computed 0x%08X)\n", computed)
+	default:
+		fmt.Printf("  [!] CheckSum: 0x%08X, computed 0x%08X (MISMATCH, file modified after linking)\n", stored, computed)
+	}
+}
+
+// printText writes the human-readable dump used throughout the lab.
+func printText(dllPath string, peFile *pe.File) {
+	dosHeader := peFile.DosHeader
+	fileHeader := peFile.FileHeader
+
+	fmt.Printf("[+] DOS Signature: MZ (0x%X)\n", dosHeader.Magic)
+	fmt.Printf("[+] Offset to NT Headers (e_lfanew): 0x%X (%d)\n", dosHeader.Lfanew, dosHeader.Lfanew)
+	printRichHeader(peFile)
+	fmt.Printf("[+] PE Signature: PE\\0\\0 (0x%X)\n", pe.IMAGE_NT_SIGNATURE)
 
 	fmt.Printf("--- File Header ---\n")
-	fmt.Printf("  Machine: 0x%X (%s)\n", fileHeader.Machine, machineTypeToString(fileHeader.Machine))
+	fmt.Printf("  Machine: 0x%X (%s)\n", fileHeader.Machine, pe.MachineTypeToString(fileHeader.Machine))
 	fmt.Printf("  NumberOfSections: %d\n", fileHeader.NumberOfSections)
 	fmt.Printf("  SizeOfOptionalHeader: %d bytes\n", fileHeader.SizeOfOptionalHeader)
-	fmt.Printf("  Characteristics: 0x%X\n", fileHeader.Characteristics)
+	fmt.Printf("  Characteristics: 0x%X %s\n", fileHeader.Characteristics, flagList(pe.FileCharacteristicsToStrings(fileHeader.Characteristics)))
 
-	// Read IMAGE_OPTIONAL_HEADER64 (Assuming 64-bit DLL for this lab)
-	if fileHeader.SizeOfOptionalHeader == 0 {
-		log.Fatalf("[-] Optional Header size is zero, cannot proceed.")
+	// The optional header layout is picked by its Magic: PE32 (0x10b) keeps
+	// BaseOfData and uses 32-bit ImageBase/stack fields, PE32+ (0x20b) widens them.
+	if oh := peFile.OptionalHeader32; oh != nil {
+		fmt.Printf("--- Optional Header (32-bit) ---\n")
+		fmt.Printf("  Magic: 0x%X (%s)\n", oh.Magic, pe.MagicTypeToString(oh.Magic))
+		fmt.Printf("  AddressOfEntryPoint (RVA): 0x%X\n", oh.AddressOfEntryPoint)
+		fmt.Printf("  BaseOfCode (RVA): 0x%X\n", oh.BaseOfCode)
+		fmt.Printf("  BaseOfData (RVA): 0x%X\n", oh.BaseOfData)
+		fmt.Printf("  ImageBase: 0x%X\n", oh.ImageBase)
+		fmt.Printf("  SizeOfImage: 0x%X (%d bytes)\n", oh.SizeOfImage, oh.SizeOfImage)
+		fmt.Printf("  SizeOfHeaders: 0x%X (%d bytes)\n", oh.SizeOfHeaders, oh.SizeOfHeaders)
+		printChecksum(peFile)
+		fmt.Printf("  SizeOfStackReserve: 0x%X\n", oh.SizeOfStackReserve)
+		fmt.Printf("  SizeOfStackCommit: 0x%X\n", oh.SizeOfStackCommit)
+		fmt.Printf("  DllCharacteristics: 0x%X %s\n", oh.DllCharacteristics, flagList(pe.DllCharacteristicsToStrings(oh.DllCharacteristics)))
+		fmt.Printf("  NumberOfRvaAndSizes: %d\n", oh.NumberOfRvaAndSizes)
+	} else {
+		oh := peFile.OptionalHeader64
+		fmt.Printf("--- Optional Header (64-bit) ---\n")
+		fmt.Printf("  Magic: 0x%X (%s)\n", oh.Magic, pe.MagicTypeToString(oh.Magic))
+		fmt.Printf("  AddressOfEntryPoint (RVA): 0x%X\n", oh.AddressOfEntryPoint)
+		fmt.Printf("  BaseOfCode (RVA): 0x%X\n", oh.BaseOfCode)
+		fmt.Printf("  ImageBase: 0x%X\n", oh.ImageBase)
+		fmt.Printf("  SizeOfImage: 0x%X (%d bytes)\n", oh.SizeOfImage, oh.SizeOfImage)
+		fmt.Printf("  SizeOfHeaders: 0x%X (%d bytes)\n", oh.SizeOfHeaders, oh.SizeOfHeaders)
+		printChecksum(peFile)
+		fmt.Printf("  SizeOfStackReserve: 0x%X\n", oh.SizeOfStackReserve)
+		fmt.Printf("  SizeOfStackCommit: 0x%X\n", oh.SizeOfStackCommit)
+		fmt.Printf("  DllCharacteristics: 0x%X %s\n", oh.DllCharacteristics, flagList(pe.DllCharacteristicsToStrings(oh.DllCharacteristics)))
+		fmt.Printf("  NumberOfRvaAndSizes: %d\n", oh.NumberOfRvaAndSizes)
 	}
 
-	var optionalHeader IMAGE_OPTIONAL_HEADER64
-	err = binary.Read(reader, binary.LittleEndian, &optionalHeader)
-	if err != nil {
-		// If the optional header read fails, it might be because the size doesn't match IMAGE_OPTIONAL_HEADER64
-		// Check if SizeOfOptionalHeader indicates a different size (e.g., 32-bit)
-		log.Printf("[-] Failed to read Optional Header (tried 64-bit): %v. Expected size %d bytes.\n", err, binary.Size(optionalHeader))
-		// You might want to add logic here to try parsing IMAGE_OPTIONAL_HEADER32 if needed.
-		log.Fatalf("Stopping execution.")
-	}
-
-	// Basic check for 64-bit magic number
-	if optionalHeader.Magic != 0x20b {
-		log.Printf("[!] Warning: Optional Header Magic (0x%X) is not 0x20b (PE32+), parsing may be incorrect if not 64-bit.\n", optionalHeader.Magic)
-		// Consider adding a check for 0x10b (PE32) here.
+	// --- Data Directories ---
+	fmt.Printf("--- Data Directories ---\n")
+	for _, dir := range peFile.DataDirectories() {
+		if dir.VirtualAddress == 0 && dir.Size == 0 {
+			fmt.Printf("  [%2d] %-12s (empty)\n", dir.Index, dir.Name)
+			continue
+		}
+		where := "not in any section"
+		if dir.Section != nil {
+			where = fmt.Sprintf("in '%s'", dir.Section.Name)
+		}
+		addrKind := "RVA"
+		if dir.Index == pe.IMAGE_DIRECTORY_ENTRY_SECURITY {
+			addrKind = "Offset" // The Security directory holds a file offset, not an RVA
+		}
+		fmt.Printf("  [%2d] %-12s %s: 0x%X  Size: 0x%X  (%s)\n", dir.Index, dir.Name, addrKind, dir.VirtualAddress, dir.Size, where)
 	}
 
-	fmt.Printf("--- Optional Header (64-bit) ---\n")
-	fmt.Printf("  Magic: 0x%X (%s)\n", optionalHeader.Magic, magicTypeToString(optionalHeader.Magic))
-	fmt.Printf("  AddressOfEntryPoint (RVA): 0x%X\n", optionalHeader.AddressOfEntryPoint)
-	fmt.Printf("  ImageBase: 0x%X\n", optionalHeader.ImageBase)
-	fmt.Printf("  SizeOfImage: 0x%X (%d bytes)\n", optionalHeader.SizeOfImage, optionalHeader.SizeOfImage)
-	fmt.Printf("  SizeOfHeaders: 0x%X (%d bytes)\n", optionalHeader.SizeOfHeaders, optionalHeader.SizeOfHeaders)
-	fmt.Printf("  NumberOfRvaAndSizes: %d\n", optionalHeader.NumberOfRvaAndSizes)
-
 	// --- Section Headers ---
 	fmt.Printf("--- Section Headers (%d) ---\n", fileHeader.NumberOfSections)
-	// Section headers immediately follow the optional header.
-	// The reader is already positioned correctly after reading the optional header.
-	for i := uint16(0); i < fileHeader.NumberOfSections; i++ {
-		var sectionHeader IMAGE_SECTION_HEADER
-		err = binary.Read(reader, binary.LittleEndian, &sectionHeader)
-		if err != nil {
-			log.Fatalf("[-] Failed to read Section Header %d: %v\n", i, err)
-		}
-
-		sectionName := sectionNameToString(sectionHeader.Name)
-		fmt.Printf("  Section %d: '%s'\n", i, sectionName)
+	for i, sectionHeader := range peFile.Sections {
+		fmt.Printf("  Section %d: '%s'\n", i, sectionHeader.Name)
 		fmt.Printf("    VirtualAddress (RVA): 0x%X\n", sectionHeader.VirtualAddress)
 		fmt.Printf("    SizeOfRawData: 0x%X (%d bytes)\n", sectionHeader.SizeOfRawData, sectionHeader.SizeOfRawData)
 		fmt.Printf("    PointerToRawData: 0x%X (%d)\n", sectionHeader.PointerToRawData, sectionHeader.PointerToRawData)
-		fmt.Printf("    Characteristics: 0x%X\n", sectionHeader.Characteristics)
+		fmt.Printf("    Characteristics: 0x%X %s\n", sectionHeader.Characteristics, flagList(pe.SectionCharacteristicsToStrings(sectionHeader.Characteristics)))
+		fmt.Printf("    Entropy: %.2f bits/byte\n", pe.Entropy(peFile.SectionData(sectionHeader)))
+		hashes := peFile.SectionHashes(sectionHeader)
+		fmt.Printf("    MD5: %s\n", hashes.MD5)
+		fmt.Printf("    SHA256: %s\n", hashes.SHA256)
+		if hashes.TLSH != "" {
+			fmt.Printf("    TLSH: %s\n", hashes.TLSH)
+		}
+	}
+	printPackerIndicators(peFile)
+
+	// --- Overlay ---
+	// Bytes past the last section are never mapped by the loader.
+	if overlay := peFile.Overlay(); overlay == nil {
+		fmt.Printf("--- Overlay (none) ---\n")
+	} else {
+		fmt.Printf("--- Overlay ---\n")
+		fmt.Printf("  Offset: 0x%X  Size: 0x%X (%d bytes)\n", overlay.Offset, overlay.Size, overlay.Size)
+		fmt.Printf("  Entropy: %.2f bits/byte\n", overlay.Entropy)
+		if overlay.Authenticode {
+			fmt.Printf("  [*] Contains the Authenticode certificate table\n")
+			if overlay.AppendedSize != 0 {
+				fmt.Printf("  [!] %d bytes sit outside the certificate table (not covered by the signature)\n", overlay.AppendedSize)
+			}
+		}
+		if overlay.SymbolTable {
+			fmt.Printf("  [*] Contains the COFF symbol table (PointerToSymbolTable 0x%X)\n", fileHeader.PointerToSymbolTable)
+		}
+	}
+
+	printSignatures(peFile)
+	printResources(peFile)
+	printVersionInfo(dllPath, peFile)
+	printManifest(peFile)
+	printDebugDirectory(peFile)
+	printTLSDirectory(peFile)
+	printLoadConfig(peFile)
+	printExceptionTable(peFile)
+
+	// --- Imports ---
+	// Read straight from the file on disk: nothing is loaded or resolved.
+	imports, err := peFile.Imports()
+	if err != nil {
+		log.Printf("[!] Warning: Failed to fully parse import directory: %v\n", err)
+	}
+	fmt.Printf("--- Imports (%d DLLs) ---\n", len(imports))
+	for _, dll := range imports {
+		fmt.Printf("  %s (%d functions)\n", dll.Name, len(dll.Functions))
+		for _, fn := range dll.Functions {
+			fmt.Printf("    [IAT 0x%X] %s\n", fn.ThunkRVA, fn)
+		}
 	}
 
+	// --- Delay-Load Imports ---
+	// Resolved by the image's own __delayLoadHelper2 on first call, not by
+	// the loader, so these DLLs don't show up in a process until then.
+	delayImports, err := peFile.DelayImports()
+	if err != nil {
+		log.Printf("[!] Warning: Failed to fully parse delay-load import directory: %v\n", err)
+	}
+	if len(delayImports) == 0 {
+		fmt.Printf("--- Delay-Load Imports (none) ---\n")
+	} else {
+		fmt.Printf("--- Delay-Load Imports (%d DLLs) ---\n", len(delayImports))
+	}
+	for _, dll := range delayImports {
+		fmt.Printf("  %s (%d functions, module handle at RVA 0x%X)\n", dll.Name, len(dll.Functions), dll.Descriptor.ModuleHandleRVA)
+		if !dll.RVABased {
+			fmt.Printf("    [*] VA-based descriptor (pre-VC7 linker)\n")
+		}
+		for _, fn := range dll.Functions {
+			fmt.Printf("    [IAT 0x%X] %s\n", fn.ThunkRVA, fn)
+		}
+	}
+
+	// --- Exports ---
+	exports, err := peFile.Exports()
+	if err != nil {
+		log.Printf("[!] Warning: Failed to fully parse export directory: %v\n", err)
+	}
+	if exports == nil {
+		fmt.Printf("--- Exports (none) ---\n")
+	} else {
+		fmt.Printf("--- Exports (%d) ---\n", len(exports.Functions))
+		fmt.Printf("  Module Name: %s\n", exports.Name)
+		fmt.Printf("  Ordinal Base: %d\n", exports.Base)
+		for _, fn := range exports.Functions {
+			name := fn.Name
+			if name == "" {
+				name = "(ordinal only)"
+			}
+			if fn.IsForwarder() {
+				fmt.Printf("    @%d %s -> %s (forwarder, RVA 0x%X)\n", fn.Ordinal, name, fn.Forwarder, fn.RVA)
+			} else {
+				fmt.Printf("    @%d %s (RVA 0x%X)\n", fn.Ordinal, name, fn.RVA)
+			}
+		}
+	}
+
+	printFingerprints(peFile)
+
 	fmt.Println("[+] PE Header Parser finished.")
 }
 
-// Helper functions for printing descriptive names
-func machineTypeToString(machine uint16) string {
-	switch machine {
-	case 0x0:
-		return "Unknown"
-	case 0x14c:
-		return "x86 (I386)"
-	case 0x8664:
-		return "x64 (AMD64)"
-	case 0xaa64:
-		return "ARM64"
-	case 0x1c0:
-		return "ARM"
-	// Add other common types if needed
+// printFingerprints prints the hashes threat-intel feeds pivot on. Unlike a
+// file hash they stay the same across rebuilds with the same imports or
+// exports, and TLSH stays close when the code itself changes a little.
+func printFingerprints(peFile *pe.File) {
+	fmt.Printf("--- Fingerprints ---\n")
+	imphash, err := peFile.Imphash()
+	switch {
+	case err != nil:
+		log.Printf("[!] Warning: Failed to compute imphash: %v\n", err)
+	case imphash == "":
+		fmt.Printf("  Imphash: (no imports)\n")
+	default:
+		fmt.Printf("  Imphash: %s\n", imphash)
+	}
+	exphash, err := peFile.ExportHash()
+	switch {
+	case err != nil:
+		log.Printf("[!] Warning: Failed to compute export hash: %v\n", err)
+	case exphash == "":
+		fmt.Printf("  Export hash: (no named exports)\n")
 	default:
-		return "Other"
+		fmt.Printf("  Export hash: %s\n", exphash)
+	}
+	if digest, err := pe.TLSH(peFile.Bytes()); err != nil {
+		fmt.Printf("  TLSH: (%v)\n", err)
+	} else {
+		fmt.Printf("  TLSH: %s\n", digest)
 	}
 }
 
-func magicTypeToString(magic uint16) string {
-	switch magic {
-	case 0x10b:
-		return "PE32 (32-bit)"
-	case 0x20b:
-		return "PE32+ (64-bit)"
-	default:
-		return "Unknown/Invalid"
+// printRichHeader decodes the Rich header hidden between the DOS stub and
+// e_lfanew, which records the MSVC toolchain that built the image.
+func printRichHeader(peFile *pe.File) {
+	rich, err := peFile.RichHeader()
+	if err != nil {
+		log.Printf("[!] Warning: Failed to decode Rich header: %v\n", err)
+		return
 	}
+	if rich == nil {
+		fmt.Printf("--- Rich Header (none, not linked by MSVC) ---\n")
+		return
+	}
+	fmt.Printf("--- Rich Header (%d entries) ---\n", len(rich.Entries))
+	fmt.Printf("  Offset: 0x%X  Size: %d bytes\n", rich.Offset, rich.Size)
+	if rich.ChecksumValid() {
+		fmt.Printf("  Checksum: 0x%08X (valid)\n", rich.Key)
+	} else {
+		fmt.Printf("  [!] Checksum: 0x%08X, computed 0x%08X (MISMATCH, header may be forged)\n", rich.Key, rich.ComputedChecksum)
+	}
+	for _, entry := range rich.Entries {
+		fmt.Printf("    %s\n", entry)
+	}
+}
+
+// printSignatures lists who signed the image, from the PKCS#7 blobs in the
+// Security directory. Nothing is verified: this only reports what the
+// signature claims.
+func printSignatures(peFile *pe.File) {
+	sigs, err := peFile.Signatures()
+	if err != nil {
+		log.Printf("[!] Warning: Failed to parse Authenticode signature: %v\n", err)
+	}
+	if len(sigs) == 0 {
+		fmt.Printf("--- Authenticode (unsigned) ---\n")
+		return
+	}
+	fmt.Printf("--- Authenticode (%d signatures) ---\n", len(sigs))
+	for i, sig := range sigs {
+		fmt.Printf("  Signature %d: digest %s, %d certificates\n", i, sig.DigestAlgorithm, len(sig.Certificates))
+		printDigestVerification(peFile, sig)
+		for _, signer := range sig.Signers {
+			fmt.Printf("    Signer Serial: %X\n", signer.SerialNumber)
+			fmt.Printf("    Issuer: %s\n", signer.Issuer)
+			fmt.Printf("    Digest Algorithm: %s\n", signer.DigestAlgorithm)
+			if cert := signer.Certificate; cert != nil {
+				fmt.Printf("    Subject: %s\n", cert.Subject)
+				fmt.Printf("    Valid: %s to %s\n", cert.NotBefore.Format(time.DateTime), cert.NotAfter.Format(time.DateTime))
+			} else {
+				fmt.Printf("    [!] Signer certificate not included in the signature\n")
+			}
+		}
+		for _, cert := range sig.Certificates {
+			fmt.Printf("    [*] Certificate: %s\n", cert.Subject.CommonName)
+		}
+	}
+}
+
+// printDigestVerification recomputes the Authenticode image hash and
+// compares it with the one in the signature, which shows whether the file
+// was patched after signing.
+func printDigestVerification(peFile *pe.File, sig *pe.Signature) {
+	v, err := peFile.VerifyDigest(sig)
+	if err != nil {
+		log.Printf("[!] Warning: Failed to compute Authenticode digest: %v\n", err)
+		return
+	}
+	fmt.Printf("    Signed Digest:   %x\n", v.SignedDigest)
+	fmt.Printf("    Computed Digest: %x\n", v.ComputedDigest)
+	if v.Match() {
+		fmt.Printf("    [+] Image digest matches: file unchanged since signing\n")
+	} else {
+		fmt.Printf("    [!] Image digest MISMATCH: file was modified after signing\n")
+	}
+	if !v.MessageDigestValid {
+		fmt.Printf("    [!] Signer messageDigest does not cover the signed image digest\n")
+	}
+}
+
+// exportCertificates writes every certificate from every signature to
+// outPath as PEM. Status goes to stderr so it can be combined with --format
+// json.
+func exportCertificates(peFile *pe.File, outPath string) {
+	sigs, err := peFile.Signatures()
+	if err != nil {
+		log.Fatalf("[-] Failed to parse Authenticode signature: %v\n", err)
+	}
+	var out []byte
+	count := 0
+	for _, sig := range sigs {
+		for _, cert := range sig.Certificates {
+			out = append(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+			count++
+		}
+	}
+	if count == 0 {
+		log.Fatalf("[-] No certificates to export: the file is not signed.\n")
+	}
+	if err := os.WriteFile(outPath, out, 0644); err != nil {
+		log.Fatalf("[-] Failed to write certificates to '%s': %v\n", outPath, err)
+	}
+	fmt.Fprintf(os.Stderr, "[+] Exported %d certificates to %s\n", count, outPath)
+}
+
+// flagList formats decoded flag names as "[A | B | C]".
+func flagList(names []string) string {
+	return "[" + strings.Join(names, " | ") + "]"
 }
@@ -0,0 +1,710 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"reflective/pe"
+)
+
+// reportSchemaVersion is bumped whenever a field is renamed, removed or
+// changes meaning. Adding fields does not bump it, so consumers should
+// ignore keys they don't recognise.
+const reportSchemaVersion = 1
+
+// report is the --format json output. Every key is always present (empty
+// lists are [] rather than null) so two reports can be diffed field by field.
+type report struct {
+	SchemaVersion   int                     `json:"schema_version"`
+	File            reportFile              `json:"file"`
+	DOSHeader       reportDOSHeader         `json:"dos_header"`
+	RichHeader      *reportRich             `json:"rich_header"` // null when the image has none
+	NTHeaders       reportNTHeaders         `json:"nt_headers"`
+	Sections        []reportSection         `json:"sections"`
+	Packer          []reportPackerIndicator `json:"packer_indicators"`
+	DataDirectories []reportDirectory       `json:"data_directories"`
+	Overlay         *reportOverlay          `json:"overlay"`    // null when nothing follows the last section
+	Signatures      []reportSignature       `json:"signatures"` // Authenticode, empty when unsigned
+	Resources       []reportResource        `json:"resources"`
+	VersionInfo     *reportVersion          `json:"version_info"` // null without an RT_VERSION resource
+	Manifest        *reportManifest         `json:"manifest"`     // null without an RT_MANIFEST resource
+	Debug           []reportDebug           `json:"debug"`
+	TLS             *reportTLS              `json:"tls"`         // null without a TLS directory
+	LoadConfig      *reportLoadConfig       `json:"load_config"` // null without a load config directory
+	Mitigations     []mitigation            `json:"mitigations"`
+	ExceptionTable  []reportFunction        `json:"exception_table"` // x64 RUNTIME_FUNCTION entries
+	ExceptionIssues []reportAnomaly         `json:"exception_issues"`
+	Imports         []reportImportDLL       `json:"imports"`
+	DelayImports    []reportDelayDLL        `json:"delay_imports"`
+	Exports         *reportExports          `json:"exports"` // null when the DLL exports nothing
+	Fingerprints    reportFingerprints      `json:"fingerprints"`
+	Warnings        []string                `json:"warnings"`
+}
+
+type reportFile struct {
+	Path    string  `json:"path"`
+	Size    int     `json:"size"`
+	Entropy float64 `json:"entropy"`
+}
+
+type reportDOSHeader struct {
+	Magic  uint16 `json:"e_magic"`
+	Lfanew int32  `json:"e_lfanew"`
+}
+
+type reportRich struct {
+	Offset           uint32         `json:"offset"`
+	Size             uint32         `json:"size"`
+	Checksum         uint32         `json:"checksum"`
+	ComputedChecksum uint32         `json:"computed_checksum"`
+	ChecksumValid    bool           `json:"checksum_valid"`
+	Entries          []reportCompID `json:"entries"`
+}
+
+type reportCompID struct {
+	ProductID    uint16 `json:"product_id"`
+	Build        uint16 `json:"build"`
+	Count        uint32 `json:"count"`
+	VisualStudio string `json:"visual_studio"`
+}
+
+type reportNTHeaders struct {
+	Signature      uint32               `json:"signature"`
+	FileHeader     reportFileHeader     `json:"file_header"`
+	OptionalHeader reportOptionalHeader `json:"optional_header"`
+}
+
+type reportFileHeader struct {
+	Machine              uint16   `json:"machine"`
+	MachineName          string   `json:"machine_name"`
+	NumberOfSections     uint16   `json:"number_of_sections"`
+	TimeDateStamp        uint32   `json:"time_date_stamp"`
+	SizeOfOptionalHeader uint16   `json:"size_of_optional_header"`
+	Characteristics      uint16   `json:"characteristics"`
+	CharacteristicsNames []string `json:"characteristics_names"`
+}
+
+// reportOptionalHeader covers both PE32 and PE32+; BaseOfData is null for PE32+.
+type reportOptionalHeader struct {
+	Magic                   uint16   `json:"magic"`
+	Format                  string   `json:"format"`
+	AddressOfEntryPoint     uint32   `json:"address_of_entry_point"`
+	BaseOfCode              uint32   `json:"base_of_code"`
+	BaseOfData              *uint32  `json:"base_of_data"`
+	ImageBase               uint64   `json:"image_base"`
+	SectionAlignment        uint32   `json:"section_alignment"`
+	FileAlignment           uint32   `json:"file_alignment"`
+	SizeOfImage             uint32   `json:"size_of_image"`
+	SizeOfHeaders           uint32   `json:"size_of_headers"`
+	CheckSum                uint32   `json:"checksum"`
+	ComputedCheckSum        uint32   `json:"computed_checksum"`
+	CheckSumValid           bool     `json:"checksum_valid"`
+	Subsystem               uint16   `json:"subsystem"`
+	DllCharacteristics      uint16   `json:"dll_characteristics"`
+	DllCharacteristicsNames []string `json:"dll_characteristics_names"`
+	SizeOfStackReserve      uint64   `json:"size_of_stack_reserve"`
+	SizeOfStackCommit       uint64   `json:"size_of_stack_commit"`
+	SizeOfHeapReserve       uint64   `json:"size_of_heap_reserve"`
+	SizeOfHeapCommit        uint64   `json:"size_of_heap_commit"`
+	NumberOfRvaAndSizes     uint32   `json:"number_of_rva_and_sizes"`
+}
+
+type reportSection struct {
+	Name                 string   `json:"name"`
+	VirtualAddress       uint32   `json:"virtual_address"`
+	VirtualSize          uint32   `json:"virtual_size"`
+	PointerToRawData     uint32   `json:"pointer_to_raw_data"`
+	SizeOfRawData        uint32   `json:"size_of_raw_data"`
+	Characteristics      uint32   `json:"characteristics"`
+	CharacteristicsNames []string `json:"characteristics_names"`
+	Entropy              float64  `json:"entropy"`
+	MD5                  string   `json:"md5"`
+	SHA256               string   `json:"sha256"`
+	TLSH                 string   `json:"tlsh"`
+}
+
+// reportFingerprints holds the import and export hashes and the whole-file
+// TLSH; each is "" when the image has nothing to hash.
+type reportFingerprints struct {
+	Imphash string `json:"imphash"`
+	Exphash string `json:"exphash"`
+	TLSH    string `json:"tlsh"`
+}
+
+type reportPackerIndicator struct {
+	Section string `json:"section"`
+	Reason  string `json:"reason"`
+}
+
+type reportDirectory struct {
+	Index          int    `json:"index"`
+	Name           string `json:"name"`
+	VirtualAddress uint32 `json:"virtual_address"` // File offset for the Security directory
+	Size           uint32 `json:"size"`
+	Section        string `json:"section"` // "" when not inside a section
+}
+
+type reportOverlay struct {
+	Offset       uint32  `json:"offset"`
+	Size         uint32  `json:"size"`
+	Entropy      float64 `json:"entropy"`
+	Authenticode bool    `json:"authenticode"`
+	AppendedSize uint32  `json:"appended_size"` // Overlay bytes outside the certificate table
+	SymbolTable  bool    `json:"symbol_table"`
+}
+
+type reportSignature struct {
+	DigestAlgorithm    string              `json:"digest_algorithm"`
+	SignedDigest       string              `json:"signed_digest"`   // Hex, from SpcIndirectDataContent
+	ComputedDigest     string              `json:"computed_digest"` // Hex, "" if it couldn't be computed
+	DigestMatch        bool                `json:"digest_match"`
+	MessageDigestValid bool                `json:"message_digest_valid"`
+	Signers            []reportSigner      `json:"signers"`
+	Certificates       []reportCertificate `json:"certificates"`
+}
+
+type reportSigner struct {
+	Issuer          string `json:"issuer"`
+	SerialNumber    string `json:"serial_number"` // Hex
+	DigestAlgorithm string `json:"digest_algorithm"`
+	Subject         string `json:"subject"` // "" when the signer certificate is missing
+}
+
+type reportCertificate struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial_number"` // Hex
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+}
+
+type reportResource struct {
+	Path     string  `json:"path"` // "type/name/lang", as accepted by "peparser resource"
+	Type     string  `json:"type"`
+	Name     string  `json:"name"`
+	Language string  `json:"language"`
+	RVA      uint32  `json:"rva"`
+	Size     uint32  `json:"size"`
+	CodePage uint32  `json:"codepage"`
+	Offset   *uint32 `json:"offset"` // null when the data has no file backing
+}
+
+type reportVersion struct {
+	FileVersion    string                `json:"file_version"`    // From VS_FIXEDFILEINFO, "" if absent
+	ProductVersion string                `json:"product_version"` // From VS_FIXEDFILEINFO, "" if absent
+	FileFlags      uint32                `json:"file_flags"`
+	FileType       uint32                `json:"file_type"`
+	Strings        []reportVersionString `json:"strings"`
+	// OriginalFilenameMatches compares OriginalFilename with the file name
+	// on disk; null when there is no OriginalFilename.
+	OriginalFilenameMatches *bool `json:"original_filename_matches"`
+}
+
+type reportVersionString struct {
+	Table string `json:"table"` // Language and codepage, e.g. "040904B0"
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type reportManifest struct {
+	Size                    int                `json:"size"`
+	RequestedExecutionLevel string             `json:"requested_execution_level"`
+	UIAccess                string             `json:"ui_access"`
+	Dependencies            []reportDependency `json:"dependencies"`
+}
+
+type reportDependency struct {
+	Type                  string `json:"type"`
+	Name                  string `json:"name"`
+	Version               string `json:"version"`
+	ProcessorArchitecture string `json:"processor_architecture"`
+	PublicKeyToken        string `json:"public_key_token"`
+	Language              string `json:"language"`
+}
+
+type reportDebug struct {
+	Type             string `json:"type"`
+	TimeDateStamp    uint32 `json:"time_date_stamp"`
+	SizeOfData       uint32 `json:"size_of_data"`
+	AddressOfRawData uint32 `json:"address_of_raw_data"`
+	PointerToRawData uint32 `json:"pointer_to_raw_data"`
+	// At most one of the following is set, matching Type.
+	CodeView             *reportCodeView  `json:"codeview"`
+	POGO                 *reportPOGO      `json:"pogo"`
+	VCFeature            *reportVCFeature `json:"vc_feature"`
+	ReproHash            *string          `json:"repro_hash"`
+	ExDllCharacteristics []string         `json:"ex_dll_characteristics"`
+}
+
+type reportCodeView struct {
+	Signature string `json:"signature"`
+	GUID      string `json:"guid"`
+	Age       uint32 `json:"age"`
+	PDBPath   string `json:"pdb_path"`
+}
+
+type reportPOGO struct {
+	Signature string            `json:"signature"`
+	Entries   []reportPOGOEntry `json:"entries"`
+}
+
+type reportPOGOEntry struct {
+	RVA  uint32 `json:"rva"`
+	Size uint32 `json:"size"`
+	Name string `json:"name"`
+}
+
+type reportVCFeature struct {
+	PreVC11 uint32 `json:"pre_vc11"`
+	CCpp    uint32 `json:"c_cpp"`
+	GS      uint32 `json:"gs"`
+	SDL     uint32 `json:"sdl"`
+	GuardN  uint32 `json:"guard_n"`
+}
+
+type reportTLS struct {
+	StartAddressOfRawData uint64              `json:"start_address_of_raw_data"`
+	EndAddressOfRawData   uint64              `json:"end_address_of_raw_data"`
+	RawDataSize           uint64              `json:"raw_data_size"`
+	SizeOfZeroFill        uint32              `json:"size_of_zero_fill"`
+	AddressOfIndex        uint64              `json:"address_of_index"`
+	AddressOfCallBacks    uint64              `json:"address_of_callbacks"`
+	Characteristics       uint32              `json:"characteristics"`
+	Callbacks             []reportTLSCallback `json:"callbacks"`
+}
+
+type reportTLSCallback struct {
+	VA      uint64  `json:"va"`
+	RVA     *uint32 `json:"rva"`     // null when the VA is outside the image
+	Section *string `json:"section"` // null outside any section
+}
+
+type reportLoadConfig struct {
+	Size                          uint32   `json:"size"`
+	LastField                     string   `json:"last_field"`
+	Truncated                     bool     `json:"truncated"`
+	SecurityCookie                uint64   `json:"security_cookie"`
+	SEHandlerTable                uint64   `json:"se_handler_table"`
+	SEHandlerCount                uint64   `json:"se_handler_count"`
+	GuardCFCheckFunctionPointer   uint64   `json:"guard_cf_check_function_pointer"`
+	GuardCFFunctionTable          uint64   `json:"guard_cf_function_table"`
+	GuardCFFunctionCount          uint64   `json:"guard_cf_function_count"`
+	GuardFlags                    uint32   `json:"guard_flags"`
+	GuardFlagsNames               []string `json:"guard_flags_names"`
+	DynamicValueRelocTableOffset  uint32   `json:"dynamic_value_reloc_table_offset"`
+	DynamicValueRelocTableSection uint16   `json:"dynamic_value_reloc_table_section"`
+}
+
+type reportFunction struct {
+	Begin         uint32   `json:"begin"`
+	End           uint32   `json:"end"`
+	UnwindInfo    uint32   `json:"unwind_info"`
+	Version       uint8    `json:"version"`
+	Flags         []string `json:"flags"`
+	SizeOfProlog  uint8    `json:"size_of_prolog"`
+	FrameRegister string   `json:"frame_register"` // "" without a frame pointer
+	FrameOffset   uint32   `json:"frame_offset"`
+	UnwindCodes   []string `json:"unwind_codes"` // As stored: last prolog instruction first
+	HandlerRVA    *uint32  `json:"handler_rva"`  // null without EHANDLER/UHANDLER
+	ChainedTo     *uint32  `json:"chained_to"`   // Begin of the parent function, null unless CHAININFO
+}
+
+type reportAnomaly struct {
+	Index  int    `json:"index"`
+	Begin  uint32 `json:"begin"`
+	End    uint32 `json:"end"`
+	Reason string `json:"reason"`
+}
+
+type reportImportDLL struct {
+	Name      string           `json:"name"`
+	Functions []reportImportFn `json:"functions"`
+}
+
+type reportDelayDLL struct {
+	Name            string           `json:"name"`
+	RVABased        bool             `json:"rva_based"`
+	ModuleHandleRVA uint32           `json:"module_handle_rva"`
+	IATRVA          uint32           `json:"iat_rva"`
+	Functions       []reportImportFn `json:"functions"`
+}
+
+type reportImportFn struct {
+	Name      string `json:"name"` // "" for imports by ordinal
+	Hint      uint16 `json:"hint"`
+	Ordinal   uint16 `json:"ordinal"`
+	ByOrdinal bool   `json:"by_ordinal"`
+	IATRVA    uint32 `json:"iat_rva"`
+}
+
+type reportExports struct {
+	Name        string           `json:"name"`
+	OrdinalBase uint32           `json:"ordinal_base"`
+	Functions   []reportExportFn `json:"functions"`
+}
+
+type reportExportFn struct {
+	Ordinal   uint32 `json:"ordinal"`
+	Name      string `json:"name"` // "" for ordinal-only exports
+	RVA       uint32 `json:"rva"`
+	Forwarder string `json:"forwarder"`
+}
+
+// buildReport collects everything the text dump prints into a report.
+// Directory parsing failures are recorded as warnings rather than aborting,
+// matching the text output.
+func buildReport(dllPath string, f *pe.File) report {
+	r := report{
+		SchemaVersion:   reportSchemaVersion,
+		File:            reportFile{Path: dllPath, Size: len(f.Bytes()), Entropy: pe.Entropy(f.Bytes())},
+		DOSHeader:       reportDOSHeader{Magic: f.DosHeader.Magic, Lfanew: f.DosHeader.Lfanew},
+		Sections:        []reportSection{},
+		Packer:          []reportPackerIndicator{},
+		DataDirectories: []reportDirectory{},
+		Signatures:      []reportSignature{},
+		Resources:       []reportResource{},
+		Debug:           []reportDebug{},
+		ExceptionTable:  []reportFunction{},
+		ExceptionIssues: []reportAnomaly{},
+		Imports:         []reportImportDLL{},
+		DelayImports:    []reportDelayDLL{},
+		Warnings:        []string{},
+	}
+
+	rich, err := f.RichHeader()
+	if err != nil {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("rich header: %v", err))
+	}
+	if rich != nil {
+		r.RichHeader = &reportRich{Offset: rich.Offset, Size: rich.Size, Checksum: rich.Key,
+			ComputedChecksum: rich.ComputedChecksum, ChecksumValid: rich.ChecksumValid(), Entries: []reportCompID{}}
+		for _, e := range rich.Entries {
+			r.RichHeader.Entries = append(r.RichHeader.Entries, reportCompID{
+				ProductID: e.ProductID, Build: e.Build, Count: e.Count, VisualStudio: e.VisualStudioVersion(),
+			})
+		}
+	}
+
+	fh := f.FileHeader
+	r.NTHeaders.Signature = pe.IMAGE_NT_SIGNATURE
+	r.NTHeaders.FileHeader = reportFileHeader{
+		Machine:              fh.Machine,
+		MachineName:          pe.MachineTypeToString(fh.Machine),
+		NumberOfSections:     fh.NumberOfSections,
+		TimeDateStamp:        fh.TimeDateStamp,
+		SizeOfOptionalHeader: fh.SizeOfOptionalHeader,
+		Characteristics:      fh.Characteristics,
+		CharacteristicsNames: nonNil(pe.FileCharacteristicsToStrings(fh.Characteristics)),
+	}
+	r.NTHeaders.OptionalHeader = buildOptionalHeader(f)
+
+	for _, p := range f.PackerIndicators() {
+		r.Packer = append(r.Packer, reportPackerIndicator{Section: p.Section.Name, Reason: p.Reason})
+	}
+	for _, s := range f.Sections {
+		hashes := f.SectionHashes(s)
+		r.Sections = append(r.Sections, reportSection{
+			Name:                 s.Name,
+			VirtualAddress:       s.VirtualAddress,
+			VirtualSize:          s.VirtualSize,
+			PointerToRawData:     s.PointerToRawData,
+			SizeOfRawData:        s.SizeOfRawData,
+			Characteristics:      s.Characteristics,
+			CharacteristicsNames: nonNil(pe.SectionCharacteristicsToStrings(s.Characteristics)),
+			Entropy:              pe.Entropy(f.SectionData(s)),
+			MD5:                  hashes.MD5,
+			SHA256:               hashes.SHA256,
+			TLSH:                 hashes.TLSH,
+		})
+	}
+
+	for _, d := range f.DataDirectories() {
+		entry := reportDirectory{Index: d.Index, Name: d.Name, VirtualAddress: d.VirtualAddress, Size: d.Size}
+		if d.Section != nil {
+			entry.Section = d.Section.Name
+		}
+		r.DataDirectories = append(r.DataDirectories, entry)
+	}
+
+	if o := f.Overlay(); o != nil {
+		r.Overlay = &reportOverlay{Offset: o.Offset, Size: o.Size, Entropy: o.Entropy,
+			Authenticode: o.Authenticode, AppendedSize: o.AppendedSize, SymbolTable: o.SymbolTable}
+	}
+
+	sigs, err := f.Signatures()
+	if err != nil {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("signatures: %v", err))
+	}
+	for _, sig := range sigs {
+		entry := reportSignature{DigestAlgorithm: sig.DigestAlgorithm, SignedDigest: fmt.Sprintf("%x", sig.ImageDigest),
+			Signers: []reportSigner{}, Certificates: []reportCertificate{}}
+		if v, err := f.VerifyDigest(sig); err != nil {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("authenticode digest: %v", err))
+		} else {
+			entry.ComputedDigest = fmt.Sprintf("%x", v.ComputedDigest)
+			entry.DigestMatch = v.Match()
+			entry.MessageDigestValid = v.MessageDigestValid
+		}
+		for _, s := range sig.Signers {
+			signer := reportSigner{Issuer: s.Issuer.String(), SerialNumber: fmt.Sprintf("%X", s.SerialNumber),
+				DigestAlgorithm: s.DigestAlgorithm}
+			if s.Certificate != nil {
+				signer.Subject = s.Certificate.Subject.String()
+			}
+			entry.Signers = append(entry.Signers, signer)
+		}
+		for _, c := range sig.Certificates {
+			entry.Certificates = append(entry.Certificates, reportCertificate{Subject: c.Subject.String(),
+				Issuer: c.Issuer.String(), SerialNumber: fmt.Sprintf("%X", c.SerialNumber),
+				NotBefore: c.NotBefore, NotAfter: c.NotAfter})
+		}
+		r.Signatures = append(r.Signatures, entry)
+	}
+
+	resources, err := f.Resources()
+	if err != nil {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("resources: %v", err))
+	}
+	for _, res := range resources {
+		entry := reportResource{Path: res.PathString(), Type: pe.ResourceTypeName(res.Type()), Name: res.Name().String(),
+			Language: res.Language().String(), RVA: res.OffsetToData, Size: res.Size, CodePage: res.CodePage}
+		if res.HasOffset {
+			offset := res.Offset
+			entry.Offset = &offset
+		}
+		r.Resources = append(r.Resources, entry)
+	}
+
+	if info, err := f.VersionInfo(); err != nil {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("version info: %v", err))
+	} else if info != nil {
+		r.VersionInfo = &reportVersion{Strings: []reportVersionString{}}
+		if info.Fixed != nil {
+			r.VersionInfo.FileVersion = info.Fixed.FileVersion()
+			r.VersionInfo.ProductVersion = info.Fixed.ProductVersion()
+			r.VersionInfo.FileFlags = info.Fixed.FileFlags
+			r.VersionInfo.FileType = info.Fixed.FileType
+		}
+		for _, table := range info.StringTables {
+			for _, s := range table.Strings {
+				r.VersionInfo.Strings = append(r.VersionInfo.Strings, reportVersionString{Table: table.Language, Key: s.Key, Value: s.Value})
+			}
+		}
+		if original := info.String("OriginalFilename"); original != "" {
+			matches := originalFilenameMatches(dllPath, original)
+			r.VersionInfo.OriginalFilenameMatches = &matches
+		}
+	}
+
+	if m, err := f.Manifest(); err != nil {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("manifest: %v", err))
+	} else if m != nil {
+		r.Manifest = &reportManifest{Size: len(m.XML), RequestedExecutionLevel: m.RequestedExecutionLevel,
+			UIAccess: m.UIAccess, Dependencies: []reportDependency{}}
+		for _, d := range m.Dependencies {
+			r.Manifest.Dependencies = append(r.Manifest.Dependencies, reportDependency(d))
+		}
+	}
+
+	debug, err := f.DebugDirectory()
+	if err != nil {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("debug directory: %v", err))
+	}
+	for _, e := range debug {
+		entry := reportDebug{Type: pe.DebugTypeToString(e.Type), TimeDateStamp: e.TimeDateStamp, SizeOfData: e.SizeOfData,
+			AddressOfRawData: e.AddressOfRawData, PointerToRawData: e.PointerToRawData}
+		if cv := e.CodeView; cv != nil {
+			entry.CodeView = &reportCodeView{Signature: cv.Signature, Age: cv.Age, PDBPath: cv.PDBPath}
+			if cv.Signature == "RSDS" {
+				entry.CodeView.GUID = cv.GUIDString()
+			}
+		}
+		if e.POGO != nil {
+			entry.POGO = &reportPOGO{Signature: e.POGO.Signature, Entries: []reportPOGOEntry{}}
+			for _, p := range e.POGO.Entries {
+				entry.POGO.Entries = append(entry.POGO.Entries, reportPOGOEntry(p))
+			}
+		}
+		if e.VCFeature != nil {
+			vc := reportVCFeature(*e.VCFeature)
+			entry.VCFeature = &vc
+		}
+		switch e.Type {
+		case pe.IMAGE_DEBUG_TYPE_REPRO:
+			hash := fmt.Sprintf("%x", e.ReproHash)
+			entry.ReproHash = &hash
+		case pe.IMAGE_DEBUG_TYPE_EX_DLLCHARACTERISTICS:
+			entry.ExDllCharacteristics = nonNil(pe.ExDllCharacteristicsToStrings(e.ExDllCharacteristics))
+		}
+		r.Debug = append(r.Debug, entry)
+	}
+	if f.IsReproducible() {
+		r.Warnings = append(r.Warnings, "reproducible build: TimeDateStamp fields are a content hash, not a date")
+	}
+
+	tls, err := f.TLSDirectory()
+	if err != nil {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("TLS directory: %v", err))
+	}
+	if tls != nil {
+		r.TLS = &reportTLS{StartAddressOfRawData: tls.StartAddressOfRawData, EndAddressOfRawData: tls.EndAddressOfRawData,
+			RawDataSize: tls.RawDataSize(), SizeOfZeroFill: tls.SizeOfZeroFill, AddressOfIndex: tls.AddressOfIndex,
+			AddressOfCallBacks: tls.AddressOfCallBacks, Characteristics: tls.Characteristics, Callbacks: []reportTLSCallback{}}
+		for _, cb := range tls.Callbacks {
+			entry := reportTLSCallback{VA: cb.VA}
+			if cb.Address.Location != pe.LocationOutside {
+				rva := cb.Address.RVA
+				entry.RVA = &rva
+			}
+			if cb.Address.Section != nil {
+				entry.Section = &cb.Address.Section.Name
+			}
+			r.TLS.Callbacks = append(r.TLS.Callbacks, entry)
+		}
+	}
+
+	lc, err := f.LoadConfig()
+	if err != nil {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("load config: %v", err))
+	}
+	if lc != nil {
+		r.LoadConfig = &reportLoadConfig{Size: lc.Size, LastField: lc.LastField, Truncated: lc.Truncated,
+			SecurityCookie: lc.SecurityCookie, SEHandlerTable: lc.SEHandlerTable, SEHandlerCount: lc.SEHandlerCount,
+			GuardCFCheckFunctionPointer: lc.GuardCFCheckFunctionPointer, GuardCFFunctionTable: lc.GuardCFFunctionTable,
+			GuardCFFunctionCount: lc.GuardCFFunctionCount, GuardFlags: lc.GuardFlags,
+			GuardFlagsNames:              nonNil(pe.GuardFlagsToStrings(lc.GuardFlags)),
+			DynamicValueRelocTableOffset: lc.DynamicValueRelocTableOffset, DynamicValueRelocTableSection: lc.DynamicValueRelocTableSection}
+	}
+	r.Mitigations = mitigations(f, lc)
+
+	funcs, err := f.ExceptionTable()
+	if err != nil {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("exception directory: %v", err))
+	}
+	for _, rf := range funcs {
+		u := rf.Unwind
+		entry := reportFunction{Begin: rf.BeginAddress, End: rf.EndAddress, UnwindInfo: u.RVA, Version: u.Version,
+			Flags: nonNil(pe.UnwindFlagsToStrings(u.Flags)), SizeOfProlog: u.SizeOfProlog,
+			FrameRegister: u.FrameRegisterName(), FrameOffset: uint32(u.FrameOffset) * 16, UnwindCodes: []string{}}
+		for _, c := range u.Codes {
+			entry.UnwindCodes = append(entry.UnwindCodes, c.String())
+		}
+		if u.Flags&(pe.UNW_FLAG_EHANDLER|pe.UNW_FLAG_UHANDLER) != 0 {
+			handler := u.HandlerRVA
+			entry.HandlerRVA = &handler
+		}
+		if u.Chained != nil {
+			begin := u.Chained.BeginAddress
+			entry.ChainedTo = &begin
+		}
+		r.ExceptionTable = append(r.ExceptionTable, entry)
+	}
+	for _, a := range f.CheckRuntimeFunctions(funcs) {
+		r.ExceptionIssues = append(r.ExceptionIssues, reportAnomaly{Index: a.Index, Begin: a.Function.BeginAddress,
+			End: a.Function.EndAddress, Reason: a.Reason})
+	}
+
+	imports, err := f.Imports()
+	if err != nil {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("imports: %v", err))
+	}
+	for _, dll := range imports {
+		entry := reportImportDLL{Name: dll.Name, Functions: []reportImportFn{}}
+		for _, fn := range dll.Functions {
+			entry.Functions = append(entry.Functions, reportImportFn{
+				Name: fn.Name, Hint: fn.Hint, Ordinal: fn.Ordinal, ByOrdinal: fn.ByOrdinal, IATRVA: fn.ThunkRVA,
+			})
+		}
+		r.Imports = append(r.Imports, entry)
+	}
+
+	delayImports, err := f.DelayImports()
+	if err != nil {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("delay-load imports: %v", err))
+	}
+	for _, dll := range delayImports {
+		entry := reportDelayDLL{Name: dll.Name, RVABased: dll.RVABased, ModuleHandleRVA: dll.Descriptor.ModuleHandleRVA,
+			IATRVA: dll.Descriptor.ImportAddressTableRVA, Functions: []reportImportFn{}}
+		for _, fn := range dll.Functions {
+			entry.Functions = append(entry.Functions, reportImportFn{
+				Name: fn.Name, Hint: fn.Hint, Ordinal: fn.Ordinal, ByOrdinal: fn.ByOrdinal, IATRVA: fn.ThunkRVA,
+			})
+		}
+		r.DelayImports = append(r.DelayImports, entry)
+	}
+
+	// Parse errors in either table are already reported above.
+	r.Fingerprints.Imphash, _ = f.Imphash()
+	r.Fingerprints.Exphash, _ = f.ExportHash()
+	if digest, err := pe.TLSH(f.Bytes()); err == nil {
+		r.Fingerprints.TLSH = digest.String()
+	}
+
+	exports, err := f.Exports()
+	if err != nil {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("exports: %v", err))
+	}
+	if exports != nil {
+		r.Exports = &reportExports{Name: exports.Name, OrdinalBase: exports.Base, Functions: []reportExportFn{}}
+		for _, fn := range exports.Functions {
+			r.Exports.Functions = append(r.Exports.Functions, reportExportFn{
+				Ordinal: fn.Ordinal, Name: fn.Name, RVA: fn.RVA, Forwarder: fn.Forwarder,
+			})
+		}
+	}
+
+	return r
+}
+
+func buildOptionalHeader(f *pe.File) reportOptionalHeader {
+	h := optionalHeaderFields(f)
+	h.ComputedCheckSum = f.ComputeChecksum()
+	h.CheckSumValid = h.CheckSum == h.ComputedCheckSum
+	return h
+}
+
+func optionalHeaderFields(f *pe.File) reportOptionalHeader {
+	if oh := f.OptionalHeader32; oh != nil {
+		baseOfData := oh.BaseOfData
+		return reportOptionalHeader{
+			Magic: oh.Magic, Format: "PE32",
+			AddressOfEntryPoint: oh.AddressOfEntryPoint, BaseOfCode: oh.BaseOfCode, BaseOfData: &baseOfData,
+			ImageBase: uint64(oh.ImageBase), SectionAlignment: oh.SectionAlignment, FileAlignment: oh.FileAlignment,
+			SizeOfImage: oh.SizeOfImage, SizeOfHeaders: oh.SizeOfHeaders, CheckSum: oh.CheckSum, Subsystem: oh.Subsystem,
+			DllCharacteristics:      oh.DllCharacteristics,
+			DllCharacteristicsNames: nonNil(pe.DllCharacteristicsToStrings(oh.DllCharacteristics)),
+			SizeOfStackReserve:      uint64(oh.SizeOfStackReserve), SizeOfStackCommit: uint64(oh.SizeOfStackCommit),
+			SizeOfHeapReserve: uint64(oh.SizeOfHeapReserve), SizeOfHeapCommit: uint64(oh.SizeOfHeapCommit),
+			NumberOfRvaAndSizes: oh.NumberOfRvaAndSizes,
+		}
+	}
+	oh := f.OptionalHeader64
+	return reportOptionalHeader{
+		Magic: oh.Magic, Format: "PE32+",
+		AddressOfEntryPoint: oh.AddressOfEntryPoint, BaseOfCode: oh.BaseOfCode,
+		ImageBase: oh.ImageBase, SectionAlignment: oh.SectionAlignment, FileAlignment: oh.FileAlignment,
+		SizeOfImage: oh.SizeOfImage, SizeOfHeaders: oh.SizeOfHeaders, CheckSum: oh.CheckSum, Subsystem: oh.Subsystem,
+		DllCharacteristics:      oh.DllCharacteristics,
+		DllCharacteristicsNames: nonNil(pe.DllCharacteristicsToStrings(oh.DllCharacteristics)),
+		SizeOfStackReserve:      oh.SizeOfStackReserve, SizeOfStackCommit: oh.SizeOfStackCommit,
+		SizeOfHeapReserve: oh.SizeOfHeapReserve, SizeOfHeapCommit: oh.SizeOfHeapCommit,
+		NumberOfRvaAndSizes: oh.NumberOfRvaAndSizes,
+	}
+}
+
+// nonNil turns a nil slice into an empty one so it encodes as [].
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// writeJSONReport encodes the report for dllPath as indented JSON.
+func writeJSONReport(w io.Writer, dllPath string, f *pe.File) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(buildReport(dllPath, f))
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"reflective/pe"
+)
+
+// runResource implements "peparser resource": list the resource tree, or
+// extract one resource to a file.
+func runResource(args []string) {
+	fs := flag.NewFlagSet("resource", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s resource <path_to_dll> [<type/name[/lang]> <out_file>]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  e.g. %s resource calc.dll RT_MANIFEST/1 manifest.xml\n", os.Args[0])
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 && fs.NArg() != 3 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	peFile := loadPE(fs.Arg(0), true)
+	if fs.NArg() == 1 {
+		printResources(peFile)
+		return
+	}
+
+	resource, err := peFile.FindResource(fs.Arg(1))
+	if err != nil {
+		log.Fatalf("[-] %v\n", err)
+	}
+	data, err := peFile.ResourceData(*resource)
+	if err != nil {
+		log.Fatalf("[-] Failed to read resource %s: %v\n", resource.PathString(), err)
+	}
+	if err := os.WriteFile(fs.Arg(2), data, 0644); err != nil {
+		log.Fatalf("[-] Failed to write '%s': %v\n", fs.Arg(2), err)
+	}
+	fmt.Printf("[+] Extracted %s (%d bytes) to %s\n", resource.PathString(), len(data), fs.Arg(2))
+}
+
+// printResources lists every leaf of the resource tree.
+func printResources(peFile *pe.File) {
+	resources, err := peFile.Resources()
+	if err != nil {
+		log.Printf("[!] Warning: Failed to fully parse resource directory: %v\n", err)
+	}
+	if len(resources) == 0 {
+		fmt.Printf("--- Resources (none) ---\n")
+		return
+	}
+	fmt.Printf("--- Resources (%d) ---\n", len(resources))
+	for _, r := range resources {
+		offset := "n/a"
+		if r.HasOffset {
+			offset = fmt.Sprintf("0x%X", r.Offset)
+		}
+		fmt.Printf("  %-32s Size: 0x%-6X CodePage: %-5d RVA: 0x%X  Offset: %s\n", r.PathString(), r.Size, r.CodePage, r.OffsetToData, offset)
+	}
+}
+
+// printVersionInfo decodes RT_VERSION and checks OriginalFilename against
+// the name the file has on disk; a renamed DLL is an early triage flag.
+func printVersionInfo(dllPath string, peFile *pe.File) {
+	info, err := peFile.VersionInfo()
+	if err != nil {
+		log.Printf("[!] Warning: Failed to decode version information: %v\n", err)
+		return
+	}
+	if info == nil {
+		fmt.Printf("--- Version Info (none) ---\n")
+		return
+	}
+	fmt.Printf("--- Version Info ---\n")
+	if info.Fixed != nil {
+		fmt.Printf("  FileVersion (binary): %s\n", info.Fixed.FileVersion())
+		fmt.Printf("  ProductVersion (binary): %s\n", info.Fixed.ProductVersion())
+		fmt.Printf("  FileFlags: 0x%X  FileOS: 0x%X  FileType: 0x%X\n", info.Fixed.FileFlags, info.Fixed.FileOS, info.Fixed.FileType)
+	}
+	for _, table := range info.StringTables {
+		fmt.Printf("  StringFileInfo %s:\n", table.Language)
+		for _, s := range table.Strings {
+			fmt.Printf("    %-18s %s\n", s.Key+":", s.Value)
+		}
+	}
+	if original := info.String("OriginalFilename"); original != "" {
+		if originalFilenameMatches(dllPath, original) {
+			fmt.Printf("  [+] OriginalFilename matches the file name on disk\n")
+		} else {
+			fmt.Printf("  [!] OriginalFilename '%s' differs from the file name on disk '%s'\n", original, filepath.Base(dllPath))
+		}
+	}
+}
+
+// originalFilenameMatches compares case-insensitively, as Windows would.
+// Language resource DLLs record their ".mui" suffix in OriginalFilename too,
+// so no suffix needs special handling.
+func originalFilenameMatches(dllPath, original string) bool {
+	return strings.EqualFold(original, filepath.Base(dllPath))
+}
+
+// printManifest summarises RT_MANIFEST: the UAC execution level and the
+// side-by-side assemblies the image binds to.
+func printManifest(peFile *pe.File) {
+	manifest, err := peFile.Manifest()
+	if err != nil {
+		log.Printf("[!] Warning: Failed to decode manifest: %v\n", err)
+		return
+	}
+	if manifest == nil {
+		fmt.Printf("--- Manifest (none) ---\n")
+		return
+	}
+	fmt.Printf("--- Manifest (%d bytes) ---\n", len(manifest.XML))
+	level := manifest.RequestedExecutionLevel
+	if level == "" {
+		level = "(not specified)"
+	}
+	fmt.Printf("  requestedExecutionLevel: %s", level)
+	if manifest.UIAccess != "" {
+		fmt.Printf(" (uiAccess=%s)", manifest.UIAccess)
+	}
+	fmt.Println()
+	if manifest.RequestedExecutionLevel == "requireAdministrator" {
+		fmt.Printf("  [!] Requests elevation when launched\n")
+	}
+	for _, dep := range manifest.Dependencies {
+		fmt.Printf("  Dependency: %s %s (arch %s, token %s)\n", dep.Name, dep.Version, dep.ProcessorArchitecture, dep.PublicKeyToken)
+	}
+}
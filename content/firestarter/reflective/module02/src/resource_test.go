@@ -0,0 +1,24 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestOriginalFilenameMatches(t *testing.T) {
+	for _, tc := range []struct {
+		path, original string
+		want           bool
+	}{
+		{"calc_dll.dll", "calc_dll.dll", true},
+		{filepath.Join("lab", "CALC_DLL.DLL"), "calc_dll.dll", true},
+		{"payload.dll", "calc_dll.dll", false},
+		{filepath.Join("en-US", "notepad.exe.mui"), "NOTEPAD.EXE.MUI", true},
+		{"notepad.exe.mui", "NOTEPAD.EXE", false},
+		{"notepad.exe", "NOTEPAD.EXE.MUI", false},
+	} {
+		if got := originalFilenameMatches(tc.path, tc.original); got != tc.want {
+			t.Errorf("originalFilenameMatches(%q, %q) = %v, want %v", tc.path, tc.original, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"reflective/pe"
+)
+
+// printTLSDirectory lists the TLS template and callbacks. The loader runs
+// every callback with DLL_PROCESS_ATTACH before DllMain, so a callback is an
+// entry point in its own right, and a mapper that skips them skips code.
+func printTLSDirectory(peFile *pe.File) {
+	tls, err := peFile.TLSDirectory()
+	if err != nil {
+		log.Printf("[!] Warning: Failed to fully parse TLS directory: %v\n", err)
+	}
+	if tls == nil {
+		fmt.Printf("--- TLS Directory (none) ---\n")
+		return
+	}
+	fmt.Printf("--- TLS Directory ---\n")
+	fmt.Printf("  Raw Data: 0x%X - 0x%X (%d bytes)\n", tls.StartAddressOfRawData, tls.EndAddressOfRawData, tls.RawDataSize())
+	fmt.Printf("  SizeOfZeroFill: 0x%X\n", tls.SizeOfZeroFill)
+	fmt.Printf("  AddressOfIndex: 0x%X\n", tls.AddressOfIndex)
+	fmt.Printf("  AddressOfCallBacks: 0x%X\n", tls.AddressOfCallBacks)
+	fmt.Printf("  Characteristics: 0x%X\n", tls.Characteristics)
+	fmt.Printf("  Callbacks (%d):\n", len(tls.Callbacks))
+	for i, cb := range tls.Callbacks {
+		fmt.Printf("    [%d] VA 0x%X -> %s\n", i, cb.VA, cb.Address)
+		if cb.Address.Section == nil || cb.Address.Section.Characteristics&pe.IMAGE_SCN_MEM_EXECUTE == 0 {
+			fmt.Printf("    [!] Callback does not point into an executable section\n")
+		}
+	}
+	if len(tls.Callbacks) != 0 {
+		fmt.Printf("  [*] Callbacks run before the entry point on process and thread attach\n")
+	}
+}
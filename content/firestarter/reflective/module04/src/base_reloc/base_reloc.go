@@ -4,8 +4,6 @@
 package main
 
 import (
-	"bytes"
-	"encoding/binary"
 	"fmt"
 	"log"
 	"os"
@@ -13,104 +11,15 @@ import (
 	"unsafe"  // Needed for pointer conversions with syscall/windows
 
 	"golang.org/x/sys/windows"
-)
-
-// --- Existing PE Structures ---
-type IMAGE_DOS_HEADER struct {
-	Magic    uint16     // Magic number (MZ)
-	Cblp     uint16     // Bytes on last page of file
-	Cp       uint16     // Pages in file
-	Crlc     uint16     // Relocations
-	Cparhdr  uint16     // Size of header in paragraphs
-	MinAlloc uint16     // Minimum extra paragraphs needed
-	MaxAlloc uint16     // Maximum extra paragraphs needed
-	Ss       uint16     // Initial (relative) SS value
-	Sp       uint16     // Initial SP value
-	Csum     uint16     // Checksum
-	Ip       uint16     // Initial IP value
-	Cs       uint16     // Initial (relative) CS value
-	Lfarlc   uint16     // File address of relocation table
-	Ovno     uint16     // Overlay number
-	Res      [4]uint16  // Reserved words
-	Oemid    uint16     // OEM identifier (for e_oeminfo)
-	Oeminfo  uint16     // OEM information; e_oemid specific
-	Res2     [10]uint16 // Reserved words
-	Lfanew   int32      // File address of new exe header (PE header offset)
-}
-
-type IMAGE_FILE_HEADER struct {
-	Machine              uint16 // Architecture type
-	NumberOfSections     uint16 // Number of sections
-	TimeDateStamp        uint32 // Time and date stamp
-	PointerToSymbolTable uint32 // Pointer to symbol table
-	NumberOfSymbols      uint32 // Number of symbols
-	SizeOfOptionalHeader uint16 // Size of optional header
-	Characteristics      uint16 // File characteristics
-}
-
-type IMAGE_DATA_DIRECTORY struct {
-	VirtualAddress uint32 // RVA of the directory
-	Size           uint32 // Size of the directory
-}
 
-type IMAGE_OPTIONAL_HEADER64 struct {
-	Magic                       uint16 // Magic number (0x20b for PE32+)
-	MajorLinkerVersion          uint8
-	MinorLinkerVersion          uint8
-	SizeOfCode                  uint32
-	SizeOfInitializedData       uint32
-	SizeOfUninitializedData     uint32
-	AddressOfEntryPoint         uint32 // RVA of the entry point
-	BaseOfCode                  uint32
-	ImageBase                   uint64 // Preferred base address
-	SectionAlignment            uint32
-	FileAlignment               uint32
-	MajorOperatingSystemVersion uint16
-	MinorOperatingSystemVersion uint16
-	MajorImageVersion           uint16
-	MinorImageVersion           uint16
-	MajorSubsystemVersion       uint16
-	MinorSubsystemVersion       uint16
-	Win32VersionValue           uint32
-	SizeOfImage                 uint32 // Total size of the image in memory
-	SizeOfHeaders               uint32 // Size of headers (DOS + PE + Section Headers)
-	CheckSum                    uint32
-	Subsystem                   uint16
-	DllCharacteristics          uint16
-	SizeOfStackReserve          uint64
-	SizeOfStackCommit           uint64
-	SizeOfHeapReserve           uint64
-	SizeOfHeapCommit            uint64
-	LoaderFlags                 uint32
-	NumberOfRvaAndSizes         uint32
-	DataDirectory               [16]IMAGE_DATA_DIRECTORY // Array of data directories
-}
+	"reflective/pe"
+)
 
-type IMAGE_SECTION_HEADER struct {
-	Name                 [8]byte // Section name (null-padded)
-	VirtualSize          uint32  // Actual size used in memory
-	VirtualAddress       uint32  // RVA of the section
-	SizeOfRawData        uint32  // Size of section data on disk
-	PointerToRawData     uint32  // File offset of section data
-	PointerToRelocations uint32  // File offset of relocations
-	PointerToLinenumbers uint32  // File offset of line numbers
-	NumberOfRelocations  uint16  // Number of relocations
-	NumberOfLinenumbers  uint16  // Number of line numbers
-	Characteristics      uint32  // Section characteristics (flags like executable, readable, writable)
-}
-
-// --- NEW: Relocation Structures/Constants  ---
-type IMAGE_BASE_RELOCATION struct { //nolint:revive // Windows struct
-	VirtualAddress uint32 // RVA of the page this block applies to
-	SizeOfBlock    uint32 // Total size of this relocation block (including header)
-}
+// --- PE Structures ---
+// Shared with the other labs through the reflective/pe package.
 
+// --- Constants ---
 const (
-	IMAGE_DOS_SIGNATURE             = 0x5A4D     // "MZ"
-	IMAGE_NT_SIGNATURE              = 0x00004550 // "PE\0\0"
-	IMAGE_DIRECTORY_ENTRY_BASERELOC = 5          // Base Relocation Table index in DataDirectory
-	IMAGE_REL_BASED_DIR64           = 10         // Relocation type for 64-bit addresses (x64)
-	IMAGE_REL_BASED_ABSOLUTE        = 0          // Padding/nop relocation type
 	// Adding Memory constants if not already implicitly available via windows package
 	MEM_COMMIT             = 0x00001000
 	MEM_RESERVE            = 0x00002000
@@ -119,15 +28,6 @@ const (
 	PAGE_EXECUTE_READWRITE = 0x40
 )
 
-// --- Existing Helper Functions ---
-func sectionNameToString(nameBytes [8]byte) string {
-	n := bytes.IndexByte(nameBytes[:], 0)
-	if n == -1 {
-		n = 8
-	}
-	return string(nameBytes[:n])
-}
-
 // --- Main Function ---
 func main() {
 	// Ensure running on Windows
@@ -146,35 +46,19 @@ func main() {
 	if err != nil {
 		log.Fatalf("[-] Failed to read file '%s': %v\n", dllPath, err)
 	}
-	reader := bytes.NewReader(dllBytes)
-	var dosHeader IMAGE_DOS_HEADER
-	if err := binary.Read(reader, binary.LittleEndian, &dosHeader); err != nil {
-		log.Fatalf("[-] Failed to read DOS header: %v\n", err)
-	}
-	if dosHeader.Magic != IMAGE_DOS_SIGNATURE {
-		log.Fatalf("[-] Invalid DOS signature")
-	}
-	if _, err := reader.Seek(int64(dosHeader.Lfanew), 0); err != nil {
-		log.Fatalf("[-] Failed to seek to NT Headers: %v\n", err)
-	}
-	var peSignature uint32
-	if err := binary.Read(reader, binary.LittleEndian, &peSignature); err != nil {
-		log.Fatalf("[-] Failed to read PE signature: %v\n", err)
-	}
-	if peSignature != IMAGE_NT_SIGNATURE {
-		log.Fatalf("[-] Invalid PE signature")
-	}
-	var fileHeader IMAGE_FILE_HEADER
-	if err := binary.Read(reader, binary.LittleEndian, &fileHeader); err != nil {
-		log.Fatalf("[-] Failed to read File Header: %v\n", err)
+	peFile, err := pe.Parse(dllBytes)
+	if err != nil {
+		log.Fatalf("[-] Failed to parse PE headers: %v\n", err)
 	}
-	var optionalHeader IMAGE_OPTIONAL_HEADER64
-	if err := binary.Read(reader, binary.LittleEndian, &optionalHeader); err != nil {
-		log.Fatalf("[-] Failed to read Optional Header: %v\n", err)
+	dosHeader := peFile.DosHeader
+	fileHeader := peFile.FileHeader
+	if !peFile.Is64() {
+		log.Fatalf("[-] This loader only maps PE32+ (64-bit) DLLs, got %s.\n", pe.MagicTypeToString(peFile.Magic()))
 	}
-	if optionalHeader.Magic != 0x20b {
-		log.Printf("[!] Warning: Optional Header Magic is not PE32+ (0x20b).")
+	if warning := peFile.CFGWarning(); warning != "" {
+		log.Printf("[!] Warning: %s.\n", warning)
 	}
+	optionalHeader := peFile.OptionalHeader64
 	fmt.Println("[+] Parsed PE Headers successfully.")
 	fmt.Printf("[+] Target ImageBase: 0x%X\n", optionalHeader.ImageBase)
 	fmt.Printf("[+] Target SizeOfImage: 0x%X (%d bytes)\n", optionalHeader.SizeOfImage, optionalHeader.SizeOfImage)
@@ -247,15 +131,15 @@ func main() {
 	// NoteL Code adjusted slightly from Lab 3.1 to read section headers from allocBase
 	fmt.Println("[+] Copying sections...")
 	firstSectionHeaderOffset := uintptr(dosHeader.Lfanew) + 4 + unsafe.Sizeof(fileHeader) + uintptr(fileHeader.SizeOfOptionalHeader)
-	sectionHeaderSize := unsafe.Sizeof(IMAGE_SECTION_HEADER{}) // Get size of struct for iteration
+	sectionHeaderSize := unsafe.Sizeof(pe.IMAGE_SECTION_HEADER{}) // Get size of struct for iteration
 
 	for i := uint16(0); i < fileHeader.NumberOfSections; i++ {
 		// *** Read section header from the *mapped* headers in allocBase ***
 		currentSectionHeaderAddr := allocBase + firstSectionHeaderOffset + uintptr(i)*sectionHeaderSize
-		sectionHeader := (*IMAGE_SECTION_HEADER)(unsafe.Pointer(currentSectionHeaderAddr))
+		sectionHeader := (*pe.IMAGE_SECTION_HEADER)(unsafe.Pointer(currentSectionHeaderAddr))
 		// *** End modification for reading section header ***
 
-		sectionName := sectionNameToString(sectionHeader.Name)
+		sectionName := pe.SectionNameToString(sectionHeader.Name)
 		fmt.Printf("  [*] Processing Section %d: '%s'\n", i, sectionName)
 		if sectionHeader.SizeOfRawData == 0 {
 			fmt.Printf("    [*] Skipping section '%s' (SizeOfRawData is 0).\n", sectionName)
@@ -284,7 +168,7 @@ func main() {
 	} else {
 		fmt.Printf("[+] Image loaded at non-preferred base (Delta: 0x%X). Processing relocations...\n", delta)
 		// Find the Base Relocation Directory entry using the parsed optionalHeader
-		relocDirEntry := optionalHeader.DataDirectory[IMAGE_DIRECTORY_ENTRY_BASERELOC]
+		relocDirEntry := optionalHeader.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_BASERELOC]
 		relocDirRVA := relocDirEntry.VirtualAddress
 		relocDirSize := relocDirEntry.Size
 
@@ -297,17 +181,17 @@ func main() {
 			currentBlockAddr := relocTableBase
 			totalFixups := 0
 
-			// Iterate through IMAGE_BASE_RELOCATION blocks
+			// Iterate through pe.IMAGE_BASE_RELOCATION blocks
 			for currentBlockAddr < relocTableEnd {
 				// Read block header directly from allocBase memory
-				blockHeader := (*IMAGE_BASE_RELOCATION)(unsafe.Pointer(currentBlockAddr))
+				blockHeader := (*pe.IMAGE_BASE_RELOCATION)(unsafe.Pointer(currentBlockAddr))
 				// Check for end marker or invalid size
-				if blockHeader.VirtualAddress == 0 || blockHeader.SizeOfBlock <= uint32(unsafe.Sizeof(IMAGE_BASE_RELOCATION{})) {
+				if blockHeader.VirtualAddress == 0 || blockHeader.SizeOfBlock <= uint32(unsafe.Sizeof(pe.IMAGE_BASE_RELOCATION{})) {
 					break // Stop processing
 				}
 
-				numEntries := (blockHeader.SizeOfBlock - uint32(unsafe.Sizeof(IMAGE_BASE_RELOCATION{}))) / 2
-				entryPtr := currentBlockAddr + unsafe.Sizeof(IMAGE_BASE_RELOCATION{}) // Pointer to first entry
+				numEntries := (blockHeader.SizeOfBlock - uint32(unsafe.Sizeof(pe.IMAGE_BASE_RELOCATION{}))) / 2
+				entryPtr := currentBlockAddr + unsafe.Sizeof(pe.IMAGE_BASE_RELOCATION{}) // Pointer to first entry
 
 				// Iterate through the 16-bit entries in this block
 				for i := uint32(0); i < numEntries; i++ {
@@ -316,7 +200,7 @@ func main() {
 					relocType := entry >> 12
 					offset := entry & 0xFFF
 
-					if relocType == IMAGE_REL_BASED_DIR64 {
+					if relocType == pe.IMAGE_REL_BASED_DIR64 {
 						// Calculate the absolute VA within allocBase where the patch needs to be applied
 						patchAddr := allocBase + uintptr(blockHeader.VirtualAddress) + uintptr(offset)
 						// Read the original 64-bit value directly from allocBase memory
@@ -327,7 +211,7 @@ func main() {
 						// Write the new value back directly into allocBase memory
 						*originalValuePtr = newValue
 						totalFixups++
-					} else if relocType != IMAGE_REL_BASED_ABSOLUTE {
+					} else if relocType != pe.IMAGE_REL_BASED_ABSOLUTE {
 						fmt.Printf("        [!] Warning: Skipping unhandled relocation type %d at offset 0x%X\n", relocType, offset)
 					}
 				}
@@ -353,32 +237,3 @@ func main() {
 	fmt.Println("[+] Mapper finished.")
 	// Deferred VirtualFree calls will execute now
 }
-
-// Helper functions
-func machineTypeToString(machine uint16) string {
-	switch machine {
-	case 0x0:
-		return "Unknown"
-	case 0x14c:
-		return "x86 (I386)"
-	case 0x8664:
-		return "x64 (AMD64)"
-	case 0xaa64:
-		return "ARM64"
-	case 0x1c0:
-		return "ARM"
-	default:
-		return "Other"
-	}
-}
-
-func magicTypeToString(magic uint16) string {
-	switch magic {
-	case 0x10b:
-		return "PE32 (32-bit)"
-	case 0x20b:
-		return "PE32+ (64-bit)"
-	default:
-		return "Unknown/Invalid"
-	}
-}
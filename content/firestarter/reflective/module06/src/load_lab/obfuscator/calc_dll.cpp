@@ -1,3 +1,5 @@
+//go:build ignore
+
 #include <windows.h>
 
 unsigned char calc_shellcode[] = {
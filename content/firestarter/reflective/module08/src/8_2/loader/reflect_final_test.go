@@ -0,0 +1,88 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// loaderArgsEnv makes the test binary run the loader's main with the given
+// newline-separated arguments instead of the tests, so a crash inside the
+// mapped DLL only takes down the child process.
+const loaderArgsEnv = "REFLECT_LOADER_ARGS"
+
+func TestMain(m *testing.M) {
+	if args := os.Getenv(loaderArgsEnv); args != "" {
+		os.Args = append(os.Args[:1], strings.Split(args, "\n")...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// buildTestDLL compiles testdata/seh_dll.cpp with MinGW-w64, the toolchain
+// the labs use for their DLLs, and skips the test when none is installed.
+// A plain g++ only qualifies if it targets x86_64-w64-mingw32: a 32-bit
+// MinGW, MSYS or Cygwin compiler builds DLLs this loader rejects.
+func buildTestDLL(t *testing.T) string {
+	t.Helper()
+	var cxx string
+	for _, name := range []string{"x86_64-w64-mingw32-g++", "g++"} {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			continue
+		}
+		if target, err := exec.Command(path, "-dumpmachine").Output(); err == nil && strings.TrimSpace(string(target)) == "x86_64-w64-mingw32" {
+			cxx = path
+			break
+		}
+	}
+	if cxx == "" {
+		t.Skip("no x86_64-w64-mingw32 g++ in PATH to build testdata/seh_dll.cpp")
+	}
+	dll := filepath.Join(t.TempDir(), "seh_dll.dll")
+	cmd := exec.Command(cxx, filepath.Join("testdata", "seh_dll.cpp"), "-o", dll,
+		"-shared", "-static", "-static-libgcc", "-static-libstdc++")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("building test DLL: %v\n%s", err, out)
+	}
+	return dll
+}
+
+// runLoader maps dll in a child process and returns its output and whether
+// it exited cleanly.
+func runLoader(t *testing.T, args ...string) (string, bool) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), loaderArgsEnv+"="+strings.Join(args, "\n"))
+	out, err := cmd.CombinedOutput()
+	return string(out), err == nil
+}
+
+func TestExceptionTableRegistration(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skip("the loader maps x64 images only")
+	}
+	dll := buildTestDLL(t)
+	const ok = "Exported function 'ThrowAndCatch' executed successfully (returned TRUE)"
+
+	out, clean := runLoader(t, "-dll", dll, "-export", "ThrowAndCatch")
+	if !clean || !strings.Contains(out, ok) {
+		t.Fatalf("with the exception table registered, ThrowAndCatch failed (clean exit %v):\n%s", clean, out)
+	}
+	if !strings.Contains(out, "Exception table deregistered.") {
+		t.Errorf("exception table was not deregistered on unload:\n%s", out)
+	}
+
+	// Without the table the throw can't find the catch frame, so the
+	// exception is unhandled and the process dies.
+	out, clean = runLoader(t, "-dll", dll, "-export", "ThrowAndCatch", "-register-exceptions=false")
+	if clean || strings.Contains(out, ok) {
+		t.Errorf("ThrowAndCatch survived without a registered exception table (clean exit %v):\n%s", clean, out)
+	}
+}
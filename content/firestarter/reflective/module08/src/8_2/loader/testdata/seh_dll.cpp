@@ -0,0 +1,31 @@
+// Test DLL for the reflective loader: ThrowAndCatch throws a C++ exception
+// a few frames deep and catches it again, all inside the image. Dispatching
+// it needs the image's .pdata, so it only returns TRUE when the loader has
+// registered the exception table.
+//
+//   x86_64-w64-mingw32-g++ seh_dll.cpp -o seh_dll.dll -shared -static -static-libgcc -static-libstdc++
+
+#include <windows.h>
+#include <stdexcept>
+
+static int Thrower(int depth) {
+    if (depth == 0) {
+        throw std::runtime_error("thrown inside the reflectively loaded image");
+    }
+    return Thrower(depth - 1) + 1;
+}
+
+extern "C" {
+    __declspec(dllexport) BOOL ThrowAndCatch() {
+        try {
+            Thrower(3);
+        } catch (const std::runtime_error&) {
+            return TRUE;
+        }
+        return FALSE;
+    }
+}
+
+BOOL WINAPI DllMain(HINSTANCE hinstDLL, DWORD fdwReason, LPVOID lpvReserved) {
+    return TRUE;
+}
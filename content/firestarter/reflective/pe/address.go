@@ -0,0 +1,133 @@
+package pe
+
+import "fmt"
+
+// Location classifies where an address falls within an image.
+type Location int
+
+const (
+	LocationOutside  Location = iota // Not part of the image (or the file, for offsets)
+	LocationHeaders                  // DOS/NT headers and section table
+	LocationSection                  // Inside a section's raw data
+	LocationZeroFill                 // Mapped, but past SizeOfRawData: zero-filled by the loader, no file bytes
+)
+
+func (l Location) String() string {
+	switch l {
+	case LocationHeaders:
+		return "headers"
+	case LocationSection:
+		return "section"
+	case LocationZeroFill:
+		return "zero-filled (not on disk)"
+	default:
+		return "outside image"
+	}
+}
+
+// Address is one location expressed in all three forms used when reading a
+// PE: relative virtual address, virtual address at the preferred ImageBase,
+// and file offset. Offset is only meaningful when HasOffset is set, and RVA
+// and VA only when Location is not LocationOutside.
+type Address struct {
+	RVA       uint32
+	VA        uint64
+	Offset    uint32
+	HasOffset bool
+	Section   *Section // Containing section, nil for headers and outside addresses
+	Location  Location
+}
+
+func (a Address) String() string {
+	offset := "n/a"
+	if a.HasOffset {
+		offset = fmt.Sprintf("0x%X", a.Offset)
+	}
+	where := a.Location.String()
+	if a.Section != nil {
+		where = fmt.Sprintf("%s '%s'", where, a.Section.Name)
+	}
+	if a.Location == LocationOutside {
+		return fmt.Sprintf("RVA n/a, VA n/a, file offset %s (%s)", offset, where)
+	}
+	return fmt.Sprintf("RVA 0x%X, VA 0x%X, file offset %s (%s)", a.RVA, a.VA, offset, where)
+}
+
+// TranslateRVA resolves an RVA against the section table.
+func (f *File) TranslateRVA(rva uint32) Address {
+	a := Address{RVA: rva, VA: f.ImageBase() + uint64(rva)}
+
+	if s := f.sectionForRVA(rva); s != nil {
+		a.Section = s
+		delta := rva - s.VirtualAddress
+		if delta < s.SizeOfRawData {
+			a.Location = LocationSection
+			a.Offset, a.HasOffset = s.PointerToRawData+delta, true
+		} else {
+			a.Location = LocationZeroFill
+		}
+		return a
+	}
+
+	if rva < f.SizeOfHeaders() {
+		a.Location = LocationHeaders
+		a.Offset, a.HasOffset = rva, uint64(rva) < uint64(len(f.data))
+		return a
+	}
+
+	// The loader maps every section out to the next SectionAlignment
+	// boundary, so the gap after VirtualSize is still zero-filled image.
+	if rva < f.SizeOfImage() {
+		for _, s := range f.Sections {
+			end := alignUp(uint64(s.VirtualAddress)+uint64(s.VirtualSize), uint64(f.SectionAlignment()))
+			if uint64(rva) >= uint64(s.VirtualAddress) && uint64(rva) < end {
+				a.Section, a.Location = s, LocationZeroFill
+				return a
+			}
+		}
+	}
+
+	return Address{Location: LocationOutside}
+}
+
+// TranslateVA resolves a virtual address, assuming the image sits at its
+// preferred ImageBase.
+func (f *File) TranslateVA(va uint64) Address {
+	base := f.ImageBase()
+	if va < base || va-base >= uint64(f.SizeOfImage()) {
+		return Address{Location: LocationOutside}
+	}
+	return f.TranslateRVA(uint32(va - base))
+}
+
+// TranslateOffset resolves a file offset back to the RVA it is mapped at.
+// Offsets that no section claims (such as appended data) are reported as
+// outside the image even though they exist in the file.
+func (f *File) TranslateOffset(offset uint32) Address {
+	if uint64(offset) >= uint64(len(f.data)) {
+		return Address{Location: LocationOutside}
+	}
+	for _, s := range f.Sections {
+		if s.SizeOfRawData == 0 {
+			continue
+		}
+		if offset >= s.PointerToRawData && uint64(offset) < uint64(s.PointerToRawData)+uint64(s.SizeOfRawData) {
+			rva := s.VirtualAddress + (offset - s.PointerToRawData)
+			return Address{RVA: rva, VA: f.ImageBase() + uint64(rva), Offset: offset, HasOffset: true,
+				Section: s, Location: LocationSection}
+		}
+	}
+	if offset < f.SizeOfHeaders() {
+		return Address{RVA: offset, VA: f.ImageBase() + uint64(offset), Offset: offset, HasOffset: true,
+			Location: LocationHeaders}
+	}
+	return Address{Offset: offset, HasOffset: true, Location: LocationOutside}
+}
+
+// alignUp rounds v up to the next multiple of align (which may be zero).
+func alignUp(v, align uint64) uint64 {
+	if align == 0 {
+		return v
+	}
+	return (v + align - 1) / align * align
+}
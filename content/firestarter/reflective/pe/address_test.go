@@ -0,0 +1,51 @@
+package pe
+
+import "testing"
+
+func TestTranslate(t *testing.T) {
+	f, err := Parse(loadCalcDLL(t))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	const base = 0x26A5B0000
+
+	tests := []struct {
+		name      string
+		got       Address
+		location  Location
+		section   string
+		rva       uint32
+		offset    uint32
+		hasOffset bool
+	}{
+		{"entry point RVA", f.TranslateRVA(0x1330), LocationSection, ".text", 0x1330, 0x930, true},
+		{"entry point VA", f.TranslateVA(base + 0x1330), LocationSection, ".text", 0x1330, 0x930, true},
+		{"entry point offset", f.TranslateOffset(0x930), LocationSection, ".text", 0x1330, 0x930, true},
+		{"header RVA", f.TranslateRVA(0x80), LocationHeaders, "", 0x80, 0x80, true},
+		{"bss RVA", f.TranslateRVA(0x7010), LocationZeroFill, ".bss", 0x7010, 0, false},
+		{"section alignment gap", f.TranslateRVA(0x3800), LocationZeroFill, ".data", 0x3800, 0, false},
+		{"past SizeOfImage", f.TranslateRVA(0x30000), LocationOutside, "", 0, 0, false},
+		{"VA below ImageBase", f.TranslateVA(base - 1), LocationOutside, "", 0, 0, false},
+		{"trailing file data", f.TranslateOffset(0x14100), LocationOutside, "", 0, 0x14100, true},
+		{"offset past EOF", f.TranslateOffset(0x100000), LocationOutside, "", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := tt.got
+			if a.Location != tt.location {
+				t.Fatalf("Location = %v, want %v (%v)", a.Location, tt.location, a)
+			}
+			section := ""
+			if a.Section != nil {
+				section = a.Section.Name
+			}
+			if section != tt.section || a.RVA != tt.rva || a.Offset != tt.offset || a.HasOffset != tt.hasOffset {
+				t.Errorf("got %v in %q, want RVA 0x%X offset 0x%X (%v) in %q",
+					a, section, tt.rva, tt.offset, tt.hasOffset, tt.section)
+			}
+			if a.Location != LocationOutside && a.VA != base+uint64(a.RVA) {
+				t.Errorf("VA = 0x%X, want 0x%X", a.VA, base+uint64(a.RVA))
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package pe
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+)
+
+type WIN_CERTIFICATE struct { //nolint:revive // Windows struct
+	Length          uint32 // Length of the entry including this header, not padded
+	Revision        uint16 // WIN_CERT_REVISION_*
+	CertificateType uint16 // WIN_CERT_TYPE_*
+	// Followed by Length-8 bytes of certificate data, then padding to 8 bytes
+}
+
+const (
+	WIN_CERT_REVISION_1_0 = 0x0100 //nolint:revive // Windows constant
+	WIN_CERT_REVISION_2_0 = 0x0200 //nolint:revive // Windows constant
+
+	WIN_CERT_TYPE_X509             = 0x0001 //nolint:revive // Windows constant
+	WIN_CERT_TYPE_PKCS_SIGNED_DATA = 0x0002 //nolint:revive // Windows constant
+	WIN_CERT_TYPE_TS_STACK_SIGNED  = 0x0004 //nolint:revive // Windows constant
+)
+
+// CertificateEntry is one WIN_CERTIFICATE from the attribute certificate
+// table.
+type CertificateEntry struct {
+	WIN_CERTIFICATE
+	Offset uint32 // File offset of the WIN_CERTIFICATE header
+	Data   []byte // bCertificate: a DER PKCS#7 SignedData for Authenticode
+}
+
+// CertificateTable walks the attribute certificate table that
+// DataDirectory[4] (Security) points at. Unlike every other directory its
+// VirtualAddress is a file offset: the table is never mapped, it just sits
+// at the end of the file. Returns nil, nil for an unsigned image.
+func (f *File) CertificateTable() ([]CertificateEntry, error) {
+	dir := f.DataDirectory(IMAGE_DIRECTORY_ENTRY_SECURITY)
+	if dir.VirtualAddress == 0 || dir.Size == 0 {
+		return nil, nil
+	}
+	start, end := uint64(dir.VirtualAddress), uint64(dir.VirtualAddress)+uint64(dir.Size)
+	if end > uint64(len(f.data)) {
+		return nil, &FormatError{Op: "certificate table", Offset: int64(start), Err: ErrTruncated}
+	}
+
+	var entries []CertificateEntry
+	headerSize := uint64(binary.Size(WIN_CERTIFICATE{}))
+	for offset := start; offset+headerSize <= end; {
+		var e CertificateEntry
+		e.Offset = uint32(offset)
+		binary.Read(bytes.NewReader(f.data[offset:offset+headerSize]), binary.LittleEndian, &e.WIN_CERTIFICATE)
+		if uint64(e.Length) < headerSize || offset+uint64(e.Length) > end {
+			return entries, &FormatError{Op: "WIN_CERTIFICATE", Offset: int64(offset), Err: ErrInvalidCertificate}
+		}
+		e.Data = f.data[offset+headerSize : offset+uint64(e.Length)]
+		entries = append(entries, e)
+		offset += alignUp(uint64(e.Length), 8) // Entries are quadword aligned
+	}
+	return entries, nil
+}
+
+// Signature is a decoded Authenticode PKCS#7 SignedData blob.
+type Signature struct {
+	DigestAlgorithm string              // Algorithm used for the image hash, e.g. "SHA256"
+	ImageDigest     []byte              // Image hash the signer vouched for (SpcIndirectDataContent)
+	Certificates    []*x509.Certificate // Every certificate embedded in the blob, signer first as a rule
+	Signers         []Signer
+
+	indirectData []byte // SpcIndirectDataContent value bytes, which the signer's messageDigest covers
+}
+
+// Signer is one SignerInfo: who signed, identified by issuer and serial, and
+// the certificate from the blob that matches.
+type Signer struct {
+	Issuer          pkix.Name
+	SerialNumber    *big.Int
+	DigestAlgorithm string
+	MessageDigest   []byte            // messageDigest authenticated attribute, nil if absent
+	Certificate     *x509.Certificate // nil if the blob doesn't carry the signer's certificate
+}
+
+// Signatures decodes every PKCS#7 entry in the certificate table. Returns
+// nil, nil for an unsigned image.
+func (f *File) Signatures() ([]*Signature, error) {
+	entries, err := f.CertificateTable()
+	if err != nil {
+		return nil, err
+	}
+	var sigs []*Signature
+	for _, e := range entries {
+		if e.CertificateType != WIN_CERT_TYPE_PKCS_SIGNED_DATA {
+			continue
+		}
+		sig, err := ParseSignature(e.Data)
+		if err != nil {
+			return sigs, &FormatError{Op: "Authenticode signature", Offset: int64(e.Offset), Err: err}
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs, nil
+}
+
+// --- PKCS#7 structures (RFC 2315), only as far as Authenticode needs ---
+
+var (
+	oidSignedData         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidMessageDigest      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSpcIndirectDataObj = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 4}
+
+	digestAlgorithms = map[string]string{
+		"1.2.840.113549.2.5":     "MD5",
+		"1.3.14.3.2.26":          "SHA1",
+		"2.16.840.1.101.3.4.2.1": "SHA256",
+		"2.16.840.1.101.3.4.2.2": "SHA384",
+		"2.16.840.1.101.3.4.2.3": "SHA512",
+	}
+)
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      contentInfo                // SpcIndirectDataContent for Authenticode
+	Certificates     asn1.RawValue              `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue              `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo               `asn1:"set"`
+}
+
+type signerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     issuerAndSerial
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   []attribute `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthenticatedAttributes []attribute `asn1:"optional,tag:1"`
+}
+
+// spcIndirectDataContent is the content Authenticode signs: a description
+// of the image (ignored here) and the image hash.
+type spcIndirectDataContent struct {
+	Data          asn1.RawValue
+	MessageDigest digestInfo
+}
+
+type digestInfo struct {
+	DigestAlgorithm pkix.AlgorithmIdentifier
+	Digest          []byte
+}
+
+type issuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+// ParseSignature decodes a DER PKCS#7 SignedData blob as found in a
+// WIN_CERT_TYPE_PKCS_SIGNED_DATA entry. It does not verify the signature or
+// the certificate chain.
+func ParseSignature(der []byte) (*Signature, error) {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("%w: content type %s is not signedData", ErrInvalidSignature, ci.ContentType)
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("%w: SignedData: %v", ErrInvalidSignature, err)
+	}
+
+	sig := new(Signature)
+	if len(sd.DigestAlgorithms) > 0 {
+		sig.DigestAlgorithm = digestAlgorithmName(sd.DigestAlgorithms[0].Algorithm)
+	}
+	if sd.ContentInfo.ContentType.Equal(oidSpcIndirectDataObj) {
+		var indirect spcIndirectDataContent
+		if _, err := asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &indirect); err != nil {
+			return nil, fmt.Errorf("%w: SpcIndirectDataContent: %v", ErrInvalidSignature, err)
+		}
+		sig.DigestAlgorithm = digestAlgorithmName(indirect.MessageDigest.DigestAlgorithm.Algorithm)
+		sig.ImageDigest = indirect.MessageDigest.Digest
+
+		// The signer's messageDigest covers the SEQUENCE's contents, not
+		// its tag and length.
+		var seq asn1.RawValue
+		if _, err := asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &seq); err == nil {
+			sig.indirectData = seq.Bytes
+		}
+	}
+	if len(sd.Certificates.Bytes) > 0 {
+		certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: certificates: %v", ErrInvalidSignature, err)
+		}
+		sig.Certificates = certs
+	}
+
+	for _, si := range sd.SignerInfos {
+		var rdns pkix.RDNSequence
+		if _, err := asn1.Unmarshal(si.IssuerAndSerialNumber.Issuer.FullBytes, &rdns); err != nil {
+			return nil, fmt.Errorf("%w: signer issuer: %v", ErrInvalidSignature, err)
+		}
+		s := Signer{SerialNumber: si.IssuerAndSerialNumber.SerialNumber,
+			DigestAlgorithm: digestAlgorithmName(si.DigestAlgorithm.Algorithm)}
+		s.Issuer.FillFromRDNSequence(&rdns)
+		for _, attr := range si.AuthenticatedAttributes {
+			if attr.Type.Equal(oidMessageDigest) {
+				asn1.Unmarshal(attr.Values.Bytes, &s.MessageDigest)
+			}
+		}
+		for _, c := range sig.Certificates {
+			if c.SerialNumber.Cmp(s.SerialNumber) == 0 && bytes.Equal(c.RawIssuer, si.IssuerAndSerialNumber.Issuer.FullBytes) {
+				s.Certificate = c
+				break
+			}
+		}
+		sig.Signers = append(sig.Signers, s)
+	}
+	return sig, nil
+}
+
+// digestAlgorithmName returns a short name for a digest OID, or the dotted
+// OID itself if it isn't one Authenticode uses.
+func digestAlgorithmName(oid asn1.ObjectIdentifier) string {
+	if name, ok := digestAlgorithms[oid.String()]; ok {
+		return name
+	}
+	return oid.String()
+}
@@ -0,0 +1,128 @@
+package pe
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+// loadSignedEXE returns a small MinGW PE32 executable signed with an EV
+// certificate, taken from golang.org/x/sys/windows/testdata.
+func loadSignedEXE(t testing.TB) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/ev-signed-file.exe")
+	if err != nil {
+		t.Fatalf("read testdata: %v", err)
+	}
+	return data
+}
+
+func TestSignatures(t *testing.T) {
+	f, err := Parse(loadSignedEXE(t))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	entries, err := f.CertificateTable()
+	if err != nil {
+		t.Fatalf("CertificateTable: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Offset != 0x2A00 || entries[0].Revision != WIN_CERT_REVISION_2_0 ||
+		entries[0].CertificateType != WIN_CERT_TYPE_PKCS_SIGNED_DATA {
+		t.Fatalf("entries = %+v, want one PKCS#7 entry at 0x2A00", entries)
+	}
+
+	sigs, err := f.Signatures()
+	if err != nil || len(sigs) != 1 {
+		t.Fatalf("Signatures = %v, %v", sigs, err)
+	}
+	sig := sigs[0]
+	if sig.DigestAlgorithm != "SHA256" || len(sig.Certificates) != 2 || len(sig.Signers) != 1 {
+		t.Fatalf("signature = %s, %d certs, %d signers", sig.DigestAlgorithm, len(sig.Certificates), len(sig.Signers))
+	}
+	signer := sig.Signers[0]
+	if got := signer.SerialNumber.Text(16); got != "663d5fca728882f36ff1bdf5d85f0ba" {
+		t.Errorf("serial = %s", got)
+	}
+	if signer.Issuer.CommonName != "DigiCert EV Code Signing CA (SHA2)" || signer.DigestAlgorithm != "SHA256" {
+		t.Errorf("issuer = %q, digest %s", signer.Issuer.CommonName, signer.DigestAlgorithm)
+	}
+	if signer.Certificate == nil || signer.Certificate.Subject.CommonName != "WireGuard LLC" {
+		t.Fatalf("signer certificate = %v, want WireGuard LLC", signer.Certificate)
+	}
+	if got := signer.Certificate.NotAfter.Format("2006-01-02"); got != "2021-12-14" {
+		t.Errorf("NotAfter = %s, want 2021-12-14", got)
+	}
+}
+
+func TestSignaturesUnsigned(t *testing.T) {
+	f, err := Parse(loadCalcDLL(t))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if sigs, err := f.Signatures(); sigs != nil || err != nil {
+		t.Errorf("Signatures = %v, %v; want nil, nil", sigs, err)
+	}
+}
+
+func TestSignaturesMalformed(t *testing.T) {
+	const entry = 0x2A00
+	tests := []struct {
+		name   string
+		mutate func([]byte)
+		want   error
+	}{
+		{"length past table", func(b []byte) { b[entry+3] = 0x10 }, ErrInvalidCertificate},
+		{"length below header", func(b []byte) { copy(b[entry:], []byte{4, 0, 0, 0}) }, ErrInvalidCertificate},
+		{"corrupt PKCS#7", func(b []byte) { b[entry+8] = 0x05 }, ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := loadSignedEXE(t)
+			tt.mutate(data)
+			f, err := Parse(data)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if _, err := f.Signatures(); !errors.Is(err, tt.want) {
+				t.Errorf("Signatures error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyDigest(t *testing.T) {
+	tests := []struct {
+		name      string
+		mutate    func([]byte)
+		wantMatch bool
+	}{
+		{"as signed", func([]byte) {}, true},
+		{"checksum changed", func(b []byte) { b[0x80+4+20+64] ^= 0xFF }, true},
+		{"code patched", func(b []byte) { b[0x400+0x10] ^= 0xFF }, false},
+		{"last section patched", func(b []byte) { b[0x2A00-1] ^= 0xFF }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := loadSignedEXE(t)
+			tt.mutate(data)
+			f, err := Parse(data)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			sigs, err := f.Signatures()
+			if err != nil || len(sigs) != 1 {
+				t.Fatalf("Signatures = %v, %v", sigs, err)
+			}
+			v, err := f.VerifyDigest(sigs[0])
+			if err != nil {
+				t.Fatalf("VerifyDigest: %v", err)
+			}
+			if v.Match() != tt.wantMatch {
+				t.Errorf("Match = %v, want %v (signed %x, computed %x)", v.Match(), tt.wantMatch, v.SignedDigest, v.ComputedDigest)
+			}
+			if !v.MessageDigestValid {
+				t.Error("signer messageDigest does not cover SpcIndirectDataContent")
+			}
+		})
+	}
+}
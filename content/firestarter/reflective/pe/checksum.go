@@ -0,0 +1,64 @@
+package pe
+
+import "encoding/binary"
+
+// checksumOffset is the file offset of the optional header's CheckSum
+// field, which sits at the same place in PE32 and PE32+ headers.
+func (f *File) checksumOffset() int {
+	return int(f.DosHeader.Lfanew) + 4 + 20 + 64
+}
+
+// CheckSum returns the checksum stored in the optional header. Zero means
+// the linker didn't set one, which is normal for anything but drivers,
+// boot-time DLLs and DLLs loaded into critical system processes.
+func (f *File) CheckSum() uint32 {
+	if f.OptionalHeader32 != nil {
+		return f.OptionalHeader32.CheckSum
+	}
+	return f.OptionalHeader64.CheckSum
+}
+
+// ComputeChecksum computes the image checksum the way imagehlp's
+// CheckSumMappedFile does: the file is summed as little-endian 16-bit words
+// (an odd trailing byte is padded with zero) with the carry folded back in
+// after every add, the stored CheckSum is taken back out, and the file
+// length is added to the 16-bit result.
+func (f *File) ComputeChecksum() uint32 {
+	var sum uint32
+	n := len(f.data)
+	for i := 0; i+1 < n; i += 2 {
+		sum += uint32(binary.LittleEndian.Uint16(f.data[i:]))
+		sum = (sum & 0xFFFF) + (sum >> 16)
+	}
+	if n%2 != 0 {
+		sum += uint32(f.data[n-1])
+		sum = (sum & 0xFFFF) + (sum >> 16)
+	}
+	sum = (sum + (sum >> 16)) & 0xFFFF
+
+	// Subtracting the stored value's two halves with borrow is what
+	// CheckSumMappedFile does; it equals skipping the field when it is
+	// word aligned, and matches Windows when it isn't.
+	stored := f.CheckSum()
+	for _, half := range []uint32{stored & 0xFFFF, stored >> 16} {
+		if sum < half {
+			sum--
+		}
+		sum = (sum - half) & 0xFFFF
+	}
+	return sum + uint32(n)
+}
+
+// ChecksumValid reports whether the stored checksum matches the file.
+func (f *File) ChecksumValid() bool {
+	return f.CheckSum() == f.ComputeChecksum()
+}
+
+// WithFixedChecksum returns a copy of the file with the computed checksum
+// written into the optional header. The Authenticode digest skips CheckSum,
+// so fixing it leaves any signature valid.
+func (f *File) WithFixedChecksum() []byte {
+	fixed := append([]byte(nil), f.data...)
+	binary.LittleEndian.PutUint32(fixed[f.checksumOffset():], f.ComputeChecksum())
+	return fixed
+}
@@ -0,0 +1,79 @@
+package pe
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want uint32
+	}{
+		{"calc_dll.dll", loadCalcDLL(t), 0x1DD46},
+		{"ev-signed-file.exe", loadSignedEXE(t), 0x13037},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse(tt.data)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := f.ComputeChecksum(); got != tt.want {
+				t.Errorf("ComputeChecksum = 0x%X, want 0x%X", got, tt.want)
+			}
+			if f.CheckSum() != tt.want || !f.ChecksumValid() {
+				t.Errorf("stored CheckSum 0x%X, valid %v; want 0x%X, valid", f.CheckSum(), f.ChecksumValid(), tt.want)
+			}
+		})
+	}
+}
+
+// TestChecksumFix tampers with a file, including making its length odd, and
+// checks that the repaired copy validates and keeps its Authenticode digest.
+func TestChecksumFix(t *testing.T) {
+	data := append(loadSignedEXE(t), 0x41)
+	data[0x500] ^= 0xFF
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if f.ChecksumValid() {
+		t.Fatalf("tampered file still has a valid checksum 0x%X", f.CheckSum())
+	}
+
+	fixed, err := Parse(f.WithFixedChecksum())
+	if err != nil {
+		t.Fatalf("Parse fixed copy: %v", err)
+	}
+	if !fixed.ChecksumValid() || fixed.CheckSum() != f.ComputeChecksum() {
+		t.Errorf("fixed copy has CheckSum 0x%X (valid %v), want 0x%X", fixed.CheckSum(), fixed.ChecksumValid(), f.ComputeChecksum())
+	}
+	if !bytes.Equal(f.Bytes()[:f.checksumOffset()], fixed.Bytes()[:f.checksumOffset()]) ||
+		!bytes.Equal(f.Bytes()[f.checksumOffset()+4:], fixed.Bytes()[f.checksumOffset()+4:]) {
+		t.Errorf("WithFixedChecksum changed bytes outside the CheckSum field")
+	}
+	before, err := f.AuthenticodeDigest(crypto.SHA256)
+	if err != nil {
+		t.Fatalf("AuthenticodeDigest: %v", err)
+	}
+	after, err := fixed.AuthenticodeDigest(crypto.SHA256)
+	if err != nil {
+		t.Fatalf("AuthenticodeDigest of fixed copy: %v", err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Errorf("fixing the checksum changed the Authenticode digest")
+	}
+
+	// A zero checksum is "not set" and doesn't validate either.
+	unset := testImage{}.build(t)
+	f, err = Parse(unset)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if f.CheckSum() != 0 || f.ChecksumValid() {
+		t.Errorf("unset checksum: CheckSum 0x%X, valid %v", f.CheckSum(), f.ChecksumValid())
+	}
+}
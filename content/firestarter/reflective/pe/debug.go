@@ -0,0 +1,286 @@
+package pe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+type IMAGE_DEBUG_DIRECTORY struct { //nolint:revive // Windows struct
+	Characteristics  uint32
+	TimeDateStamp    uint32
+	MajorVersion     uint16
+	MinorVersion     uint16
+	Type             uint32 // IMAGE_DEBUG_TYPE_*
+	SizeOfData       uint32
+	AddressOfRawData uint32 // RVA of the data, 0 if it isn't mapped
+	PointerToRawData uint32 // File offset of the data
+}
+
+const (
+	IMAGE_DEBUG_TYPE_UNKNOWN               = 0  //nolint:revive // Windows constant
+	IMAGE_DEBUG_TYPE_COFF                  = 1  //nolint:revive // Windows constant
+	IMAGE_DEBUG_TYPE_CODEVIEW              = 2  //nolint:revive // Windows constant
+	IMAGE_DEBUG_TYPE_FPO                   = 3  //nolint:revive // Windows constant
+	IMAGE_DEBUG_TYPE_MISC                  = 4  //nolint:revive // Windows constant
+	IMAGE_DEBUG_TYPE_EXCEPTION             = 5  //nolint:revive // Windows constant
+	IMAGE_DEBUG_TYPE_FIXUP                 = 6  //nolint:revive // Windows constant
+	IMAGE_DEBUG_TYPE_OMAP_TO_SRC           = 7  //nolint:revive // Windows constant
+	IMAGE_DEBUG_TYPE_OMAP_FROM_SRC         = 8  //nolint:revive // Windows constant
+	IMAGE_DEBUG_TYPE_BORLAND               = 9  //nolint:revive // Windows constant
+	IMAGE_DEBUG_TYPE_CLSID                 = 11 //nolint:revive // Windows constant
+	IMAGE_DEBUG_TYPE_VC_FEATURE            = 12 //nolint:revive // Windows constant
+	IMAGE_DEBUG_TYPE_POGO                  = 13 //nolint:revive // Windows constant
+	IMAGE_DEBUG_TYPE_ILTCG                 = 14 //nolint:revive // Windows constant
+	IMAGE_DEBUG_TYPE_MPX                   = 15 //nolint:revive // Windows constant
+	IMAGE_DEBUG_TYPE_REPRO                 = 16 //nolint:revive // Windows constant
+	IMAGE_DEBUG_TYPE_EMBEDDED_PORTABLE_PDB = 17 //nolint:revive // Windows constant
+	IMAGE_DEBUG_TYPE_PDBCHECKSUM           = 19 //nolint:revive // Windows constant
+	IMAGE_DEBUG_TYPE_EX_DLLCHARACTERISTICS = 20 //nolint:revive // Windows constant
+)
+
+var debugTypeNames = map[uint32]string{
+	IMAGE_DEBUG_TYPE_UNKNOWN: "UNKNOWN", IMAGE_DEBUG_TYPE_COFF: "COFF", IMAGE_DEBUG_TYPE_CODEVIEW: "CODEVIEW",
+	IMAGE_DEBUG_TYPE_FPO: "FPO", IMAGE_DEBUG_TYPE_MISC: "MISC", IMAGE_DEBUG_TYPE_EXCEPTION: "EXCEPTION",
+	IMAGE_DEBUG_TYPE_FIXUP: "FIXUP", IMAGE_DEBUG_TYPE_OMAP_TO_SRC: "OMAP_TO_SRC",
+	IMAGE_DEBUG_TYPE_OMAP_FROM_SRC: "OMAP_FROM_SRC", IMAGE_DEBUG_TYPE_BORLAND: "BORLAND",
+	IMAGE_DEBUG_TYPE_CLSID: "CLSID", IMAGE_DEBUG_TYPE_VC_FEATURE: "VC_FEATURE", IMAGE_DEBUG_TYPE_POGO: "POGO",
+	IMAGE_DEBUG_TYPE_ILTCG: "ILTCG", IMAGE_DEBUG_TYPE_MPX: "MPX", IMAGE_DEBUG_TYPE_REPRO: "REPRO",
+	IMAGE_DEBUG_TYPE_EMBEDDED_PORTABLE_PDB: "EMBEDDED_PORTABLE_PDB", IMAGE_DEBUG_TYPE_PDBCHECKSUM: "PDBCHECKSUM",
+	IMAGE_DEBUG_TYPE_EX_DLLCHARACTERISTICS: "EX_DLLCHARACTERISTICS",
+}
+
+// DebugTypeToString returns the name of an IMAGE_DEBUG_TYPE_* value.
+func DebugTypeToString(t uint32) string {
+	if name, ok := debugTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%X", t)
+}
+
+// Extended DLL characteristics, carried in an EX_DLLCHARACTERISTICS debug
+// entry because the optional header field ran out of bits.
+const (
+	IMAGE_DLLCHARACTERISTICS_EX_CET_COMPAT                                 = 0x0001 //nolint:revive // Windows constant
+	IMAGE_DLLCHARACTERISTICS_EX_CET_COMPAT_STRICT_MODE                     = 0x0002 //nolint:revive // Windows constant
+	IMAGE_DLLCHARACTERISTICS_EX_CET_SET_CONTEXT_IP_VALIDATION_RELAXED_MODE = 0x0004 //nolint:revive // Windows constant
+	IMAGE_DLLCHARACTERISTICS_EX_CET_DYNAMIC_APIS_ALLOW_IN_PROC             = 0x0008 //nolint:revive // Windows constant
+	IMAGE_DLLCHARACTERISTICS_EX_FORWARD_CFI_COMPAT                         = 0x0040 //nolint:revive // Windows constant
+	IMAGE_DLLCHARACTERISTICS_EX_HOTPATCH_COMPATIBLE                        = 0x0080 //nolint:revive // Windows constant
+)
+
+var exDllCharacteristicNames = []flagName{
+	{IMAGE_DLLCHARACTERISTICS_EX_CET_COMPAT, "CET_COMPAT"},
+	{IMAGE_DLLCHARACTERISTICS_EX_CET_COMPAT_STRICT_MODE, "CET_COMPAT_STRICT_MODE"},
+	{IMAGE_DLLCHARACTERISTICS_EX_CET_SET_CONTEXT_IP_VALIDATION_RELAXED_MODE, "CET_SET_CONTEXT_IP_VALIDATION_RELAXED_MODE"},
+	{IMAGE_DLLCHARACTERISTICS_EX_CET_DYNAMIC_APIS_ALLOW_IN_PROC, "CET_DYNAMIC_APIS_ALLOW_IN_PROC"},
+	{IMAGE_DLLCHARACTERISTICS_EX_FORWARD_CFI_COMPAT, "FORWARD_CFI_COMPAT"},
+	{IMAGE_DLLCHARACTERISTICS_EX_HOTPATCH_COMPATIBLE, "HOTPATCH_COMPATIBLE"},
+}
+
+// ExDllCharacteristicsToStrings decodes an EX_DLLCHARACTERISTICS value.
+func ExDllCharacteristicsToStrings(characteristics uint32) []string {
+	return decodeFlags(characteristics, exDllCharacteristicNames)
+}
+
+// DebugEntry is one IMAGE_DEBUG_DIRECTORY with its data and, for the types
+// we understand, the decoded payload. At most one of the payload fields is
+// set, matching Type.
+type DebugEntry struct {
+	IMAGE_DEBUG_DIRECTORY
+	Data []byte // Raw payload, clamped to the file
+
+	CodeView             *CodeViewInfo
+	POGO                 *POGOInfo
+	VCFeature            *VCFeatureInfo
+	ReproHash            []byte // REPRO: the hash that replaces timestamps; may be empty
+	ExDllCharacteristics uint32 // EX_DLLCHARACTERISTICS flags
+}
+
+// CodeViewInfo points the debugger at the matching PDB. The GUID and age
+// must match the PDB exactly, and PDBPath is whatever path the linker wrote
+// it to, which often includes the build machine's user name and layout.
+type CodeViewInfo struct {
+	Signature string   // "RSDS" (PDB 7.0) or "NB10" (PDB 2.0)
+	GUID      [16]byte // RSDS only
+	Age       uint32
+	PDBPath   string
+}
+
+// GUIDString formats the RSDS GUID as {XXXXXXXX-XXXX-XXXX-XXXX-XXXXXXXXXXXX},
+// with the first three groups little-endian as Windows stores them.
+func (c *CodeViewInfo) GUIDString() string {
+	g := c.GUID
+	return fmt.Sprintf("{%08X-%04X-%04X-%X-%X}", binary.LittleEndian.Uint32(g[0:]),
+		binary.LittleEndian.Uint16(g[4:]), binary.LittleEndian.Uint16(g[6:]), g[8:10], g[10:16])
+}
+
+// POGOInfo lists the sections the profile-guided optimiser laid out.
+type POGOInfo struct {
+	Signature string // "PGU" (profile used), "PGI" (instrumented), "LTCG", ...
+	Entries   []POGOEntry
+}
+
+type POGOEntry struct {
+	RVA  uint32
+	Size uint32
+	Name string // e.g. ".text$mn", ".rdata$zzzdbg"
+}
+
+// VCFeatureInfo counts objects built with each MSVC security feature.
+type VCFeatureInfo struct {
+	PreVC11 uint32 // Objects built by compilers older than VC++ 11
+	CCpp    uint32 // C/C++ objects
+	GS      uint32 // Objects built with /GS
+	SDL     uint32 // Objects built with /sdl
+	GuardN  uint32 // Objects built with /guard:cf
+}
+
+// DebugDirectory decodes every IMAGE_DEBUG_DIRECTORY entry. Returns nil, nil
+// if the image has no debug directory.
+func (f *File) DebugDirectory() ([]DebugEntry, error) {
+	dir := f.DataDirectory(IMAGE_DIRECTORY_ENTRY_DEBUG)
+	if dir.VirtualAddress == 0 || dir.Size == 0 {
+		return nil, nil
+	}
+	entrySize := uint32(binary.Size(IMAGE_DEBUG_DIRECTORY{}))
+	count := dir.Size / entrySize
+	if _, err := f.tableAtRVA(dir.VirtualAddress, count, entrySize); err != nil {
+		return nil, err
+	}
+
+	entries := make([]DebugEntry, 0, count)
+	for i := uint32(0); i < count; i++ {
+		var e DebugEntry
+		if err := f.structAtRVA(dir.VirtualAddress+i*entrySize, &e.IMAGE_DEBUG_DIRECTORY); err != nil {
+			return entries, err
+		}
+		e.Data = f.debugData(e.IMAGE_DEBUG_DIRECTORY)
+		if err := e.decode(); err != nil {
+			return entries, &FormatError{Op: fmt.Sprintf("debug entry %d (%s)", i, DebugTypeToString(e.Type)), Offset: int64(e.PointerToRawData), Err: err}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// IsReproducible reports whether the image was linked with /Brepro. Such
+// builds replace every TimeDateStamp with a content hash, so the dates they
+// decode to are meaningless.
+func (f *File) IsReproducible() bool {
+	entries, _ := f.DebugDirectory()
+	for _, e := range entries {
+		if e.Type == IMAGE_DEBUG_TYPE_REPRO {
+			return true
+		}
+	}
+	return false
+}
+
+// debugData returns an entry's payload, preferring the file offset and
+// falling back to the RVA for images whose PointerToRawData is zero.
+func (f *File) debugData(d IMAGE_DEBUG_DIRECTORY) []byte {
+	if d.SizeOfData == 0 {
+		return nil
+	}
+	if d.PointerToRawData != 0 {
+		start := uint64(d.PointerToRawData)
+		end := start + uint64(d.SizeOfData)
+		if start >= uint64(len(f.data)) {
+			return nil
+		}
+		if end > uint64(len(f.data)) {
+			end = uint64(len(f.data))
+		}
+		return f.data[start:end]
+	}
+	b, _ := f.bytesAtRVA(d.AddressOfRawData, d.SizeOfData)
+	return b
+}
+
+// decode fills in the payload field for Type.
+func (e *DebugEntry) decode() error {
+	switch e.Type {
+	case IMAGE_DEBUG_TYPE_CODEVIEW:
+		return e.decodeCodeView()
+	case IMAGE_DEBUG_TYPE_POGO:
+		return e.decodePOGO()
+	case IMAGE_DEBUG_TYPE_VC_FEATURE:
+		if len(e.Data) < 20 {
+			return ErrTruncated
+		}
+		e.VCFeature = new(VCFeatureInfo)
+		binary.Read(bytes.NewReader(e.Data), binary.LittleEndian, e.VCFeature)
+	case IMAGE_DEBUG_TYPE_REPRO:
+		// Either empty, or a length-prefixed hash.
+		if len(e.Data) >= 4 {
+			n := uint64(binary.LittleEndian.Uint32(e.Data))
+			if n > uint64(len(e.Data)-4) {
+				return ErrTruncated
+			}
+			e.ReproHash = e.Data[4 : 4+n]
+		}
+	case IMAGE_DEBUG_TYPE_EX_DLLCHARACTERISTICS:
+		if len(e.Data) < 4 {
+			return ErrTruncated
+		}
+		e.ExDllCharacteristics = binary.LittleEndian.Uint32(e.Data)
+	}
+	return nil
+}
+
+func (e *DebugEntry) decodeCodeView() error {
+	if len(e.Data) < 4 {
+		return ErrTruncated
+	}
+	cv := &CodeViewInfo{Signature: string(e.Data[:4])}
+	var path []byte
+	switch cv.Signature {
+	case "RSDS": // Signature, GUID, Age, PdbFileName
+		if len(e.Data) < 24 {
+			return ErrTruncated
+		}
+		copy(cv.GUID[:], e.Data[4:20])
+		cv.Age = binary.LittleEndian.Uint32(e.Data[20:])
+		path = e.Data[24:]
+	case "NB10": // Signature, Offset, TimeDateStamp, Age, PdbFileName
+		if len(e.Data) < 16 {
+			return ErrTruncated
+		}
+		cv.Age = binary.LittleEndian.Uint32(e.Data[12:])
+		path = e.Data[16:]
+	default:
+		// Unknown CodeView format: keep the signature, nothing else.
+		e.CodeView = cv
+		return nil
+	}
+	if n := bytes.IndexByte(path, 0); n != -1 {
+		path = path[:n]
+	}
+	cv.PDBPath = string(path)
+	e.CodeView = cv
+	return nil
+}
+
+func (e *DebugEntry) decodePOGO() error {
+	if len(e.Data) < 4 {
+		return ErrTruncated
+	}
+	// The signature is stored as a little-endian dword, so "PGU\0" reads
+	// back as "\0UGP"; reverse it for display.
+	sig := e.Data[:4]
+	p := &POGOInfo{Signature: string(bytes.TrimRight([]byte{sig[3], sig[2], sig[1], sig[0]}, "\x00"))}
+	for off := 4; off+8 < len(e.Data); {
+		entry := POGOEntry{RVA: binary.LittleEndian.Uint32(e.Data[off:]), Size: binary.LittleEndian.Uint32(e.Data[off+4:])}
+		name := e.Data[off+8:]
+		n := bytes.IndexByte(name, 0)
+		if n == -1 {
+			break // Truncated final name
+		}
+		entry.Name = string(name[:n])
+		p.Entries = append(p.Entries, entry)
+		off = align4(off + 8 + n + 1)
+	}
+	e.POGO = p
+	return nil
+}
@@ -0,0 +1,121 @@
+package pe
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDebugDirectorySignedEXE(t *testing.T) {
+	f, err := Parse(loadSignedEXE(t))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	entries, err := f.DebugDirectory()
+	if err != nil {
+		t.Fatalf("DebugDirectory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.Type != IMAGE_DEBUG_TYPE_CODEVIEW || e.TimeDateStamp != 0x619D1EB2 || e.PointerToRawData != 0x201C {
+		t.Errorf("entry = %+v", e.IMAGE_DEBUG_DIRECTORY)
+	}
+	cv := e.CodeView
+	if cv == nil || cv.Signature != "RSDS" || cv.Age != 1 || cv.PDBPath != "" {
+		t.Fatalf("CodeView = %+v", cv)
+	}
+	if got, want := cv.GUIDString(), "{CCD4318E-CCB9-D15E-4C4C-44205044422E}"; got != want {
+		t.Errorf("GUID = %s, want %s", got, want)
+	}
+	if f.IsReproducible() {
+		t.Error("IsReproducible = true")
+	}
+}
+
+// debugImage lays out one IMAGE_DEBUG_DIRECTORY per payload at the start of
+// .rdata (RVA 0x2000, raw 0x600), with the payloads following it. The last
+// entry leaves PointerToRawData zero so it has to be found by RVA.
+func debugImage(t *testing.T, types []uint32, payloads [][]byte) *File {
+	t.Helper()
+	const rva, raw = 0x2000, 0x600
+	rdata := make([]byte, 0x400)
+	off := uint32(len(types) * 28)
+	for i, p := range payloads {
+		d := IMAGE_DEBUG_DIRECTORY{TimeDateStamp: 0xDEADBEEF, Type: types[i], SizeOfData: uint32(len(p)),
+			AddressOfRawData: rva + off, PointerToRawData: raw + off}
+		if i == len(payloads)-1 {
+			d.PointerToRawData = 0
+		}
+		copy(rdata[i*28:], encode(t, d))
+		copy(rdata[off:], p)
+		off = uint32(align4(int(off) + len(p)))
+	}
+	return testImage{
+		is64:        true,
+		sections:    withText(newSection(".rdata", rva, uint32(len(rdata)), raw, uint32(len(rdata)), 0x40000040)),
+		directories: map[int]IMAGE_DATA_DIRECTORY{IMAGE_DIRECTORY_ENTRY_DEBUG: {VirtualAddress: rva, Size: uint32(len(types) * 28)}},
+		sectionData: map[int][]byte{1: rdata},
+	}.parse(t)
+}
+
+func TestDebugDirectory(t *testing.T) {
+	pdb := `C:\Users\builder\source\repos\calc_dll\x64\Release\calc_dll.pdb`
+	guid := []byte{0x78, 0x56, 0x34, 0x12, 0xBC, 0x9A, 0xF0, 0xDE, 1, 2, 3, 4, 5, 6, 7, 8}
+	codeView := append(append(append([]byte("RSDS"), guid...), encode(t, []uint32{3})...), pdb+"\x00"...)
+	pogo := append(encode(t, []uint32{0x50475500, 0x1000, 0x80}), ".text$mn\x00\x00\x00\x00"...)
+	pogo = append(append(pogo, encode(t, []uint32{0x2000, 0x10})...), ".rdata\x00"...)
+	repro := append(encode(t, []uint32{4}), 0xAA, 0xBB, 0xCC, 0xDD)
+
+	f := debugImage(t,
+		[]uint32{IMAGE_DEBUG_TYPE_CODEVIEW, IMAGE_DEBUG_TYPE_POGO, IMAGE_DEBUG_TYPE_VC_FEATURE,
+			IMAGE_DEBUG_TYPE_REPRO, IMAGE_DEBUG_TYPE_EX_DLLCHARACTERISTICS},
+		[][]byte{codeView, pogo, encode(t, []uint32{0, 12, 12, 3, 0}), repro, encode(t, []uint32{IMAGE_DLLCHARACTERISTICS_EX_CET_COMPAT})})
+
+	entries, err := f.DebugDirectory()
+	if err != nil {
+		t.Fatalf("DebugDirectory: %v", err)
+	}
+	if len(entries) != 5 {
+		t.Fatalf("got %d entries, want 5", len(entries))
+	}
+
+	cv := entries[0].CodeView
+	if cv == nil || cv.Age != 3 || cv.PDBPath != pdb {
+		t.Fatalf("CodeView = %+v", cv)
+	}
+	if got, want := cv.GUIDString(), "{12345678-9ABC-DEF0-0102-030405060708}"; got != want {
+		t.Errorf("GUID = %s, want %s", got, want)
+	}
+
+	wantPOGO := &POGOInfo{Signature: "PGU", Entries: []POGOEntry{{0x1000, 0x80, ".text$mn"}, {0x2000, 0x10, ".rdata"}}}
+	if !reflect.DeepEqual(entries[1].POGO, wantPOGO) {
+		t.Errorf("POGO = %+v, want %+v", entries[1].POGO, wantPOGO)
+	}
+	if vc := entries[2].VCFeature; vc == nil || *vc != (VCFeatureInfo{CCpp: 12, GS: 12, SDL: 3}) {
+		t.Errorf("VCFeature = %+v", vc)
+	}
+	if !bytes.Equal(entries[3].ReproHash, []byte{0xAA, 0xBB, 0xCC, 0xDD}) {
+		t.Errorf("ReproHash = %X", entries[3].ReproHash)
+	}
+	if got := ExDllCharacteristicsToStrings(entries[4].ExDllCharacteristics); !reflect.DeepEqual(got, []string{"CET_COMPAT"}) {
+		t.Errorf("ExDllCharacteristics = %v", got)
+	}
+	if !f.IsReproducible() {
+		t.Error("IsReproducible = false")
+	}
+}
+
+func TestDebugDirectoryMalformed(t *testing.T) {
+	f := debugImage(t, []uint32{IMAGE_DEBUG_TYPE_CODEVIEW}, [][]byte{[]byte("RSDS\x01\x02")})
+	if _, err := f.DebugDirectory(); !errors.Is(err, ErrTruncated) {
+		t.Errorf("truncated RSDS: err = %v, want ErrTruncated", err)
+	}
+
+	f = debugImage(t, []uint32{IMAGE_DEBUG_TYPE_REPRO}, [][]byte{encode(t, []uint32{0x1000})})
+	if _, err := f.DebugDirectory(); !errors.Is(err, ErrTruncated) {
+		t.Errorf("oversized REPRO length: err = %v, want ErrTruncated", err)
+	}
+}
@@ -0,0 +1,88 @@
+package pe
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// IMAGE_DELAYLOAD_DESCRIPTOR is one entry of the delay-load import directory
+// (ImgDelayDescr in delayimp.h). The table ends at the first descriptor
+// without a DLL name.
+type IMAGE_DELAYLOAD_DESCRIPTOR struct { //nolint:revive // Windows struct
+	Attributes                 uint32 // IMAGE_DELAYLOAD_RVA_BASED when the fields below are RVAs
+	DllNameRVA                 uint32
+	ModuleHandleRVA            uint32 // HMODULE slot the helper fills on first use
+	ImportAddressTableRVA      uint32 // Slots start out pointing at the __delayLoadHelper2 thunks
+	ImportNameTableRVA         uint32 // Same layout as an import lookup table
+	BoundImportAddressTableRVA uint32
+	UnloadInformationTableRVA  uint32
+	TimeDateStamp              uint32
+}
+
+// IMAGE_DELAYLOAD_RVA_BASED is the only defined Attributes bit. Images from
+// Visual C++ 6 leave it clear and store virtual addresses instead.
+const IMAGE_DELAYLOAD_RVA_BASED = 0x1 //nolint:revive // Windows constant
+
+// DelayImportedDLL is one delay-load descriptor with the functions it
+// imports. The descriptor's addresses are always RVAs here, converted from
+// VAs for old VA-based descriptors. Each function's ThunkRVA is its slot in
+// the delay-load IAT.
+type DelayImportedDLL struct {
+	Name       string
+	Descriptor IMAGE_DELAYLOAD_DESCRIPTOR
+	RVABased   bool // Attributes had IMAGE_DELAYLOAD_RVA_BASED set
+	Functions  []ImportedFunction
+}
+
+// DelayImports walks the delay-load import directory (DataDirectory[13]).
+// Nothing in it is resolved by the Windows loader: each IAT slot points at
+// a stub that calls __delayLoadHelper2, which loads the DLL and patches the
+// slot on the first call.
+func (f *File) DelayImports() ([]DelayImportedDLL, error) {
+	dir := f.DataDirectory(IMAGE_DIRECTORY_ENTRY_DELAY_IMPORT)
+	if dir.VirtualAddress == 0 {
+		return nil, nil
+	}
+
+	var dlls []DelayImportedDLL
+	descriptorSize := uint32(binary.Size(IMAGE_DELAYLOAD_DESCRIPTOR{}))
+	for rva := dir.VirtualAddress; ; rva += descriptorSize {
+		var desc IMAGE_DELAYLOAD_DESCRIPTOR
+		if err := f.structAtRVA(rva, &desc); err != nil {
+			return dlls, err
+		}
+		if desc.DllNameRVA == 0 {
+			break
+		}
+
+		// VA-based descriptors hold VAs in the descriptor and in the name
+		// table's by-name thunks alike.
+		var base uint64
+		rvaBased := desc.Attributes&IMAGE_DELAYLOAD_RVA_BASED != 0
+		if !rvaBased {
+			base = f.ImageBase()
+			for _, field := range []*uint32{&desc.DllNameRVA, &desc.ModuleHandleRVA, &desc.ImportAddressTableRVA,
+				&desc.ImportNameTableRVA, &desc.BoundImportAddressTableRVA, &desc.UnloadInformationTableRVA} {
+				if *field != 0 {
+					*field = uint32(uint64(*field) - base)
+				}
+			}
+		}
+
+		name, err := f.stringAtRVA(desc.DllNameRVA)
+		if err != nil {
+			return dlls, err
+		}
+		dll := DelayImportedDLL{Name: name, Descriptor: desc, RVABased: rvaBased}
+		if desc.ImportNameTableRVA == 0 || desc.ImportAddressTableRVA == 0 {
+			dlls = append(dlls, dll)
+			return dlls, &FormatError{Op: fmt.Sprintf("delay-load descriptor for %s", name), Offset: -1, Err: ErrInvalidRVA}
+		}
+		dll.Functions, err = f.readThunks(desc.ImportNameTableRVA, desc.ImportAddressTableRVA, base)
+		dlls = append(dlls, dll)
+		if err != nil {
+			return dlls, err
+		}
+	}
+	return dlls, nil
+}
@@ -0,0 +1,97 @@
+package pe
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// delayImage builds a PE32 image (ImageBase 0x10000000) with one RVA-based
+// delay-load descriptor for USER32.dll, importing one function by name and
+// one by ordinal, and one VA-based descriptor for OLD.dll.
+func delayImage(t *testing.T, mutate func(put32 func(off int, v uint32))) *File {
+	t.Helper()
+	const rva, raw, base = 0x1000, 0x400, 0x10000000
+	data := make([]byte, 0x200)
+	put32 := func(off int, v uint32) { binary.LittleEndian.PutUint32(data[off:], v) }
+
+	// Descriptors at 0x00 and 0x20, terminator at 0x40.
+	put32(0x00, IMAGE_DELAYLOAD_RVA_BASED)
+	put32(0x04, rva+0xE0)  // DllNameRVA
+	put32(0x08, rva+0x100) // ModuleHandleRVA
+	put32(0x0C, rva+0x90)  // ImportAddressTableRVA
+	put32(0x10, rva+0x80)  // ImportNameTableRVA
+	put32(0x24, base+rva+0xF0)
+	put32(0x28, base+rva+0x108)
+	put32(0x2C, base+rva+0xA8)
+	put32(0x30, base+rva+0xA0)
+
+	put32(0x80, rva+0xC0) // USER32 name table
+	put32(0x84, IMAGE_ORDINAL_FLAG32|5)
+	put32(0xA0, base+rva+0xD0) // OLD name table, by VA
+	binary.LittleEndian.PutUint16(data[0xC0:], 1)
+	copy(data[0xC2:], "MessageBoxA\x00")
+	binary.LittleEndian.PutUint16(data[0xD0:], 2)
+	copy(data[0xD2:], "Legacy\x00")
+	copy(data[0xE0:], "USER32.dll\x00")
+	copy(data[0xF0:], "OLD.dll\x00")
+	if mutate != nil {
+		mutate(put32)
+	}
+
+	return testImage{
+		sections:    []IMAGE_SECTION_HEADER{newSection(".didat", rva, 0x200, raw, 0x200, 0xC0000040)},
+		directories: map[int]IMAGE_DATA_DIRECTORY{IMAGE_DIRECTORY_ENTRY_DELAY_IMPORT: {VirtualAddress: rva, Size: 0x60}},
+		sectionData: map[int][]byte{0: data},
+	}.parse(t)
+}
+
+func TestDelayImports(t *testing.T) {
+	dlls, err := delayImage(t, nil).DelayImports()
+	if err != nil {
+		t.Fatalf("DelayImports: %v", err)
+	}
+	if len(dlls) != 2 {
+		t.Fatalf("got %d delay-load DLLs, want 2: %+v", len(dlls), dlls)
+	}
+
+	user32 := dlls[0]
+	if user32.Name != "USER32.dll" || !user32.RVABased || user32.Descriptor.ModuleHandleRVA != 0x1100 {
+		t.Errorf("dlls[0] = %+v, want RVA-based USER32.dll with module handle at 0x1100", user32)
+	}
+	if len(user32.Functions) != 2 {
+		t.Fatalf("USER32.dll has %d functions, want 2", len(user32.Functions))
+	}
+	if fn := user32.Functions[0]; fn.Name != "MessageBoxA" || fn.Hint != 1 || fn.ThunkRVA != 0x1090 {
+		t.Errorf("USER32 function 0 = %+v, want MessageBoxA hint 1 at IAT 0x1090", fn)
+	}
+	if fn := user32.Functions[1]; !fn.ByOrdinal || fn.Ordinal != 5 || fn.ThunkRVA != 0x1094 {
+		t.Errorf("USER32 function 1 = %+v, want ordinal 5 at IAT 0x1094", fn)
+	}
+
+	old := dlls[1]
+	if old.Name != "OLD.dll" || old.RVABased || old.Descriptor.ImportAddressTableRVA != 0x10A8 {
+		t.Errorf("dlls[1] = %+v, want VA-based OLD.dll with its IAT converted to RVA 0x10A8", old)
+	}
+	if len(old.Functions) != 1 || old.Functions[0].Name != "Legacy" || old.Functions[0].ThunkRVA != 0x10A8 {
+		t.Errorf("OLD.dll functions = %+v, want Legacy at IAT 0x10A8", old.Functions)
+	}
+}
+
+func TestDelayImportsMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(put32 func(off int, v uint32))
+	}{
+		{"missing name table", func(put32 func(int, uint32)) { put32(0x30, 0) }},
+		{"name table outside image", func(put32 func(int, uint32)) { put32(0x10, 0x9000) }},
+		{"name outside image", func(put32 func(int, uint32)) { put32(0x24, 0x10009000) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := delayImage(t, tt.mutate).DelayImports(); !errors.Is(err, ErrInvalidRVA) {
+				t.Errorf("DelayImports error = %v, want ErrInvalidRVA", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package pe
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/md5" // Register the hashes Authenticode signatures may name
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"fmt"
+	"sort"
+)
+
+// digestHashes maps the names returned by digestAlgorithmName to hashes.
+var digestHashes = map[string]crypto.Hash{
+	"MD5":    crypto.MD5,
+	"SHA1":   crypto.SHA1,
+	"SHA256": crypto.SHA256,
+	"SHA384": crypto.SHA384,
+	"SHA512": crypto.SHA512,
+}
+
+// AuthenticodeDigest computes the Authenticode image hash of the file, as
+// described in "Windows Authenticode Portable Executable Signature Format":
+//
+//  1. Hash the headers up to SizeOfHeaders, skipping the optional header
+//     CheckSum and the Security data directory entry, since signing
+//     changes both.
+//  2. Hash each section's raw data in file order (sorted by
+//     PointerToRawData).
+//  3. Hash whatever follows the last section, up to the certificate table.
+//
+// The certificate table itself is never hashed; it holds the signature.
+func (f *File) AuthenticodeDigest(h crypto.Hash) ([]byte, error) {
+	if !h.Available() {
+		return nil, fmt.Errorf("pe: hash %v not available", h)
+	}
+	optionalHeaderOffset := uint64(f.DosHeader.Lfanew) + 4 + 20
+	checksumOffset := optionalHeaderOffset + 64
+	securityEntryOffset := optionalHeaderOffset + 96 + IMAGE_DIRECTORY_ENTRY_SECURITY*8
+	if f.Is64() {
+		securityEntryOffset += 16 // ImageBase and the stack/heap fields are 8 bytes wide
+	}
+	headersEnd := uint64(f.SizeOfHeaders())
+	if securityEntryOffset+8 > headersEnd || uint64(f.NumberOfRvaAndSizes()) <= IMAGE_DIRECTORY_ENTRY_SECURITY {
+		return nil, &FormatError{Op: "Authenticode digest", Offset: int64(securityEntryOffset), Err: ErrTruncated}
+	}
+
+	d := h.New()
+	d.Write(f.data[:checksumOffset])
+	d.Write(f.data[checksumOffset+4 : securityEntryOffset])
+	d.Write(f.data[securityEntryOffset+8 : headersEnd])
+
+	sections := append([]*Section(nil), f.Sections...)
+	sort.SliceStable(sections, func(i, j int) bool { return sections[i].PointerToRawData < sections[j].PointerToRawData })
+	hashed := headersEnd
+	for _, s := range sections {
+		if s.SizeOfRawData == 0 {
+			continue
+		}
+		d.Write(f.SectionData(s))
+		hashed += uint64(s.SizeOfRawData)
+	}
+
+	// Trailing data: everything past the bytes hashed so far, stopping at
+	// the certificate table when there is one.
+	end := uint64(len(f.data))
+	if cert := f.DataDirectory(IMAGE_DIRECTORY_ENTRY_SECURITY); cert.Size != 0 && uint64(cert.VirtualAddress) < end {
+		end = uint64(cert.VirtualAddress)
+	}
+	if hashed < end {
+		d.Write(f.data[hashed:end])
+	}
+	return d.Sum(nil), nil
+}
+
+// DigestVerification compares the image hash a signature vouches for with
+// the hash of the file as it is now.
+type DigestVerification struct {
+	Algorithm      string
+	SignedDigest   []byte // From the signature's SpcIndirectDataContent
+	ComputedDigest []byte // Recomputed over the file
+
+	// MessageDigestValid reports whether every signer's messageDigest
+	// attribute matches the signed SpcIndirectDataContent, i.e. the digest
+	// above is the one the signer actually signed.
+	MessageDigestValid bool
+}
+
+// Match reports whether the file still hashes to the signed digest. A
+// mismatch means the image was modified after it was signed.
+func (v *DigestVerification) Match() bool {
+	return len(v.SignedDigest) > 0 && bytes.Equal(v.SignedDigest, v.ComputedDigest)
+}
+
+// VerifyDigest recomputes the image hash with the signature's algorithm and
+// compares it with the signed one. It checks integrity only: the PKCS#7
+// signature and the certificate chain are not verified.
+func (f *File) VerifyDigest(sig *Signature) (*DigestVerification, error) {
+	h, ok := digestHashes[sig.DigestAlgorithm]
+	if !ok {
+		return nil, fmt.Errorf("pe: unsupported Authenticode digest algorithm %s", sig.DigestAlgorithm)
+	}
+	if sig.ImageDigest == nil {
+		return nil, fmt.Errorf("%w: no SpcIndirectDataContent", ErrInvalidSignature)
+	}
+	computed, err := f.AuthenticodeDigest(h)
+	if err != nil {
+		return nil, err
+	}
+	v := &DigestVerification{Algorithm: sig.DigestAlgorithm, SignedDigest: sig.ImageDigest, ComputedDigest: computed}
+
+	v.MessageDigestValid = len(sig.Signers) > 0
+	for _, s := range sig.Signers {
+		sh, ok := digestHashes[s.DigestAlgorithm]
+		if !ok || s.MessageDigest == nil {
+			v.MessageDigestValid = false
+			continue
+		}
+		d := sh.New()
+		d.Write(sig.indirectData)
+		if !bytes.Equal(d.Sum(nil), s.MessageDigest) {
+			v.MessageDigestValid = false
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,62 @@
+package pe
+
+import "math"
+
+// Entropy returns the Shannon entropy of data in bits per byte, from 0
+// (a single repeated value) to 8 (uniformly random). Compressed or encrypted
+// data sits close to 8; code and plain data usually fall between 4 and 6.5.
+func Entropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+	var h float64
+	n := float64(len(data))
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// EntropyWindow is one sample of a sliding-window entropy profile.
+type EntropyWindow struct {
+	Offset  uint32  // Offset of the window in the data
+	Size    uint32  // Bytes in the window; short only when data is smaller than one window
+	Entropy float64 // Bits per byte
+}
+
+// EntropyProfile slides a window of window bytes across data in steps of
+// step bytes and returns the entropy of each position. If the last step
+// leaves a tail uncovered, a final window is aligned to the end of data, so
+// every byte is covered and every sample has the same size. Data shorter than
+// one window yields a single sample. A step larger than the window would skip
+// bytes and returns nil, like a zero window or step.
+//
+// Short windows cap the entropy they can show: 256 bytes can reach at most
+// 8 bits only if every byte value occurs exactly once, so compare profiles
+// taken with the same window size.
+func EntropyProfile(data []byte, window, step int) []EntropyWindow {
+	if window <= 0 || step <= 0 || step > window || len(data) == 0 {
+		return nil
+	}
+	if len(data) <= window {
+		return []EntropyWindow{{Offset: 0, Size: uint32(len(data)), Entropy: Entropy(data)}}
+	}
+	var profile []EntropyWindow
+	off := 0
+	for ; off+window <= len(data); off += step {
+		profile = append(profile, EntropyWindow{Offset: uint32(off), Size: uint32(window), Entropy: Entropy(data[off : off+window])})
+	}
+	if off-step+window < len(data) {
+		last := len(data) - window
+		profile = append(profile, EntropyWindow{Offset: uint32(last), Size: uint32(window), Entropy: Entropy(data[last:])})
+	}
+	return profile
+}
@@ -0,0 +1,71 @@
+package pe
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEntropy(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want float64
+	}{
+		{"empty", nil, 0},
+		{"zeros", make([]byte, 100), 0},
+		{"two values", []byte("abababab"), 1},
+		{"uniform", allBytes(256), 8},
+	}
+	for _, tt := range tests {
+		if got := Entropy(tt.data); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: Entropy = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// allBytes returns n bytes cycling through every byte value, which has the
+// maximum entropy of 8 bits per byte for any multiple of 256.
+func allBytes(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
+
+func TestEntropyProfile(t *testing.T) {
+	// 512 zero bytes followed by 498 high-entropy ones.
+	data := append(make([]byte, 512), allBytes(498)...)
+	profile := EntropyProfile(data, 256, 128)
+
+	// The last window is pulled back to end at the end of the data.
+	wantOffsets := []uint32{0, 128, 256, 384, 512, 640, 754}
+	if len(profile) != len(wantOffsets) {
+		t.Fatalf("got %d windows, want %d: %+v", len(profile), len(wantOffsets), profile)
+	}
+	for i, w := range profile {
+		if w.Offset != wantOffsets[i] || w.Size != 256 {
+			t.Errorf("window %d at offset %d size %d, want offset %d size 256", i, w.Offset, w.Size, wantOffsets[i])
+		}
+	}
+	for i, w := range profile[:3] {
+		if w.Entropy != 0 {
+			t.Errorf("window %d over the zero run has entropy %v, want 0", i, w.Entropy)
+		}
+	}
+	if e := profile[3].Entropy; e <= 0 || e >= 8 {
+		t.Errorf("window straddling both halves has entropy %v, want between 0 and 8", e)
+	}
+	for i, w := range profile[4:] {
+		if w.Entropy != 8 {
+			t.Errorf("window %d over the high-entropy half has entropy %v, want 8", i+4, w.Entropy)
+		}
+	}
+
+	if short := EntropyProfile([]byte("abab"), 256, 128); len(short) != 1 || short[0].Size != 4 || short[0].Entropy != 1 {
+		t.Errorf("profile of data shorter than a window = %+v, want one 4-byte window", short)
+	}
+	if EntropyProfile(data, 0, 128) != nil || EntropyProfile(data, 256, 0) != nil || EntropyProfile(data, 256, 512) != nil {
+		t.Errorf("EntropyProfile with a zero window or step, or a step past the window, should return nil")
+	}
+}
@@ -0,0 +1,51 @@
+package pe
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors describing why an image was rejected. Parse never returns
+// these directly; they are wrapped in a *FormatError, so test for them with
+// errors.Is.
+var (
+	ErrTruncated           = errors.New("data truncated")
+	ErrInvalidDOSSignature = errors.New("invalid DOS signature")
+	ErrInvalidNTSignature  = errors.New("invalid PE signature")
+	ErrNoOptionalHeader    = errors.New("optional header size is zero")
+	ErrUnsupportedMagic    = errors.New("unsupported optional header magic")
+	ErrInvalidRVA          = errors.New("RVA not backed by file data")
+	ErrInvalidLfanew       = errors.New("e_lfanew outside file")
+	ErrTooManySections     = errors.New("too many sections")
+	ErrSectionOverlap      = errors.New("section overlaps another section")
+	ErrSectionPastEOF      = errors.New("section raw data extends past end of file")
+	ErrSizeOfImage         = errors.New("SizeOfImage out of range")
+	ErrInvalidRichHeader   = errors.New("malformed Rich header")
+	ErrInvalidCertificate  = errors.New("malformed WIN_CERTIFICATE")
+	ErrInvalidSignature    = errors.New("malformed Authenticode signature")
+	ErrResourceLoop        = errors.New("resource directory loop")
+	ErrTooManyResources    = errors.New("too many resource directory entries")
+	ErrResourceNotFound    = errors.New("resource not found")
+	ErrInvalidVersionInfo  = errors.New("malformed VS_VERSIONINFO")
+	ErrInvalidManifest     = errors.New("malformed manifest")
+	ErrUnsupportedMachine  = errors.New("unsupported machine type")
+	ErrInvalidUnwindInfo   = errors.New("malformed UNWIND_INFO")
+	ErrTLSHInput           = errors.New("no TLSH digest")
+)
+
+// FormatError reports a structural problem found while parsing a PE image:
+// which structure was being read, where in the file, and why it failed.
+type FormatError struct {
+	Op     string // Structure being parsed, e.g. "DOS header"
+	Offset int64  // File offset at which the problem was found, or -1 if it has none
+	Err    error  // Underlying cause
+}
+
+func (e *FormatError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("pe: %s: %v", e.Op, e.Err)
+	}
+	return fmt.Sprintf("pe: %s at offset 0x%X: %v", e.Op, e.Offset, e.Err)
+}
+
+func (e *FormatError) Unwrap() error { return e.Err }
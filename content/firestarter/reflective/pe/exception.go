@@ -0,0 +1,322 @@
+package pe
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// IMAGE_RUNTIME_FUNCTION_ENTRY is one x64 .pdata entry: the range of a
+// non-leaf function and the UNWIND_INFO describing its prolog.
+type IMAGE_RUNTIME_FUNCTION_ENTRY struct { //nolint:revive // Windows struct
+	BeginAddress      uint32 // RVA of the first byte of the function
+	EndAddress        uint32 // RVA one past the last byte
+	UnwindInfoAddress uint32 // RVA of the UNWIND_INFO
+}
+
+// --- UNWIND_INFO Flags ---
+const (
+	UNW_FLAG_NHANDLER  = 0x0 //nolint:revive // Windows constant
+	UNW_FLAG_EHANDLER  = 0x1 //nolint:revive // Windows constant
+	UNW_FLAG_UHANDLER  = 0x2 //nolint:revive // Windows constant
+	UNW_FLAG_CHAININFO = 0x4 //nolint:revive // Windows constant
+)
+
+var unwindFlagNames = []flagName{
+	{UNW_FLAG_EHANDLER, "EHANDLER"},
+	{UNW_FLAG_UHANDLER, "UHANDLER"},
+	{UNW_FLAG_CHAININFO, "CHAININFO"},
+}
+
+// UnwindFlagsToStrings decodes UNWIND_INFO flags.
+func UnwindFlagsToStrings(flags uint8) []string {
+	return decodeFlags(uint32(flags), unwindFlagNames)
+}
+
+// --- Unwind Operation Codes ---
+const (
+	UWOP_PUSH_NONVOL     = 0  //nolint:revive // Windows constant
+	UWOP_ALLOC_LARGE     = 1  //nolint:revive // Windows constant
+	UWOP_ALLOC_SMALL     = 2  //nolint:revive // Windows constant
+	UWOP_SET_FPREG       = 3  //nolint:revive // Windows constant
+	UWOP_SAVE_NONVOL     = 4  //nolint:revive // Windows constant
+	UWOP_SAVE_NONVOL_FAR = 5  //nolint:revive // Windows constant
+	UWOP_EPILOG          = 6  //nolint:revive // Windows constant
+	UWOP_SPARE_CODE      = 7  //nolint:revive // Windows constant
+	UWOP_SAVE_XMM128     = 8  //nolint:revive // Windows constant
+	UWOP_SAVE_XMM128_FAR = 9  //nolint:revive // Windows constant
+	UWOP_PUSH_MACHFRAME  = 10 //nolint:revive // Windows constant
+)
+
+var unwindOpNames = [...]string{
+	"PUSH_NONVOL", "ALLOC_LARGE", "ALLOC_SMALL", "SET_FPREG", "SAVE_NONVOL", "SAVE_NONVOL_FAR",
+	"EPILOG", "SPARE_CODE", "SAVE_XMM128", "SAVE_XMM128_FAR", "PUSH_MACHFRAME",
+}
+
+// unwindOpSlots is how many 16-bit UNWIND_CODE slots each operation takes,
+// including its own. ALLOC_LARGE takes one more when OpInfo is 1.
+var unwindOpSlots = [...]int{1, 2, 1, 1, 2, 3, 2, 3, 2, 3, 1}
+
+var registerNames = [16]string{
+	"RAX", "RCX", "RDX", "RBX", "RSP", "RBP", "RSI", "RDI",
+	"R8", "R9", "R10", "R11", "R12", "R13", "R14", "R15",
+}
+
+// maxUnwindChain bounds how many CHAININFO links are followed, so a chain
+// that points back at itself can't loop forever.
+const maxUnwindChain = 32
+
+// UnwindCode is one decoded prolog operation. Operand holds the scaled
+// allocation size or stack offset for the operations that carry one.
+type UnwindCode struct {
+	CodeOffset uint8 // Offset in the prolog of the instruction after this operation
+	Op         uint8 // UWOP_*
+	OpInfo     uint8 // Register number, or operation-specific info
+	Operand    uint32
+}
+
+func (c UnwindCode) String() string {
+	name := fmt.Sprintf("UWOP_%d", c.Op)
+	if int(c.Op) < len(unwindOpNames) {
+		name = unwindOpNames[c.Op]
+	}
+	reg := registerNames[c.OpInfo&0xF]
+	switch c.Op {
+	case UWOP_PUSH_NONVOL:
+		return fmt.Sprintf("%s %s", name, reg)
+	case UWOP_ALLOC_LARGE, UWOP_ALLOC_SMALL:
+		return fmt.Sprintf("%s 0x%X", name, c.Operand)
+	case UWOP_SAVE_NONVOL, UWOP_SAVE_NONVOL_FAR:
+		return fmt.Sprintf("%s %s, [RSP+0x%X]", name, reg, c.Operand)
+	case UWOP_SAVE_XMM128, UWOP_SAVE_XMM128_FAR:
+		return fmt.Sprintf("%s XMM%d, [RSP+0x%X]", name, c.OpInfo, c.Operand)
+	case UWOP_PUSH_MACHFRAME:
+		if c.OpInfo == 1 {
+			return name + " (with error code)"
+		}
+	}
+	return name
+}
+
+// UnwindInfo is a decoded UNWIND_INFO. A chained entry shares its parent's
+// unwind codes and lists only what it adds; Chained is the parent's entry and
+// Parent its decoded unwind info.
+type UnwindInfo struct {
+	RVA           uint32
+	Version       uint8
+	Flags         uint8
+	SizeOfProlog  uint8
+	CountOfCodes  uint8
+	FrameRegister uint8 // 0 if no frame pointer is used
+	FrameOffset   uint8 // Scaled by 16
+	Codes         []UnwindCode
+	HandlerRVA    uint32 // Language-specific handler, if EHANDLER or UHANDLER is set
+	HandlerData   uint32 // RVA of the handler's data that follows HandlerRVA
+	Chained       *IMAGE_RUNTIME_FUNCTION_ENTRY
+	Parent        *UnwindInfo
+}
+
+// FrameRegisterName returns the frame pointer register, or "" if none.
+func (u *UnwindInfo) FrameRegisterName() string {
+	if u.FrameRegister == 0 {
+		return ""
+	}
+	return registerNames[u.FrameRegister]
+}
+
+// RuntimeFunction is a .pdata entry with its unwind information.
+type RuntimeFunction struct {
+	IMAGE_RUNTIME_FUNCTION_ENTRY
+	Unwind *UnwindInfo
+}
+
+// Size returns the length of the function in bytes.
+func (r RuntimeFunction) Size() uint32 {
+	if r.EndAddress < r.BeginAddress {
+		return 0
+	}
+	return r.EndAddress - r.BeginAddress
+}
+
+// ExceptionTable decodes the x64 exception directory: the RUNTIME_FUNCTION
+// table and the UNWIND_INFO each entry points to. Because every non-leaf
+// function needs an entry, the table is also a dependable list of function
+// boundaries. Returns nil, nil if the image has no exception directory, and
+// the entries decoded so far along with the error if one is malformed.
+func (f *File) ExceptionTable() ([]RuntimeFunction, error) {
+	dir := f.DataDirectory(IMAGE_DIRECTORY_ENTRY_EXCEPTION)
+	if dir.VirtualAddress == 0 || dir.Size == 0 {
+		return nil, nil
+	}
+	if f.FileHeader.Machine != IMAGE_FILE_MACHINE_AMD64 {
+		return nil, &FormatError{Op: fmt.Sprintf("exception directory for machine 0x%X", f.FileHeader.Machine), Offset: -1, Err: ErrUnsupportedMachine}
+	}
+	const entrySize = 12
+	count := dir.Size / entrySize
+	table, err := f.tableAtRVA(dir.VirtualAddress, count, entrySize)
+	if err != nil {
+		return nil, err
+	}
+
+	funcs := make([]RuntimeFunction, 0, count)
+	for i := uint32(0); i < count; i++ {
+		b := table[i*entrySize:]
+		rf := RuntimeFunction{IMAGE_RUNTIME_FUNCTION_ENTRY: IMAGE_RUNTIME_FUNCTION_ENTRY{
+			BeginAddress:      binary.LittleEndian.Uint32(b),
+			EndAddress:        binary.LittleEndian.Uint32(b[4:]),
+			UnwindInfoAddress: binary.LittleEndian.Uint32(b[8:]),
+		}}
+		if rf.BeginAddress == 0 && rf.EndAddress == 0 && rf.UnwindInfoAddress == 0 {
+			continue // Padding some linkers leave at the end of the table
+		}
+		unwind, err := f.unwindInfo(rf.UnwindInfoAddress, 0)
+		if err != nil {
+			return funcs, &FormatError{Op: fmt.Sprintf("RUNTIME_FUNCTION %d (0x%X-0x%X)", i, rf.BeginAddress, rf.EndAddress), Offset: -1, Err: err}
+		}
+		rf.Unwind = unwind
+		funcs = append(funcs, rf)
+	}
+	return funcs, nil
+}
+
+// unwindInfo decodes the UNWIND_INFO at rva and the chain behind it.
+func (f *File) unwindInfo(rva uint32, depth int) (*UnwindInfo, error) {
+	if depth > maxUnwindChain {
+		return nil, fmt.Errorf("%w: unwind chain longer than %d", ErrInvalidUnwindInfo, maxUnwindChain)
+	}
+	// An odd address is an older form of chaining: it points at another
+	// RUNTIME_FUNCTION whose unwind info is shared.
+	if rva&1 != 0 {
+		var parent IMAGE_RUNTIME_FUNCTION_ENTRY
+		if err := f.structAtRVA(rva&^1, &parent); err != nil {
+			return nil, err
+		}
+		return f.unwindInfo(parent.UnwindInfoAddress, depth+1)
+	}
+
+	header, err := f.bytesAtRVA(rva, 4)
+	if err != nil {
+		return nil, err
+	}
+	u := &UnwindInfo{
+		RVA:           rva,
+		Version:       header[0] & 0x7,
+		Flags:         header[0] >> 3,
+		SizeOfProlog:  header[1],
+		CountOfCodes:  header[2],
+		FrameRegister: header[3] & 0xF,
+		FrameOffset:   header[3] >> 4,
+	}
+	if u.Version != 1 && u.Version != 2 {
+		return nil, fmt.Errorf("%w: version %d at RVA 0x%X", ErrInvalidUnwindInfo, u.Version, rva)
+	}
+
+	// The code array is padded to an even number of slots.
+	slots := (uint32(u.CountOfCodes) + 1) &^ 1
+	var raw []byte
+	if slots != 0 {
+		// An entry without codes may end exactly at the section's VirtualSize.
+		if raw, err = f.bytesAtRVA(rva+4, slots*2); err != nil {
+			return nil, err
+		}
+	}
+	slot := func(i int) uint16 { return binary.LittleEndian.Uint16(raw[i*2:]) }
+	for i := 0; i < int(u.CountOfCodes); {
+		c := UnwindCode{CodeOffset: raw[i*2], Op: raw[i*2+1] & 0xF, OpInfo: raw[i*2+1] >> 4}
+		if int(c.Op) >= len(unwindOpSlots) {
+			return nil, fmt.Errorf("%w: unknown unwind op %d at RVA 0x%X", ErrInvalidUnwindInfo, c.Op, rva)
+		}
+		n := unwindOpSlots[c.Op]
+		if c.Op == UWOP_ALLOC_LARGE && c.OpInfo == 1 {
+			n = 3
+		}
+		if i+n > int(u.CountOfCodes) {
+			return nil, fmt.Errorf("%w: unwind op %s overruns CountOfCodes at RVA 0x%X", ErrInvalidUnwindInfo, unwindOpNames[c.Op], rva)
+		}
+		switch c.Op {
+		case UWOP_ALLOC_LARGE:
+			if c.OpInfo == 0 {
+				c.Operand = uint32(slot(i+1)) * 8
+			} else {
+				c.Operand = uint32(slot(i+1)) | uint32(slot(i+2))<<16
+			}
+		case UWOP_ALLOC_SMALL:
+			c.Operand = uint32(c.OpInfo)*8 + 8
+		case UWOP_SAVE_NONVOL:
+			c.Operand = uint32(slot(i+1)) * 8
+		case UWOP_SAVE_NONVOL_FAR, UWOP_SAVE_XMM128_FAR:
+			c.Operand = uint32(slot(i+1)) | uint32(slot(i+2))<<16
+		case UWOP_SAVE_XMM128:
+			c.Operand = uint32(slot(i+1)) * 16
+		}
+		u.Codes = append(u.Codes, c)
+		i += n
+	}
+
+	tail := rva + 4 + slots*2
+	switch {
+	case u.Flags&UNW_FLAG_CHAININFO != 0:
+		var parent IMAGE_RUNTIME_FUNCTION_ENTRY
+		if err := f.structAtRVA(tail, &parent); err != nil {
+			return nil, err
+		}
+		u.Chained = &parent
+		if u.Parent, err = f.unwindInfo(parent.UnwindInfoAddress, depth+1); err != nil {
+			return nil, err
+		}
+	case u.Flags&(UNW_FLAG_EHANDLER|UNW_FLAG_UHANDLER) != 0:
+		if u.HandlerRVA, err = f.uint32AtRVA(tail); err != nil {
+			return nil, err
+		}
+		u.HandlerData = tail + 4
+	}
+	return u, nil
+}
+
+// RuntimeFunctionAnomaly describes a .pdata entry that a loader or unwinder
+// would trip over.
+type RuntimeFunctionAnomaly struct {
+	Index    int // Index into the slice passed to CheckRuntimeFunctions
+	Function RuntimeFunction
+	Reason   string
+}
+
+// CheckRuntimeFunctions reports entries with empty or inverted ranges,
+// ranges that overlap another entry (RtlLookupFunctionEntry binary-searches
+// the table, so overlaps make lookups ambiguous), and ranges that are not
+// wholly inside one executable section.
+func (f *File) CheckRuntimeFunctions(funcs []RuntimeFunction) []RuntimeFunctionAnomaly {
+	var anomalies []RuntimeFunctionAnomaly
+	add := func(i int, format string, args ...any) {
+		anomalies = append(anomalies, RuntimeFunctionAnomaly{Index: i, Function: funcs[i], Reason: fmt.Sprintf(format, args...)})
+	}
+
+	for i, rf := range funcs {
+		if rf.EndAddress <= rf.BeginAddress {
+			add(i, "empty or inverted range")
+			continue
+		}
+		s := f.sectionForRVA(rf.BeginAddress)
+		switch {
+		case s == nil:
+			add(i, "begins outside every section")
+		case s.Characteristics&IMAGE_SCN_MEM_EXECUTE == 0:
+			add(i, "lies in non-executable section '%s'", s.Name)
+		case f.sectionForRVA(rf.EndAddress-1) != s:
+			add(i, "runs past the end of section '%s'", s.Name)
+		}
+	}
+
+	order := make([]int, len(funcs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return funcs[order[a]].BeginAddress < funcs[order[b]].BeginAddress })
+	for k := 1; k < len(order); k++ {
+		prev, cur := funcs[order[k-1]], funcs[order[k]]
+		if cur.BeginAddress < prev.EndAddress && prev.EndAddress > prev.BeginAddress {
+			add(order[k], "overlaps entry %d (0x%X-0x%X)", order[k-1], prev.BeginAddress, prev.EndAddress)
+		}
+	}
+	return anomalies
+}
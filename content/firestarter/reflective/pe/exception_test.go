@@ -0,0 +1,146 @@
+package pe
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExceptionTableCalcDLL(t *testing.T) {
+	f, err := Parse(loadCalcDLL(t))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	funcs, err := f.ExceptionTable()
+	if err != nil {
+		t.Fatalf("ExceptionTable: %v", err)
+	}
+	if len(funcs) != 46 {
+		t.Fatalf("got %d functions, want 46", len(funcs))
+	}
+	rf := funcs[1]
+	if rf.BeginAddress != 0x1010 || rf.Size() != 0x1BF || rf.Unwind.SizeOfProlog != 12 {
+		t.Errorf("function 1 = %+v, unwind %+v", rf.IMAGE_RUNTIME_FUNCTION_ENTRY, rf.Unwind)
+	}
+	var codes []string
+	for _, c := range rf.Unwind.Codes {
+		codes = append(codes, c.String())
+	}
+	want := []string{"ALLOC_SMALL 0x28", "PUSH_NONVOL RBX", "PUSH_NONVOL RSI", "PUSH_NONVOL RDI",
+		"PUSH_NONVOL RBP", "PUSH_NONVOL R12", "PUSH_NONVOL R13"}
+	if !reflect.DeepEqual(codes, want) {
+		t.Errorf("codes = %q, want %q", codes, want)
+	}
+	if anomalies := f.CheckRuntimeFunctions(funcs); len(anomalies) != 0 {
+		t.Errorf("CheckRuntimeFunctions = %+v, want none", anomalies)
+	}
+}
+
+// exceptionImage builds a PE32 or PE32+ image with .text at 0x1000 (0x100
+// bytes), unwind data in .rdata at 0x2000 and the RUNTIME_FUNCTION table in
+// .pdata at 0x3000.
+func exceptionImage(t *testing.T, is64 bool, rdata []byte, table ...IMAGE_RUNTIME_FUNCTION_ENTRY) *File {
+	t.Helper()
+	pdata := make([]byte, 0x200)
+	copy(pdata, encode(t, table))
+	return testImage{
+		is64: is64,
+		sections: withText(
+			newSection(".rdata", 0x2000, 0x200, 0x600, 0x200, 0x40000040),
+			newSection(".pdata", 0x3000, 0x200, 0x800, 0x200, 0x40000040),
+		),
+		directories: map[int]IMAGE_DATA_DIRECTORY{IMAGE_DIRECTORY_ENTRY_EXCEPTION: {VirtualAddress: 0x3000, Size: uint32(len(table) * 12)}},
+		sectionData: map[int][]byte{1: rdata, 2: pdata},
+	}.parse(t)
+}
+
+func TestExceptionTable(t *testing.T) {
+	rdata := make([]byte, 0x200)
+	// 0x2000: EHANDLER, frame register RBP at +0x20, handler at 0x1080.
+	copy(rdata[0x00:], []byte{0x09, 0x10, 6, 0x25,
+		0x10, 0x03, // SET_FPREG
+		0x0C, 0x34, 0x04, 0x00, // SAVE_NONVOL RBX, [RSP+0x20]
+		0x08, 0x01, 0x20, 0x00, // ALLOC_LARGE 0x100
+		0x01, 0x50, // PUSH_NONVOL RBP
+		0x80, 0x10, 0x00, 0x00})
+	// 0x2020: CHAININFO back to the 0x1000 function.
+	copy(rdata[0x20:], []byte{0x21, 0, 0, 0, 0x00, 0x10, 0, 0, 0x40, 0x10, 0, 0, 0x00, 0x20, 0, 0})
+	// 0x2040: SAVE_XMM128, the three-slot ALLOC_LARGE and ALLOC_SMALL.
+	copy(rdata[0x40:], []byte{0x01, 0x0A, 6, 0,
+		0x0A, 0x68, 0x02, 0x00, // SAVE_XMM128 XMM6, [RSP+0x20]
+		0x04, 0x11, 0x00, 0x00, 0x01, 0x00, // ALLOC_LARGE 0x10000
+		0x00, 0x22}) // ALLOC_SMALL 0x18
+
+	f := exceptionImage(t, true, rdata,
+		IMAGE_RUNTIME_FUNCTION_ENTRY{0x1000, 0x1040, 0x2000},
+		IMAGE_RUNTIME_FUNCTION_ENTRY{0x1040, 0x1060, 0x2020},
+		IMAGE_RUNTIME_FUNCTION_ENTRY{0x1050, 0x1070, 0x2040}, // Overlaps the previous entry
+		IMAGE_RUNTIME_FUNCTION_ENTRY{0x2000, 0x2010, 0x2040}, // In .rdata
+		IMAGE_RUNTIME_FUNCTION_ENTRY{0x10F0, 0x1200, 0x2040}, // Past the end of .text
+	)
+	funcs, err := f.ExceptionTable()
+	if err != nil {
+		t.Fatalf("ExceptionTable: %v", err)
+	}
+	if len(funcs) != 5 {
+		t.Fatalf("got %d functions, want 5", len(funcs))
+	}
+
+	u := funcs[0].Unwind
+	if u.Version != 1 || u.Flags != UNW_FLAG_EHANDLER || u.SizeOfProlog != 0x10 || u.FrameRegisterName() != "RBP" ||
+		u.FrameOffset != 2 || u.HandlerRVA != 0x1080 || u.HandlerData != 0x2014 {
+		t.Errorf("unwind 0 = %+v", u)
+	}
+	wantCodes := []UnwindCode{
+		{0x10, UWOP_SET_FPREG, 0, 0},
+		{0x0C, UWOP_SAVE_NONVOL, 3, 0x20},
+		{0x08, UWOP_ALLOC_LARGE, 0, 0x100},
+		{0x01, UWOP_PUSH_NONVOL, 5, 0},
+	}
+	if !reflect.DeepEqual(u.Codes, wantCodes) {
+		t.Errorf("codes 0 = %+v, want %+v", u.Codes, wantCodes)
+	}
+
+	chained := funcs[1].Unwind
+	if chained.Chained == nil || chained.Chained.BeginAddress != 0x1000 || chained.Parent == nil || chained.Parent.RVA != 0x2000 {
+		t.Errorf("chained unwind = %+v", chained)
+	}
+
+	var codes []string
+	for _, c := range funcs[2].Unwind.Codes {
+		codes = append(codes, c.String())
+	}
+	if want := []string{"SAVE_XMM128 XMM6, [RSP+0x20]", "ALLOC_LARGE 0x10000", "ALLOC_SMALL 0x18"}; !reflect.DeepEqual(codes, want) {
+		t.Errorf("codes 2 = %q, want %q", codes, want)
+	}
+
+	var got []int
+	for _, a := range f.CheckRuntimeFunctions(funcs) {
+		got = append(got, a.Index)
+	}
+	if want := []int{3, 4, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("anomalies at %v, want %v", got, want)
+	}
+}
+
+func TestExceptionTableMalformed(t *testing.T) {
+	rdata := make([]byte, 0x200)
+	// 0x2000 chains to an entry whose unwind info is itself.
+	copy(rdata[0x00:], []byte{0x21, 0, 0, 0, 0x00, 0x10, 0, 0, 0x40, 0x10, 0, 0, 0x00, 0x20, 0, 0})
+	// 0x2020 claims version 3.
+	copy(rdata[0x20:], []byte{0x03, 0, 0, 0})
+	// 0x2040 has one slot but a two-slot operation.
+	copy(rdata[0x40:], []byte{0x01, 0, 1, 0, 0x04, 0x01})
+
+	for _, rva := range []uint32{0x2000, 0x2020, 0x2040} {
+		f := exceptionImage(t, true, rdata, IMAGE_RUNTIME_FUNCTION_ENTRY{0x1000, 0x1040, rva})
+		if _, err := f.ExceptionTable(); !errors.Is(err, ErrInvalidUnwindInfo) {
+			t.Errorf("unwind at 0x%X: err = %v, want ErrInvalidUnwindInfo", rva, err)
+		}
+	}
+
+	f := exceptionImage(t, false, rdata, IMAGE_RUNTIME_FUNCTION_ENTRY{0x1000, 0x1040, 0x2040})
+	if _, err := f.ExceptionTable(); !errors.Is(err, ErrUnsupportedMachine) {
+		t.Errorf("PE32: err = %v, want ErrUnsupportedMachine", err)
+	}
+}
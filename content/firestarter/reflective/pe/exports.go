@@ -0,0 +1,97 @@
+package pe
+
+import "encoding/binary"
+
+// ExportDirectory is the decoded IMAGE_EXPORT_DIRECTORY together with every
+// entry of its Export Address Table.
+type ExportDirectory struct {
+	IMAGE_EXPORT_DIRECTORY
+	Name      string   // Module name recorded by the linker
+	Names     []string // Every entry of the name pointer table, in table order
+	Functions []ExportedFunction
+}
+
+// ExportedFunction is one populated slot of the Export Address Table.
+type ExportedFunction struct {
+	Ordinal   uint32 // Biased ordinal (Base + EAT index), as used by GetProcAddress
+	Name      string // Empty for ordinal-only exports
+	RVA       uint32 // EAT entry; for forwarders this points at the forwarder string
+	Forwarder string // e.g. "NTDLL.RtlFoo"; empty unless the export is forwarded
+}
+
+// IsForwarder reports whether the export forwards to another DLL instead of
+// pointing at code in this one.
+func (e ExportedFunction) IsForwarder() bool {
+	return e.Forwarder != ""
+}
+
+// Exports dumps the export directory from the file on disk. Unlike the
+// name search in the reflective loader, every EAT slot is reported: named
+// exports are matched up through the ordinal table (EOT), exports that no
+// name points at are listed as ordinal-only, and any EAT entry that lands
+// back inside the export directory's own range is decoded as a forwarder.
+func (f *File) Exports() (*ExportDirectory, error) {
+	dir := f.DataDirectory(IMAGE_DIRECTORY_ENTRY_EXPORT)
+	if dir.VirtualAddress == 0 {
+		return nil, nil
+	}
+
+	exp := new(ExportDirectory)
+	if err := f.structAtRVA(dir.VirtualAddress, &exp.IMAGE_EXPORT_DIRECTORY); err != nil {
+		return nil, err
+	}
+	if exp.IMAGE_EXPORT_DIRECTORY.Name != 0 {
+		name, err := f.stringAtRVA(exp.IMAGE_EXPORT_DIRECTORY.Name)
+		if err != nil {
+			return nil, err
+		}
+		exp.Name = name
+	}
+
+	// Map EAT index -> name by walking the name pointer table (ENPT) and the
+	// parallel ordinal table (EOT). The tables are sliced out whole first so a
+	// hostile NumberOfNames/NumberOfFunctions can't run past the file.
+	namePointers, err := f.tableAtRVA(exp.AddressOfNames, exp.NumberOfNames, 4)
+	if err != nil && exp.NumberOfNames != 0 {
+		return exp, err
+	}
+	nameOrdinals, err := f.tableAtRVA(exp.AddressOfNameOrdinals, exp.NumberOfNames, 2)
+	if err != nil && exp.NumberOfNames != 0 {
+		return exp, err
+	}
+	names := make(map[uint32]string)
+	for i := uint32(0); i < exp.NumberOfNames; i++ {
+		nameRVA := binary.LittleEndian.Uint32(namePointers[i*4:])
+		index := binary.LittleEndian.Uint16(nameOrdinals[i*2:])
+		name, err := f.stringAtRVA(nameRVA)
+		if err != nil {
+			return exp, err
+		}
+		exp.Names = append(exp.Names, name)
+		if _, seen := names[uint32(index)]; !seen {
+			names[uint32(index)] = name
+		}
+	}
+
+	functions, err := f.tableAtRVA(exp.AddressOfFunctions, exp.NumberOfFunctions, 4)
+	if err != nil && exp.NumberOfFunctions != 0 {
+		return exp, err
+	}
+	for i := uint32(0); i < exp.NumberOfFunctions; i++ {
+		rva := binary.LittleEndian.Uint32(functions[i*4:])
+		// Gaps in the ordinal range are left as zero entries
+		if rva == 0 {
+			continue
+		}
+		fn := ExportedFunction{Ordinal: exp.Base + i, Name: names[i], RVA: rva}
+		if rva >= dir.VirtualAddress && uint64(rva) < uint64(dir.VirtualAddress)+uint64(dir.Size) {
+			forwarder, err := f.stringAtRVA(rva)
+			if err != nil {
+				return exp, err
+			}
+			fn.Forwarder = forwarder
+		}
+		exp.Functions = append(exp.Functions, fn)
+	}
+	return exp, nil
+}
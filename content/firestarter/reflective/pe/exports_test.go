@@ -0,0 +1,120 @@
+package pe
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestExportsCalcDLL(t *testing.T) {
+	f, err := Parse(loadCalcDLL(t))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	exp, err := f.Exports()
+	if err != nil {
+		t.Fatalf("Exports: %v", err)
+	}
+	if exp.Name != "calc_dll.dll" || exp.Base != 1 {
+		t.Errorf("module = %q base %d, want calc_dll.dll base 1", exp.Name, exp.Base)
+	}
+	if len(exp.Functions) != 1 || exp.Functions[0].Name != "LaunchCalc" || exp.Functions[0].Ordinal != 1 {
+		t.Fatalf("exports = %+v, want LaunchCalc @1", exp.Functions)
+	}
+}
+
+// TestExportsForwarderAndOrdinalOnly builds an export directory with a named
+// export, an unused EAT slot, an ordinal-only export and a forwarder.
+func TestExportsForwarderAndOrdinalOnly(t *testing.T) {
+	const rva, raw = 0x2000, 0x400
+	edata := make([]byte, 0x200)
+	put32 := func(off int, v uint32) { binary.LittleEndian.PutUint32(edata[off:], v) }
+
+	put32(0x0C, rva+0x80) // Name
+	put32(0x10, 10)       // Base
+	put32(0x14, 4)        // NumberOfFunctions
+	put32(0x18, 2)        // NumberOfNames
+	put32(0x1C, rva+0x40) // AddressOfFunctions
+	put32(0x20, rva+0x50) // AddressOfNames
+	put32(0x24, rva+0x58) // AddressOfNameOrdinals
+
+	put32(0x40, 0x1000)   // @10 Alpha
+	put32(0x44, 0)        // @11 unused
+	put32(0x48, 0x1010)   // @12 ordinal-only
+	put32(0x4C, rva+0x90) // @13 Beta, forwarded
+	put32(0x50, rva+0xA0) // "Alpha"
+	put32(0x54, rva+0xA8) // "Beta"
+	binary.LittleEndian.PutUint16(edata[0x58:], 0)
+	binary.LittleEndian.PutUint16(edata[0x5A:], 3)
+	copy(edata[0x80:], "test.dll\x00")
+	copy(edata[0x90:], "NTDLL.RtlFoo\x00")
+	copy(edata[0xA0:], "Alpha\x00")
+	copy(edata[0xA8:], "Beta\x00")
+
+	data := testImage{
+		is64: true,
+		sections: []IMAGE_SECTION_HEADER{
+			newSection(".text", 0x1000, 0x100, 0x600, 0x200, 0x60000020),
+			newSection(".edata", rva, 0x200, raw, 0x200, 0x40000040),
+		},
+		directories: map[int]IMAGE_DATA_DIRECTORY{IMAGE_DIRECTORY_ENTRY_EXPORT: {VirtualAddress: rva, Size: 0xB0}},
+		sectionData: map[int][]byte{1: edata},
+	}.build(t)
+
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	exp, err := f.Exports()
+	if err != nil {
+		t.Fatalf("Exports: %v", err)
+	}
+	want := []ExportedFunction{
+		{Ordinal: 10, Name: "Alpha", RVA: 0x1000},
+		{Ordinal: 12, RVA: 0x1010},
+		{Ordinal: 13, Name: "Beta", RVA: rva + 0x90, Forwarder: "NTDLL.RtlFoo"},
+	}
+	if len(exp.Functions) != len(want) {
+		t.Fatalf("exports = %+v, want %+v", exp.Functions, want)
+	}
+	for i := range want {
+		if exp.Functions[i] != want[i] {
+			t.Errorf("export %d = %+v, want %+v", i, exp.Functions[i], want[i])
+		}
+	}
+	if !exp.Functions[2].IsForwarder() || exp.Functions[0].IsForwarder() {
+		t.Error("IsForwarder mismatch")
+	}
+}
+
+// TestExportsHostileCounts checks that NumberOfNames/NumberOfFunctions far
+// larger than the file are rejected instead of driving a 4-billion-entry loop.
+func TestExportsHostileCounts(t *testing.T) {
+	data := loadCalcDLL(t)
+	edata := exportDirOffset(t, data)
+	for _, field := range []int{0x14, 0x18} { // NumberOfFunctions, NumberOfNames
+		mutated := append([]byte(nil), data...)
+		binary.LittleEndian.PutUint32(mutated[edata+field:], 0xFFFFFFFF)
+		f, err := Parse(mutated)
+		if err != nil {
+			t.Fatalf("Parse: %v", err)
+		}
+		if _, err := f.Exports(); !errors.Is(err, ErrTruncated) {
+			t.Errorf("field 0x%X: Exports error = %v, want ErrTruncated", field, err)
+		}
+	}
+}
+
+// exportDirOffset returns the file offset of the export directory.
+func exportDirOffset(t *testing.T, data []byte) int {
+	t.Helper()
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	offset, err := f.RVAToOffset(f.DataDirectory(IMAGE_DIRECTORY_ENTRY_EXPORT).VirtualAddress)
+	if err != nil {
+		t.Fatalf("RVAToOffset: %v", err)
+	}
+	return int(offset)
+}
@@ -0,0 +1,278 @@
+package pe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// File is a parsed PE image. The raw bytes passed to Parse are retained so
+// later lookups (sections, directories) can slice into them without copying.
+//
+// Exactly one of OptionalHeader32 and OptionalHeader64 is set, chosen by the
+// optional header's Magic. The accessor methods below cover the fields both
+// layouts share, so callers that don't care about bitness can ignore which
+// one was populated.
+type File struct {
+	DosHeader        IMAGE_DOS_HEADER
+	FileHeader       IMAGE_FILE_HEADER
+	OptionalHeader32 *IMAGE_OPTIONAL_HEADER32 // Set for PE32 images
+	OptionalHeader64 *IMAGE_OPTIONAL_HEADER64 // Set for PE32+ images
+	Sections         []*Section
+
+	data []byte
+}
+
+// Section is a section header together with its decoded name.
+type Section struct {
+	IMAGE_SECTION_HEADER
+	Name string // Section name with the null padding stripped
+}
+
+// Parse reads the DOS header, NT headers and section table from data.
+// It follows the same sequence the labs walk through by hand: validate "MZ",
+// seek to e_lfanew, validate "PE\0\0", then read the file header, optional
+// header and each section header in turn.
+//
+// Every offset and size taken from the headers is bounds-checked before it is
+// used (see validate), so a mapper working from a *File returned without error
+// can copy headers and section data without further checks.
+func Parse(data []byte) (*File, error) {
+	f := &File{data: data}
+	reader := bytes.NewReader(data)
+
+	// Parse IMAGE_DOS_HEADER
+	if err := binary.Read(reader, binary.LittleEndian, &f.DosHeader); err != nil {
+		return nil, readError("DOS header", 0, err)
+	}
+
+	// Validate DOS signature ("MZ")
+	if f.DosHeader.Magic != IMAGE_DOS_SIGNATURE {
+		return nil, &FormatError{Op: "DOS header", Offset: 0, Err: ErrInvalidDOSSignature}
+	}
+
+	// Seek to the NT Headers offset specified in the DOS header. e_lfanew is
+	// signed, and a hostile file can point it anywhere.
+	ntOffset := int64(f.DosHeader.Lfanew)
+	if ntOffset < 0 || ntOffset >= int64(len(data)) {
+		return nil, &FormatError{Op: "e_lfanew", Offset: 0x3C, Err: ErrInvalidLfanew}
+	}
+	if _, err := reader.Seek(ntOffset, io.SeekStart); err != nil {
+		return nil, &FormatError{Op: "NT headers", Offset: ntOffset, Err: err}
+	}
+
+	// Read and validate PE signature ("PE\0\0")
+	var peSignature uint32
+	if err := binary.Read(reader, binary.LittleEndian, &peSignature); err != nil {
+		return nil, readError("PE signature", ntOffset, err)
+	}
+	if peSignature != IMAGE_NT_SIGNATURE {
+		return nil, &FormatError{Op: "PE signature", Offset: ntOffset, Err: ErrInvalidNTSignature}
+	}
+
+	// Read IMAGE_FILE_HEADER
+	fileHeaderOffset := ntOffset + 4
+	if err := binary.Read(reader, binary.LittleEndian, &f.FileHeader); err != nil {
+		return nil, readError("file header", fileHeaderOffset, err)
+	}
+
+	// Read the optional header. Its layout depends on Magic, and its length
+	// comes from SizeOfOptionalHeader rather than from either struct.
+	optionalHeaderOffset := fileHeaderOffset + int64(binary.Size(f.FileHeader))
+	if f.FileHeader.SizeOfOptionalHeader == 0 {
+		return nil, &FormatError{Op: "optional header", Offset: optionalHeaderOffset, Err: ErrNoOptionalHeader}
+	}
+	if err := f.readOptionalHeader(optionalHeaderOffset); err != nil {
+		return nil, err
+	}
+
+	// --- Section Headers ---
+	// The section table starts right after the optional header as declared
+	// by SizeOfOptionalHeader, which is not necessarily the size of the
+	// struct we decoded it into.
+	sectionTableOffset := optionalHeaderOffset + int64(f.FileHeader.SizeOfOptionalHeader)
+	if f.FileHeader.NumberOfSections > MaxSections {
+		return nil, &FormatError{Op: "file header", Offset: fileHeaderOffset + 2, Err: ErrTooManySections}
+	}
+	if _, err := reader.Seek(sectionTableOffset, io.SeekStart); err != nil {
+		return nil, &FormatError{Op: "section table", Offset: sectionTableOffset, Err: err}
+	}
+	f.Sections = make([]*Section, 0, f.FileHeader.NumberOfSections)
+	for i := uint16(0); i < f.FileHeader.NumberOfSections; i++ {
+		offset, _ := reader.Seek(0, io.SeekCurrent)
+		s := new(Section)
+		if err := binary.Read(reader, binary.LittleEndian, &s.IMAGE_SECTION_HEADER); err != nil {
+			return nil, readError("section header", offset, err)
+		}
+		s.Name = SectionNameToString(s.IMAGE_SECTION_HEADER.Name)
+		f.Sections = append(f.Sections, s)
+	}
+
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// readOptionalHeader decodes the PE32 or PE32+ optional header at offset.
+// Only SizeOfOptionalHeader bytes are consumed; a shorter header (fewer data
+// directories) leaves the remaining fields zeroed, and anything past the end
+// of the decoded struct is ignored.
+func (f *File) readOptionalHeader(offset int64) error {
+	declared := int64(f.FileHeader.SizeOfOptionalHeader)
+	if offset+2 > int64(len(f.data)) {
+		return &FormatError{Op: "optional header", Offset: offset, Err: ErrTruncated}
+	}
+	magic := binary.LittleEndian.Uint16(f.data[offset:])
+
+	var header any
+	var fixedSize int64 // Size of the header up to (not including) DataDirectory
+	switch magic {
+	case IMAGE_NT_OPTIONAL_HDR32_MAGIC:
+		f.OptionalHeader32 = new(IMAGE_OPTIONAL_HEADER32)
+		header = f.OptionalHeader32
+		fixedSize = int64(binary.Size(f.OptionalHeader32)) - IMAGE_NUMBEROF_DIRECTORY_ENTRIES*8
+	case IMAGE_NT_OPTIONAL_HDR64_MAGIC:
+		f.OptionalHeader64 = new(IMAGE_OPTIONAL_HEADER64)
+		header = f.OptionalHeader64
+		fixedSize = int64(binary.Size(f.OptionalHeader64)) - IMAGE_NUMBEROF_DIRECTORY_ENTRIES*8
+	default:
+		return &FormatError{Op: "optional header", Offset: offset, Err: ErrUnsupportedMagic}
+	}
+	if declared < fixedSize || offset+declared > int64(len(f.data)) {
+		return &FormatError{Op: "optional header", Offset: offset, Err: ErrTruncated}
+	}
+
+	// Copy the declared bytes into a buffer sized for the full struct so a
+	// short header decodes with its missing data directories zeroed.
+	buf := make([]byte, binary.Size(header))
+	copy(buf, f.data[offset:offset+declared])
+	if err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, header); err != nil {
+		return readError("optional header", offset, err)
+	}
+	return nil
+}
+
+// Is64 reports whether the image is PE32+ (64-bit).
+func (f *File) Is64() bool {
+	return f.OptionalHeader64 != nil
+}
+
+// Magic returns the optional header Magic (0x10b or 0x20b).
+func (f *File) Magic() uint16 {
+	if f.Is64() {
+		return f.OptionalHeader64.Magic
+	}
+	return f.OptionalHeader32.Magic
+}
+
+// ImageBase returns the preferred load address, widened to 64 bits.
+func (f *File) ImageBase() uint64 {
+	if f.Is64() {
+		return f.OptionalHeader64.ImageBase
+	}
+	return uint64(f.OptionalHeader32.ImageBase)
+}
+
+// AddressOfEntryPoint returns the RVA of the entry point.
+func (f *File) AddressOfEntryPoint() uint32 {
+	if f.Is64() {
+		return f.OptionalHeader64.AddressOfEntryPoint
+	}
+	return f.OptionalHeader32.AddressOfEntryPoint
+}
+
+// SizeOfImage returns the size of the image once mapped into memory.
+func (f *File) SizeOfImage() uint32 {
+	if f.Is64() {
+		return f.OptionalHeader64.SizeOfImage
+	}
+	return f.OptionalHeader32.SizeOfImage
+}
+
+// SizeOfHeaders returns the combined size of the DOS stub, NT headers and
+// section table, rounded up to FileAlignment.
+func (f *File) SizeOfHeaders() uint32 {
+	if f.Is64() {
+		return f.OptionalHeader64.SizeOfHeaders
+	}
+	return f.OptionalHeader32.SizeOfHeaders
+}
+
+// SectionAlignment returns the alignment of sections once mapped into memory.
+func (f *File) SectionAlignment() uint32 {
+	if f.Is64() {
+		return f.OptionalHeader64.SectionAlignment
+	}
+	return f.OptionalHeader32.SectionAlignment
+}
+
+// DllCharacteristics returns the optional header DllCharacteristics flags.
+func (f *File) DllCharacteristics() uint16 {
+	if f.Is64() {
+		return f.OptionalHeader64.DllCharacteristics
+	}
+	return f.OptionalHeader32.DllCharacteristics
+}
+
+// NumberOfRvaAndSizes returns how many data directory entries are in use.
+func (f *File) NumberOfRvaAndSizes() uint32 {
+	if f.Is64() {
+		return f.OptionalHeader64.NumberOfRvaAndSizes
+	}
+	return f.OptionalHeader32.NumberOfRvaAndSizes
+}
+
+// DataDirectory returns the data directory entry at index (one of the
+// IMAGE_DIRECTORY_ENTRY_* constants). Entries beyond NumberOfRvaAndSizes
+// are reported as empty.
+func (f *File) DataDirectory(index int) IMAGE_DATA_DIRECTORY {
+	if index < 0 || index >= IMAGE_NUMBEROF_DIRECTORY_ENTRIES || uint32(index) >= f.NumberOfRvaAndSizes() {
+		return IMAGE_DATA_DIRECTORY{}
+	}
+	if f.Is64() {
+		return f.OptionalHeader64.DataDirectory[index]
+	}
+	return f.OptionalHeader32.DataDirectory[index]
+}
+
+// Bytes returns the raw image the File was parsed from.
+func (f *File) Bytes() []byte {
+	return f.data
+}
+
+// Section returns the first section with the given name, or nil.
+func (f *File) Section(name string) *Section {
+	for _, s := range f.Sections {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// SectionData returns the section's raw bytes as stored in the file. The
+// slice is clamped to the end of the file, so it may be shorter than
+// SizeOfRawData.
+func (f *File) SectionData(s *Section) []byte {
+	start := uint64(s.PointerToRawData)
+	end := start + uint64(s.SizeOfRawData)
+	size := uint64(len(f.data))
+	if start > size {
+		start = size
+	}
+	if end > size {
+		end = size
+	}
+	return f.data[start:end]
+}
+
+// readError converts a binary.Read failure into a *FormatError, treating a
+// short read as truncation.
+func readError(op string, offset int64, err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = ErrTruncated
+	}
+	return &FormatError{Op: op, Offset: offset, Err: err}
+}
@@ -0,0 +1,319 @@
+package pe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// testImage describes a synthetic PE image for tests that need layouts
+// calc_dll.dll doesn't have.
+type testImage struct {
+	is64                 bool
+	sizeOfOptionalHeader uint16 // 0 means the full struct size
+	dllCharacteristics   uint16
+	sections             []IMAGE_SECTION_HEADER
+	directories          map[int]IMAGE_DATA_DIRECTORY
+	sectionData          map[int][]byte // Raw bytes written at each section's PointerToRawData
+}
+
+// build lays the image out as DOS header at 0, NT headers at 0x40, section
+// table straight after the optional header, and section data wherever the
+// headers say it lives.
+func (ti testImage) build(t testing.TB) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	write := func(v any) {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			t.Fatalf("build test image: %v", err)
+		}
+	}
+
+	write(IMAGE_DOS_HEADER{Magic: IMAGE_DOS_SIGNATURE, Lfanew: 0x40})
+	write(uint32(IMAGE_NT_SIGNATURE))
+
+	var optional any
+	var dirs [IMAGE_NUMBEROF_DIRECTORY_ENTRIES]IMAGE_DATA_DIRECTORY
+	for i, d := range ti.directories {
+		dirs[i] = d
+	}
+	machine := uint16(0x14c)
+	if ti.is64 {
+		machine = 0x8664
+		optional = IMAGE_OPTIONAL_HEADER64{Magic: IMAGE_NT_OPTIONAL_HDR64_MAGIC, ImageBase: 0x180000000,
+			SectionAlignment: 0x1000, FileAlignment: 0x200, SizeOfImage: 0x10000, SizeOfHeaders: 0x400,
+			DllCharacteristics: ti.dllCharacteristics, NumberOfRvaAndSizes: IMAGE_NUMBEROF_DIRECTORY_ENTRIES,
+			DataDirectory: dirs}
+	} else {
+		optional = IMAGE_OPTIONAL_HEADER32{Magic: IMAGE_NT_OPTIONAL_HDR32_MAGIC, ImageBase: 0x10000000,
+			BaseOfData: 0x2000, SectionAlignment: 0x1000, FileAlignment: 0x200, SizeOfImage: 0x10000,
+			SizeOfHeaders: 0x400, DllCharacteristics: ti.dllCharacteristics, SizeOfStackReserve: 0x100000,
+			NumberOfRvaAndSizes: IMAGE_NUMBEROF_DIRECTORY_ENTRIES, DataDirectory: dirs}
+	}
+	sizeOfOptionalHeader := ti.sizeOfOptionalHeader
+	if sizeOfOptionalHeader == 0 {
+		sizeOfOptionalHeader = uint16(binary.Size(optional))
+	}
+	write(IMAGE_FILE_HEADER{Machine: machine, NumberOfSections: uint16(len(ti.sections)),
+		SizeOfOptionalHeader: sizeOfOptionalHeader, Characteristics: 0x2102})
+
+	var opt bytes.Buffer
+	binary.Write(&opt, binary.LittleEndian, optional)
+	header := make([]byte, sizeOfOptionalHeader)
+	copy(header, opt.Bytes())
+	buf.Write(header)
+	for _, s := range ti.sections {
+		write(s)
+	}
+
+	image := buf.Bytes()
+	if len(image) < 0x400 {
+		image = append(image, make([]byte, 0x400-len(image))...)
+	}
+	for i, s := range ti.sections {
+		end := int(s.PointerToRawData + s.SizeOfRawData)
+		if end > len(image) {
+			image = append(image, make([]byte, end-len(image))...)
+		}
+		copy(image[s.PointerToRawData:end], ti.sectionData[i])
+	}
+	return image
+}
+
+// parse builds the image and parses it, failing the test if Parse rejects it.
+func (ti testImage) parse(t testing.TB) *File {
+	t.Helper()
+	f, err := Parse(ti.build(t))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return f
+}
+
+// withText returns the usual fixture layout: a .text section at RVA 0x1000
+// (raw 0x400) followed by sections, so sectionData for sections[i] goes in
+// slot i+1.
+func withText(sections ...IMAGE_SECTION_HEADER) []IMAGE_SECTION_HEADER {
+	return append([]IMAGE_SECTION_HEADER{newSection(".text", 0x1000, 0x100, 0x400, 0x200, 0x60000020)}, sections...)
+}
+
+// encode returns v in its little-endian on-disk form.
+func encode(t testing.TB, v any) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+		t.Fatalf("encode %T: %v", v, err)
+	}
+	return buf.Bytes()
+}
+
+// newSection returns a section header with the given name and layout.
+func newSection(name string, rva, virtualSize, rawOffset, rawSize, characteristics uint32) IMAGE_SECTION_HEADER {
+	s := IMAGE_SECTION_HEADER{VirtualAddress: rva, VirtualSize: virtualSize,
+		PointerToRawData: rawOffset, SizeOfRawData: rawSize, Characteristics: characteristics}
+	copy(s.Name[:], name)
+	return s
+}
+
+// Offsets of interesting fields in calc_dll.dll (e_lfanew 0x80, PE32+).
+const (
+	calcSizeOfImage  = 0x80 + 4 + 20 + 56
+	calcSectionTable = 0x80 + 4 + 20 + 240
+)
+
+// loadCalcDLL returns the lab DLL built in Lab 1.1.
+func loadCalcDLL(t testing.TB) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/calc_dll.dll")
+	if err != nil {
+		t.Fatalf("read testdata: %v", err)
+	}
+	return data
+}
+
+func TestParseCalcDLL(t *testing.T) {
+	f, err := Parse(loadCalcDLL(t))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if f.DosHeader.Lfanew != 0x80 {
+		t.Errorf("Lfanew = 0x%X, want 0x80", f.DosHeader.Lfanew)
+	}
+	if got := MachineTypeToString(f.FileHeader.Machine); got != "x64 (AMD64)" {
+		t.Errorf("Machine = %q, want x64 (AMD64)", got)
+	}
+	if !f.Is64() || f.OptionalHeader32 != nil {
+		t.Fatalf("Is64 = %v, want PE32+ only", f.Is64())
+	}
+	if f.AddressOfEntryPoint() != 0x1330 {
+		t.Errorf("AddressOfEntryPoint = 0x%X, want 0x1330", f.AddressOfEntryPoint())
+	}
+	if len(f.Sections) != 19 {
+		t.Fatalf("len(Sections) = %d, want 19", len(f.Sections))
+	}
+
+	text := f.Section(".text")
+	if text == nil {
+		t.Fatal("no .text section")
+	}
+	if text.VirtualAddress != 0x1000 || text.PointerToRawData != 0x600 {
+		t.Errorf(".text VA/raw = 0x%X/0x%X, want 0x1000/0x600", text.VirtualAddress, text.PointerToRawData)
+	}
+	if got := len(f.SectionData(text)); got != int(text.SizeOfRawData) {
+		t.Errorf("len(SectionData(.text)) = %d, want %d", got, text.SizeOfRawData)
+	}
+}
+
+func TestParsePE32(t *testing.T) {
+	data := testImage{sections: []IMAGE_SECTION_HEADER{
+		newSection(".text", 0x1000, 0x100, 0x400, 0x200, 0x60000020),
+		newSection(".data", 0x2000, 0x80, 0x600, 0x200, 0xC0000040),
+	}}.build(t)
+
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if f.Is64() || f.OptionalHeader32 == nil {
+		t.Fatal("expected a PE32 optional header")
+	}
+	if f.OptionalHeader32.BaseOfData != 0x2000 {
+		t.Errorf("BaseOfData = 0x%X, want 0x2000", f.OptionalHeader32.BaseOfData)
+	}
+	if f.ImageBase() != 0x10000000 {
+		t.Errorf("ImageBase = 0x%X, want 0x10000000", f.ImageBase())
+	}
+	if len(f.Sections) != 2 || f.Sections[1].Name != ".data" {
+		t.Fatalf("Sections = %v, want .text and .data", f.Sections)
+	}
+}
+
+func TestParseOptionalHeaderSize(t *testing.T) {
+	sections := []IMAGE_SECTION_HEADER{newSection(".text", 0x1000, 0x100, 0x400, 0x200, 0x60000020)}
+
+	// A PE32+ header carrying only 2 data directories: the section table
+	// follows 16*8-2*8 bytes earlier than the full struct would suggest.
+	short := testImage{is64: true, sizeOfOptionalHeader: 240 - 14*8, sections: sections,
+		directories: map[int]IMAGE_DATA_DIRECTORY{1: {VirtualAddress: 0x1000, Size: 0x28}}}
+	f, err := Parse(short.build(t))
+	if err != nil {
+		t.Fatalf("Parse short header: %v", err)
+	}
+	if f.Sections[0].Name != ".text" {
+		t.Errorf("short header: first section = %q, want .text", f.Sections[0].Name)
+	}
+	if f.DataDirectory(IMAGE_DIRECTORY_ENTRY_IMPORT).Size != 0x28 {
+		t.Errorf("short header: import directory = %+v", f.DataDirectory(IMAGE_DIRECTORY_ENTRY_IMPORT))
+	}
+	if f.DataDirectory(IMAGE_DIRECTORY_ENTRY_BASERELOC) != (IMAGE_DATA_DIRECTORY{}) {
+		t.Errorf("short header: base reloc directory should be empty")
+	}
+
+	// A header padded past the struct size must still find the section table.
+	long := testImage{sizeOfOptionalHeader: 224 + 32, sections: sections}
+	f, err = Parse(long.build(t))
+	if err != nil {
+		t.Fatalf("Parse long header: %v", err)
+	}
+	if f.Sections[0].Name != ".text" {
+		t.Errorf("long header: first section = %q, want .text", f.Sections[0].Name)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	valid := loadCalcDLL(t)
+
+	tests := []struct {
+		name   string
+		mutate func([]byte) []byte
+		want   error
+	}{
+		{"empty", func(b []byte) []byte { return nil }, ErrTruncated},
+		{"bad MZ", func(b []byte) []byte { b[0] = 'X'; return b }, ErrInvalidDOSSignature},
+		{"bad PE", func(b []byte) []byte { b[0x80] = 'X'; return b }, ErrInvalidNTSignature},
+		{"truncated file header", func(b []byte) []byte { return b[:0x88] }, ErrTruncated},
+		{"no optional header", func(b []byte) []byte {
+			binary.LittleEndian.PutUint16(b[0x94:], 0)
+			return b
+		}, ErrNoOptionalHeader},
+		{"unknown magic", func(b []byte) []byte {
+			binary.LittleEndian.PutUint16(b[0x98:], 0x107)
+			return b
+		}, ErrUnsupportedMagic},
+		{"optional header too short", func(b []byte) []byte {
+			binary.LittleEndian.PutUint16(b[0x94:], 64)
+			return b
+		}, ErrTruncated},
+		{"negative e_lfanew", func(b []byte) []byte {
+			binary.LittleEndian.PutUint32(b[0x3C:], 0xFFFFFFF0)
+			return b
+		}, ErrInvalidLfanew},
+		{"e_lfanew past EOF", func(b []byte) []byte {
+			binary.LittleEndian.PutUint32(b[0x3C:], 0x7FFFFFFF)
+			return b
+		}, ErrInvalidLfanew},
+		{"too many sections", func(b []byte) []byte {
+			binary.LittleEndian.PutUint16(b[0x86:], MaxSections+1)
+			return b
+		}, ErrTooManySections},
+		{"truncated section table", func(b []byte) []byte { return b[:calcSectionTable+5*40] }, ErrTruncated},
+		{"raw data past EOF", func(b []byte) []byte {
+			binary.LittleEndian.PutUint32(b[calcSectionTable+20:], uint32(len(b))-0x10)
+			return b
+		}, ErrSectionPastEOF},
+		{"overlapping sections", func(b []byte) []byte {
+			binary.LittleEndian.PutUint32(b[calcSectionTable+40+12:], 0x1800)
+			return b
+		}, ErrSectionOverlap},
+		{"oversized SizeOfImage", func(b []byte) []byte {
+			binary.LittleEndian.PutUint32(b[calcSizeOfImage:], 0xFFFFFFFF)
+			return b
+		}, ErrSizeOfImage},
+		{"section outside SizeOfImage", func(b []byte) []byte {
+			binary.LittleEndian.PutUint32(b[calcSizeOfImage:], 0x2000)
+			return b
+		}, ErrSizeOfImage},
+		{"SizeOfHeaders past EOF", func(b []byte) []byte {
+			binary.LittleEndian.PutUint32(b[calcSizeOfImage+4:], 0x100000)
+			return b
+		}, ErrTruncated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.mutate(append([]byte(nil), valid...))
+			_, err := Parse(data)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Parse error = %v, want %v", err, tt.want)
+			}
+			var fe *FormatError
+			if !errors.As(err, &fe) {
+				t.Fatalf("Parse error %T is not a *FormatError", err)
+			}
+		})
+	}
+}
+
+// TestDirectoryAbsent runs every directory accessor on an image whose data
+// directories are all empty. Each must report nil, nil rather than an error.
+func TestDirectoryAbsent(t *testing.T) {
+	f := testImage{is64: true}.parse(t)
+	for _, tc := range []struct {
+		name string
+		get  func() (any, error)
+	}{
+		{"DebugDirectory", func() (any, error) { return f.DebugDirectory() }},
+		{"TLSDirectory", func() (any, error) { return f.TLSDirectory() }},
+		{"LoadConfig", func() (any, error) { return f.LoadConfig() }},
+		{"ExceptionTable", func() (any, error) { return f.ExceptionTable() }},
+		{"DelayImports", func() (any, error) { return f.DelayImports() }},
+		{"Resources", func() (any, error) { return f.Resources() }},
+	} {
+		if v, err := tc.get(); err != nil || !reflect.ValueOf(v).IsNil() {
+			t.Errorf("%s = %v, %v; want nil, nil", tc.name, v, err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package pe
+
+import "fmt"
+
+// --- Data Directory Indexes ---
+const (
+	IMAGE_DIRECTORY_ENTRY_EXPORT         = 0
+	IMAGE_DIRECTORY_ENTRY_IMPORT         = 1
+	IMAGE_DIRECTORY_ENTRY_RESOURCE       = 2
+	IMAGE_DIRECTORY_ENTRY_EXCEPTION      = 3
+	IMAGE_DIRECTORY_ENTRY_SECURITY       = 4
+	IMAGE_DIRECTORY_ENTRY_BASERELOC      = 5
+	IMAGE_DIRECTORY_ENTRY_DEBUG          = 6
+	IMAGE_DIRECTORY_ENTRY_ARCHITECTURE   = 7
+	IMAGE_DIRECTORY_ENTRY_GLOBALPTR      = 8
+	IMAGE_DIRECTORY_ENTRY_TLS            = 9
+	IMAGE_DIRECTORY_ENTRY_LOAD_CONFIG    = 10
+	IMAGE_DIRECTORY_ENTRY_BOUND_IMPORT   = 11
+	IMAGE_DIRECTORY_ENTRY_IAT            = 12
+	IMAGE_DIRECTORY_ENTRY_DELAY_IMPORT   = 13
+	IMAGE_DIRECTORY_ENTRY_COM_DESCRIPTOR = 14
+)
+
+// DirectoryNames holds the canonical name of each data directory, indexed
+// by the IMAGE_DIRECTORY_ENTRY_* constants.
+var DirectoryNames = [IMAGE_NUMBEROF_DIRECTORY_ENTRIES]string{
+	"Export", "Import", "Resource", "Exception", "Security", "BaseReloc", "Debug", "Architecture",
+	"GlobalPtr", "TLS", "LoadConfig", "BoundImport", "IAT", "DelayImport", "CLR", "Reserved",
+}
+
+// --- File Header Characteristics ---
+const (
+	IMAGE_FILE_RELOCS_STRIPPED         = 0x0001
+	IMAGE_FILE_EXECUTABLE_IMAGE        = 0x0002
+	IMAGE_FILE_LINE_NUMS_STRIPPED      = 0x0004
+	IMAGE_FILE_LOCAL_SYMS_STRIPPED     = 0x0008
+	IMAGE_FILE_AGGRESSIVE_WS_TRIM      = 0x0010
+	IMAGE_FILE_LARGE_ADDRESS_AWARE     = 0x0020
+	IMAGE_FILE_BYTES_REVERSED_LO       = 0x0080
+	IMAGE_FILE_32BIT_MACHINE           = 0x0100
+	IMAGE_FILE_DEBUG_STRIPPED          = 0x0200
+	IMAGE_FILE_REMOVABLE_RUN_FROM_SWAP = 0x0400
+	IMAGE_FILE_NET_RUN_FROM_SWAP       = 0x0800
+	IMAGE_FILE_SYSTEM                  = 0x1000
+	IMAGE_FILE_DLL                     = 0x2000
+	IMAGE_FILE_UP_SYSTEM_ONLY          = 0x4000
+	IMAGE_FILE_BYTES_REVERSED_HI       = 0x8000
+)
+
+// --- Optional Header DllCharacteristics ---
+const (
+	IMAGE_DLLCHARACTERISTICS_HIGH_ENTROPY_VA       = 0x0020
+	IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE          = 0x0040
+	IMAGE_DLLCHARACTERISTICS_FORCE_INTEGRITY       = 0x0080
+	IMAGE_DLLCHARACTERISTICS_NX_COMPAT             = 0x0100
+	IMAGE_DLLCHARACTERISTICS_NO_ISOLATION          = 0x0200
+	IMAGE_DLLCHARACTERISTICS_NO_SEH                = 0x0400
+	IMAGE_DLLCHARACTERISTICS_NO_BIND               = 0x0800
+	IMAGE_DLLCHARACTERISTICS_APPCONTAINER          = 0x1000
+	IMAGE_DLLCHARACTERISTICS_WDM_DRIVER            = 0x2000
+	IMAGE_DLLCHARACTERISTICS_GUARD_CF              = 0x4000
+	IMAGE_DLLCHARACTERISTICS_TERMINAL_SERVER_AWARE = 0x8000
+)
+
+// --- Section Characteristics ---
+const (
+	IMAGE_SCN_TYPE_NO_PAD            = 0x00000008
+	IMAGE_SCN_CNT_CODE               = 0x00000020
+	IMAGE_SCN_CNT_INITIALIZED_DATA   = 0x00000040
+	IMAGE_SCN_CNT_UNINITIALIZED_DATA = 0x00000080
+	IMAGE_SCN_LNK_OTHER              = 0x00000100
+	IMAGE_SCN_LNK_INFO               = 0x00000200
+	IMAGE_SCN_LNK_REMOVE             = 0x00000800
+	IMAGE_SCN_LNK_COMDAT             = 0x00001000
+	IMAGE_SCN_GPREL                  = 0x00008000
+	IMAGE_SCN_ALIGN_MASK             = 0x00F00000 // 4-bit alignment field, not a flag
+	IMAGE_SCN_LNK_NRELOC_OVFL        = 0x01000000
+	IMAGE_SCN_MEM_DISCARDABLE        = 0x02000000
+	IMAGE_SCN_MEM_NOT_CACHED         = 0x04000000
+	IMAGE_SCN_MEM_NOT_PAGED          = 0x08000000
+	IMAGE_SCN_MEM_SHARED             = 0x10000000
+	IMAGE_SCN_MEM_EXECUTE            = 0x20000000
+	IMAGE_SCN_MEM_READ               = 0x40000000
+	IMAGE_SCN_MEM_WRITE              = 0x80000000
+)
+
+// flagName pairs a bit with the name printed for it.
+type flagName struct {
+	bit  uint32
+	name string
+}
+
+var fileCharacteristicNames = []flagName{
+	{IMAGE_FILE_RELOCS_STRIPPED, "RELOCS_STRIPPED"},
+	{IMAGE_FILE_EXECUTABLE_IMAGE, "EXECUTABLE_IMAGE"},
+	{IMAGE_FILE_LINE_NUMS_STRIPPED, "LINE_NUMS_STRIPPED"},
+	{IMAGE_FILE_LOCAL_SYMS_STRIPPED, "LOCAL_SYMS_STRIPPED"},
+	{IMAGE_FILE_AGGRESSIVE_WS_TRIM, "AGGRESSIVE_WS_TRIM"},
+	{IMAGE_FILE_LARGE_ADDRESS_AWARE, "LARGE_ADDRESS_AWARE"},
+	{IMAGE_FILE_BYTES_REVERSED_LO, "BYTES_REVERSED_LO"},
+	{IMAGE_FILE_32BIT_MACHINE, "32BIT_MACHINE"},
+	{IMAGE_FILE_DEBUG_STRIPPED, "DEBUG_STRIPPED"},
+	{IMAGE_FILE_REMOVABLE_RUN_FROM_SWAP, "REMOVABLE_RUN_FROM_SWAP"},
+	{IMAGE_FILE_NET_RUN_FROM_SWAP, "NET_RUN_FROM_SWAP"},
+	{IMAGE_FILE_SYSTEM, "SYSTEM"},
+	{IMAGE_FILE_DLL, "DLL"},
+	{IMAGE_FILE_UP_SYSTEM_ONLY, "UP_SYSTEM_ONLY"},
+	{IMAGE_FILE_BYTES_REVERSED_HI, "BYTES_REVERSED_HI"},
+}
+
+var dllCharacteristicNames = []flagName{
+	{IMAGE_DLLCHARACTERISTICS_HIGH_ENTROPY_VA, "HIGH_ENTROPY_VA"},
+	{IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE, "DYNAMIC_BASE"},
+	{IMAGE_DLLCHARACTERISTICS_FORCE_INTEGRITY, "FORCE_INTEGRITY"},
+	{IMAGE_DLLCHARACTERISTICS_NX_COMPAT, "NX_COMPAT"},
+	{IMAGE_DLLCHARACTERISTICS_NO_ISOLATION, "NO_ISOLATION"},
+	{IMAGE_DLLCHARACTERISTICS_NO_SEH, "NO_SEH"},
+	{IMAGE_DLLCHARACTERISTICS_NO_BIND, "NO_BIND"},
+	{IMAGE_DLLCHARACTERISTICS_APPCONTAINER, "APPCONTAINER"},
+	{IMAGE_DLLCHARACTERISTICS_WDM_DRIVER, "WDM_DRIVER"},
+	{IMAGE_DLLCHARACTERISTICS_GUARD_CF, "GUARD_CF"},
+	{IMAGE_DLLCHARACTERISTICS_TERMINAL_SERVER_AWARE, "TERMINAL_SERVER_AWARE"},
+}
+
+var sectionCharacteristicNames = []flagName{
+	{IMAGE_SCN_TYPE_NO_PAD, "TYPE_NO_PAD"},
+	{IMAGE_SCN_CNT_CODE, "CNT_CODE"},
+	{IMAGE_SCN_CNT_INITIALIZED_DATA, "CNT_INITIALIZED_DATA"},
+	{IMAGE_SCN_CNT_UNINITIALIZED_DATA, "CNT_UNINITIALIZED_DATA"},
+	{IMAGE_SCN_LNK_OTHER, "LNK_OTHER"},
+	{IMAGE_SCN_LNK_INFO, "LNK_INFO"},
+	{IMAGE_SCN_LNK_REMOVE, "LNK_REMOVE"},
+	{IMAGE_SCN_LNK_COMDAT, "LNK_COMDAT"},
+	{IMAGE_SCN_GPREL, "GPREL"},
+	{IMAGE_SCN_LNK_NRELOC_OVFL, "LNK_NRELOC_OVFL"},
+	{IMAGE_SCN_MEM_DISCARDABLE, "MEM_DISCARDABLE"},
+	{IMAGE_SCN_MEM_NOT_CACHED, "MEM_NOT_CACHED"},
+	{IMAGE_SCN_MEM_NOT_PAGED, "MEM_NOT_PAGED"},
+	{IMAGE_SCN_MEM_SHARED, "MEM_SHARED"},
+	{IMAGE_SCN_MEM_EXECUTE, "MEM_EXECUTE"},
+	{IMAGE_SCN_MEM_READ, "MEM_READ"},
+	{IMAGE_SCN_MEM_WRITE, "MEM_WRITE"},
+}
+
+// decodeFlags names every set bit in value. Bits without a known name are
+// reported together as a single hex remainder.
+func decodeFlags(value uint32, names []flagName) []string {
+	var out []string
+	for _, fn := range names {
+		if value&fn.bit != 0 {
+			out = append(out, fn.name)
+			value &^= fn.bit
+		}
+	}
+	if value != 0 {
+		out = append(out, fmt.Sprintf("0x%X", value))
+	}
+	return out
+}
+
+// FileCharacteristicsToStrings decodes IMAGE_FILE_HEADER.Characteristics.
+func FileCharacteristicsToStrings(characteristics uint16) []string {
+	return decodeFlags(uint32(characteristics), fileCharacteristicNames)
+}
+
+// DllCharacteristicsToStrings decodes the optional header DllCharacteristics.
+func DllCharacteristicsToStrings(characteristics uint16) []string {
+	return decodeFlags(uint32(characteristics), dllCharacteristicNames)
+}
+
+// SectionCharacteristicsToStrings decodes IMAGE_SECTION_HEADER.Characteristics.
+// The alignment nibble (bits 20-23) is a value rather than a flag, so it is
+// reported as ALIGN_<n>BYTES ahead of the flag names.
+func SectionCharacteristicsToStrings(characteristics uint32) []string {
+	var out []string
+	if nibble := (characteristics & IMAGE_SCN_ALIGN_MASK) >> 20; nibble != 0 {
+		if nibble <= 14 {
+			out = append(out, fmt.Sprintf("ALIGN_%dBYTES", 1<<(nibble-1)))
+		} else {
+			out = append(out, fmt.Sprintf("ALIGN_INVALID(0x%X)", nibble))
+		}
+	}
+	return append(out, decodeFlags(characteristics&^IMAGE_SCN_ALIGN_MASK, sectionCharacteristicNames)...)
+}
+
+// DataDirectoryEntry is a data directory together with its canonical name
+// and the section that holds it.
+type DataDirectoryEntry struct {
+	IMAGE_DATA_DIRECTORY
+	Index   int
+	Name    string
+	Section *Section // nil when empty, in the headers, or outside every section
+}
+
+// DataDirectories returns all 16 data directory entries with their names.
+// The Security entry holds a file offset rather than an RVA, so its section
+// is found by file offset; every other entry is resolved as an RVA.
+func (f *File) DataDirectories() []DataDirectoryEntry {
+	entries := make([]DataDirectoryEntry, IMAGE_NUMBEROF_DIRECTORY_ENTRIES)
+	for i := range entries {
+		dir := f.DataDirectory(i)
+		entries[i] = DataDirectoryEntry{IMAGE_DATA_DIRECTORY: dir, Index: i, Name: DirectoryNames[i]}
+		if dir.VirtualAddress == 0 {
+			continue
+		}
+		if i == IMAGE_DIRECTORY_ENTRY_SECURITY {
+			entries[i].Section = f.TranslateOffset(dir.VirtualAddress).Section
+		} else {
+			entries[i].Section = f.TranslateRVA(dir.VirtualAddress).Section
+		}
+	}
+	return entries
+}
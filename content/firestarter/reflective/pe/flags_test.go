@@ -0,0 +1,60 @@
+package pe
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeCharacteristics(t *testing.T) {
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"file", FileCharacteristicsToStrings(0x2026),
+			[]string{"EXECUTABLE_IMAGE", "LINE_NUMS_STRIPPED", "LARGE_ADDRESS_AWARE", "DLL"}},
+		{"dll", DllCharacteristicsToStrings(0x4160),
+			[]string{"HIGH_ENTROPY_VA", "DYNAMIC_BASE", "NX_COMPAT", "GUARD_CF"}},
+		{"text", SectionCharacteristicsToStrings(0x60500020),
+			[]string{"ALIGN_16BYTES", "CNT_CODE", "MEM_EXECUTE", "MEM_READ"}},
+		{"discardable", SectionCharacteristicsToStrings(0x42000040),
+			[]string{"CNT_INITIALIZED_DATA", "MEM_DISCARDABLE", "MEM_READ"}},
+		{"unknown bit", SectionCharacteristicsToStrings(0x00000001), []string{"0x1"}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestDataDirectories(t *testing.T) {
+	f, err := Parse(loadCalcDLL(t))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	dirs := f.DataDirectories()
+	if len(dirs) != IMAGE_NUMBEROF_DIRECTORY_ENTRIES {
+		t.Fatalf("got %d directories, want 16", len(dirs))
+	}
+	want := map[int]string{
+		IMAGE_DIRECTORY_ENTRY_EXPORT:    ".edata",
+		IMAGE_DIRECTORY_ENTRY_IMPORT:    ".idata",
+		IMAGE_DIRECTORY_ENTRY_EXCEPTION: ".pdata",
+		IMAGE_DIRECTORY_ENTRY_BASERELOC: ".reloc",
+		IMAGE_DIRECTORY_ENTRY_TLS:       ".rdata",
+		IMAGE_DIRECTORY_ENTRY_IAT:       ".idata",
+	}
+	for i, d := range dirs {
+		if d.Name != DirectoryNames[i] {
+			t.Errorf("dirs[%d].Name = %q, want %q", i, d.Name, DirectoryNames[i])
+		}
+		got := ""
+		if d.Section != nil {
+			got = d.Section.Name
+		}
+		if got != want[i] {
+			t.Errorf("%s directory in section %q, want %q", d.Name, got, want[i])
+		}
+	}
+}
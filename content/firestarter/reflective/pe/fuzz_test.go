@@ -0,0 +1,80 @@
+package pe
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// FuzzParse feeds arbitrary bytes through Parse and every directory walker.
+// None of them may panic, and anything Parse accepts must satisfy the
+// bounds the mappers rely on. Run with:
+//
+//	go test ./pe -run '^$' -fuzz FuzzParse
+func FuzzParse(f *testing.F) {
+	calc := loadCalcDLL(f)
+
+	// Seed with the lab DLL, a signed PE32 executable, the DLL's headers
+	// alone, and a few copies with the fields hostile files usually target
+	// already broken, so the fuzzer starts next to each check rather than
+	// having to find it.
+	f.Add(calc)
+	f.Add(loadSignedEXE(f))
+	f.Add(calc[:calcSectionTable+19*40])
+	f.Add(calc[:0x600])
+	for _, field := range []struct {
+		offset int
+		value  uint32
+	}{
+		{0x3C, 0xFFFFFFFF},                  // e_lfanew
+		{calcSizeOfImage, 0xFFFFFFFF},       // SizeOfImage
+		{calcSectionTable + 16, 0x7FFFFFFF}, // .text SizeOfRawData
+		{calcSectionTable + 20, 0xFFFFFF00}, // .text PointerToRawData
+		{0x80 + 4 + 20 + 112 + 4, 0xFFFF},   // Import directory size
+	} {
+		seed := append([]byte(nil), calc...)
+		binary.LittleEndian.PutUint32(seed[field.offset:], field.value)
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		pf, err := Parse(data)
+		if err != nil {
+			return
+		}
+		if uint64(pf.SizeOfHeaders()) > uint64(len(data)) {
+			t.Fatalf("accepted SizeOfHeaders 0x%X past EOF 0x%X", pf.SizeOfHeaders(), len(data))
+		}
+		for _, s := range pf.Sections {
+			if s.SizeOfRawData != 0 && uint64(s.PointerToRawData)+uint64(s.SizeOfRawData) > uint64(len(data)) {
+				t.Fatalf("accepted section %q with raw data past EOF", s.Name)
+			}
+			_ = pf.SectionData(s)
+		}
+		pf.Imports()
+		pf.DelayImports()
+		pf.Exports()
+		pf.ComputeChecksum()
+		pf.Imphash()
+		pf.ExportHash()
+		TLSH(data)
+		pf.RichHeader()
+		pf.Overlay()
+		pf.Resources()
+		pf.VersionInfo()
+		pf.Manifest()
+		pf.DebugDirectory()
+		pf.TLSDirectory()
+		if funcs, err := pf.ExceptionTable(); err == nil {
+			pf.CheckRuntimeFunctions(funcs)
+		}
+		if lc, err := pf.LoadConfig(); err == nil && lc != nil {
+			pf.GuardCFFunctions(lc)
+			pf.SEHandlers(lc)
+		}
+		if sigs, _ := pf.Signatures(); len(sigs) > 0 {
+			pf.VerifyDigest(sigs[0])
+		}
+		pf.DataDirectories()
+		pf.TranslateRVA(pf.AddressOfEntryPoint())
+	})
+}
@@ -0,0 +1,98 @@
+package pe
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ordinalTables maps lowercase DLL names to the ordinal tables used to name
+// imports by ordinal when computing the imphash.
+var ordinalTables = map[string]map[uint16]string{
+	"ws2_32.dll":   winsockOrdinals,
+	"wsock32.dll":  winsockOrdinals,
+	"oleaut32.dll": oleautOrdinals,
+}
+
+// Imphash returns the import hash introduced by Mandiant and computed by
+// pefile: the MD5 of "dll.function" entries, in import table order, joined
+// with commas. DLL names are lowercased and lose a .dll, .ocx or .sys
+// extension; function names are lowercased; imports by ordinal are named
+// from the ws2_32/wsock32/oleaut32 tables, or become "ord<n>" otherwise.
+// Images without imports have no imphash and return "".
+func (f *File) Imphash() (string, error) {
+	dlls, err := f.Imports()
+	if err != nil {
+		return "", err
+	}
+	var entries []string
+	for _, dll := range dlls {
+		lower := strings.ToLower(dll.Name)
+		lib := lower
+		if dot := strings.LastIndexByte(lib, '.'); dot != -1 {
+			switch lib[dot+1:] {
+			case "dll", "ocx", "sys":
+				lib = lib[:dot]
+			}
+		}
+		for _, fn := range dll.Functions {
+			name := fn.Name
+			if fn.ByOrdinal {
+				name = ordinalTables[lower][fn.Ordinal]
+				if name == "" {
+					name = fmt.Sprintf("ord%d", fn.Ordinal)
+				}
+			}
+			entries = append(entries, lib+"."+strings.ToLower(name))
+		}
+	}
+	if len(entries) == 0 {
+		return "", nil
+	}
+	sum := md5.Sum([]byte(strings.Join(entries, ",")))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ExportHash returns the SHA-256 of the lowercase export names joined with
+// commas, in name pointer table order, the way pefile's exphash does. The
+// linker sorts that table, but nothing enforces it, so a hand-edited image
+// hashes exactly as stored. Like the imphash it survives recompilation, so
+// it groups builds of the same DLL. Images without named exports return "".
+func (f *File) ExportHash() (string, error) {
+	exports, err := f.Exports()
+	if err != nil {
+		return "", err
+	}
+	if exports == nil || len(exports.Names) == 0 {
+		return "", nil
+	}
+	names := make([]string, len(exports.Names))
+	for i, name := range exports.Names {
+		names[i] = strings.ToLower(name)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(names, ",")))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// SectionHashes are digests of one section's raw data as stored in the
+// file, which is what most sandboxes and VirusTotal report.
+type SectionHashes struct {
+	MD5    string
+	SHA256 string
+	TLSH   string // "" when the section is too small or uniform for a digest
+}
+
+// SectionHashes returns the MD5, SHA-256 and TLSH of s's raw data. Sections
+// with no raw data hash as empty input.
+func (f *File) SectionHashes(s *Section) SectionHashes {
+	data := f.SectionData(s)
+	md5sum := md5.Sum(data)
+	sha := sha256.Sum256(data)
+	h := SectionHashes{MD5: hex.EncodeToString(md5sum[:]), SHA256: hex.EncodeToString(sha[:])}
+	if d, err := TLSH(data); err == nil {
+		h.TLSH = d.String()
+	}
+	return h
+}
@@ -0,0 +1,118 @@
+package pe
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestFingerprintsCalcDLL(t *testing.T) {
+	f, err := Parse(loadCalcDLL(t))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got, err := f.Imphash(); err != nil || got != "0d27f94d5f33ca0dbee7f18e70b955fd" {
+		t.Errorf("Imphash = %q, %v", got, err)
+	}
+	if got, err := f.ExportHash(); err != nil || got != "82982ac2e9e63bc5305119e1be8d0ebee2cdda641da906f9f9ddc85af42339c9" {
+		t.Errorf("ExportHash = %q, %v", got, err)
+	}
+	text := f.SectionHashes(f.Section(".text"))
+	if text.MD5 != "40a9afb4fb4418c8cc3e71e345441939" ||
+		text.SHA256 != "3947bc750198420921373ed1cde66f3051666cdb959d499ca29344a95cadf046" {
+		t.Errorf(".text hashes = %+v", text)
+	}
+
+	// The signed EXE exports nothing.
+	f, err = Parse(loadSignedEXE(t))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got, err := f.ExportHash(); err != nil || got != "" {
+		t.Errorf("ExportHash without exports = %q, %v; want empty", got, err)
+	}
+}
+
+// TestImphashNormalisation imports by ordinal from the DLLs with ordinal
+// tables, and from DLLs whose extensions are or aren't stripped.
+func TestImphashNormalisation(t *testing.T) {
+	const rva, raw = 0x1000, 0x400
+	idata := make([]byte, 0x200)
+	put32 := func(off int, v uint32) { binary.LittleEndian.PutUint32(idata[off:], v) }
+
+	// Descriptors every 0x14 bytes from 0 (Name at +0x0C, FirstThunk at
+	// +0x10), thunk arrays from 0x80 and names from 0x100.
+	descriptors := []struct {
+		name   uint32
+		thunks []uint32
+	}{
+		{0x140, []uint32{IMAGE_ORDINAL_FLAG32 | 23, IMAGE_ORDINAL_FLAG32 | 999}},
+		{0x150, []uint32{IMAGE_ORDINAL_FLAG32 | 2}},
+		{0x160, []uint32{rva + 0x100}},
+		{0x170, []uint32{IMAGE_ORDINAL_FLAG32 | 5}},
+	}
+	for i, d := range descriptors {
+		thunks := 0x80 + i*0x10
+		put32(i*0x14+0x0C, rva+d.name)
+		put32(i*0x14+0x10, rva+uint32(thunks))
+		for j, v := range d.thunks {
+			put32(thunks+j*4, v)
+		}
+	}
+	copy(idata[0x102:], "InitCommonControls\x00")
+	copy(idata[0x140:], "WS2_32.DLL\x00")
+	copy(idata[0x150:], "OleAut32.dll\x00")
+	copy(idata[0x160:], "COMCTL.OCX\x00")
+	copy(idata[0x170:], "helper.exe\x00")
+
+	data := testImage{
+		sections:    []IMAGE_SECTION_HEADER{newSection(".idata", rva, 0x200, raw, 0x200, 0xC0000040)},
+		directories: map[int]IMAGE_DATA_DIRECTORY{IMAGE_DIRECTORY_ENTRY_IMPORT: {VirtualAddress: rva, Size: 0x64}},
+		sectionData: map[int][]byte{0: idata},
+	}.build(t)
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	// md5("ws2_32.socket,ws2_32.ord999,oleaut32.sysallocstring,comctl.initcommoncontrols,helper.exe.ord5")
+	if got, err := f.Imphash(); err != nil || got != "5c12ef2ac71fbb02c4883fa7ca6ee690" {
+		t.Errorf("Imphash = %q, %v", got, err)
+	}
+
+	f, err = Parse(testImage{}.build(t))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got, err := f.Imphash(); err != nil || got != "" {
+		t.Errorf("Imphash without imports = %q, %v; want empty", got, err)
+	}
+}
+
+// TestExportHashTableOrder hashes a name pointer table that isn't sorted, as
+// a hand-edited image might have. pefile hashes the names as stored:
+// sha256("zeta,alpha,mid").
+func TestExportHashTableOrder(t *testing.T) {
+	const rva, raw = 0x2000, 0x600
+	edata := make([]byte, 0x200)
+	put32 := func(off int, v uint32) { binary.LittleEndian.PutUint32(edata[off:], v) }
+	put32(0x10, 1)        // Base
+	put32(0x14, 3)        // NumberOfFunctions
+	put32(0x18, 3)        // NumberOfNames
+	put32(0x1C, rva+0x40) // AddressOfFunctions
+	put32(0x20, rva+0x50) // AddressOfNames
+	put32(0x24, rva+0x60) // AddressOfNameOrdinals
+	for i, name := range []string{"Zeta", "alpha", "Mid"} {
+		put32(0x40+i*4, 0x1000+uint32(i)*0x10)
+		put32(0x50+i*4, rva+0x80+uint32(i)*0x10)
+		binary.LittleEndian.PutUint16(edata[0x60+i*2:], uint16(i))
+		copy(edata[0x80+i*0x10:], name+"\x00")
+	}
+	f := testImage{
+		is64:        true,
+		sections:    withText(newSection(".edata", rva, 0x200, raw, 0x200, 0x40000040)),
+		directories: map[int]IMAGE_DATA_DIRECTORY{IMAGE_DIRECTORY_ENTRY_EXPORT: {VirtualAddress: rva, Size: 0x100}},
+		sectionData: map[int][]byte{1: edata},
+	}.parse(t)
+	if got, err := f.ExportHash(); err != nil || got != "90df5a690db499a3ac0e54ae39db3964b2a6678e5d52d19cc776dfa753c529c8" {
+		t.Errorf("ExportHash = %q, %v", got, err)
+	}
+}
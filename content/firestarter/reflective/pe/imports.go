@@ -0,0 +1,129 @@
+package pe
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ImportedDLL is one IMAGE_IMPORT_DESCRIPTOR: the DLL it names and the
+// functions pulled from it.
+type ImportedDLL struct {
+	Name       string
+	Descriptor IMAGE_IMPORT_DESCRIPTOR
+	Functions  []ImportedFunction
+}
+
+// ImportedFunction is a single thunk from an import lookup table. Functions
+// imported by name carry a Hint (an index into the exporting DLL's name
+// table); functions imported by ordinal carry only the Ordinal.
+type ImportedFunction struct {
+	Name      string
+	Hint      uint16
+	Ordinal   uint16
+	ByOrdinal bool
+	ThunkRVA  uint32 // RVA of the IAT slot the loader patches for this import
+}
+
+// Imports walks the import directory in the file on disk, without loading
+// anything. For each descriptor the Import Lookup Table (OriginalFirstThunk)
+// is preferred; images that only populate FirstThunk are walked through the
+// IAT instead, which holds the same thunks until the loader binds it.
+func (f *File) Imports() ([]ImportedDLL, error) {
+	dir := f.DataDirectory(IMAGE_DIRECTORY_ENTRY_IMPORT)
+	if dir.VirtualAddress == 0 {
+		return nil, nil
+	}
+
+	var dlls []ImportedDLL
+	descriptorSize := uint32(binary.Size(IMAGE_IMPORT_DESCRIPTOR{}))
+	for rva := dir.VirtualAddress; ; rva += descriptorSize {
+		var desc IMAGE_IMPORT_DESCRIPTOR
+		if err := f.structAtRVA(rva, &desc); err != nil {
+			return dlls, err
+		}
+		// The table ends with an all-zero descriptor
+		if desc == (IMAGE_IMPORT_DESCRIPTOR{}) {
+			break
+		}
+
+		name, err := f.stringAtRVA(desc.Name)
+		if err != nil {
+			return dlls, err
+		}
+		dll := ImportedDLL{Name: name, Descriptor: desc}
+
+		lookupRVA := desc.OriginalFirstThunk
+		if lookupRVA == 0 {
+			lookupRVA = desc.FirstThunk
+		}
+		dll.Functions, err = f.readThunks(lookupRVA, desc.FirstThunk, 0)
+		dlls = append(dlls, dll)
+		if err != nil {
+			return dlls, err
+		}
+	}
+	return dlls, nil
+}
+
+// readThunks decodes a null-terminated thunk array starting at lookupRVA.
+// iatRVA is the matching IAT, used only to report where each import lands.
+// base is subtracted from by-name thunks, for tables that hold VAs.
+func (f *File) readThunks(lookupRVA, iatRVA uint32, base uint64) ([]ImportedFunction, error) {
+	thunkSize := uint32(4)
+	if f.Is64() {
+		thunkSize = 8
+	}
+
+	var funcs []ImportedFunction
+	for i := uint32(0); ; i++ {
+		thunkRVA := lookupRVA + i*thunkSize
+		var thunk uint64
+		var byOrdinal bool
+		if f.Is64() {
+			v, err := f.uint64AtRVA(thunkRVA)
+			if err != nil {
+				return funcs, err
+			}
+			thunk, byOrdinal = v, v&IMAGE_ORDINAL_FLAG64 != 0
+		} else {
+			v, err := f.uint32AtRVA(thunkRVA)
+			if err != nil {
+				return funcs, err
+			}
+			thunk, byOrdinal = uint64(v), v&IMAGE_ORDINAL_FLAG32 != 0
+		}
+		if thunk == 0 {
+			break
+		}
+
+		fn := ImportedFunction{ThunkRVA: iatRVA + i*thunkSize}
+		if byOrdinal {
+			fn.ByOrdinal = true
+			fn.Ordinal = uint16(thunk & 0xFFFF)
+		} else {
+			// The thunk is the RVA of an IMAGE_IMPORT_BY_NAME: a 16-bit
+			// hint followed by the null-terminated function name.
+			nameRVA := uint32((thunk - base) & 0x7FFFFFFF)
+			hint, err := f.uint16AtRVA(nameRVA)
+			if err != nil {
+				return funcs, err
+			}
+			name, err := f.stringAtRVA(nameRVA + 2)
+			if err != nil {
+				return funcs, err
+			}
+			fn.Hint, fn.Name = hint, name
+		}
+		funcs = append(funcs, fn)
+	}
+	return funcs, nil
+}
+
+// String renders an import the way the labs print it: by name with its hint,
+// or as an ordinal.
+func (fn ImportedFunction) String() string {
+	if fn.ByOrdinal {
+		return fmt.Sprintf("Ordinal #%d", fn.Ordinal)
+	}
+	return fmt.Sprintf("%s (Hint: %d)", fn.Name, fn.Hint)
+}
@@ -0,0 +1,73 @@
+package pe
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestImportsCalcDLL(t *testing.T) {
+	f, err := Parse(loadCalcDLL(t))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	dlls, err := f.Imports()
+	if err != nil {
+		t.Fatalf("Imports: %v", err)
+	}
+	if len(dlls) == 0 || dlls[0].Name != "KERNEL32.dll" {
+		t.Fatalf("first import = %+v, want KERNEL32.dll", dlls)
+	}
+	k32 := dlls[0]
+	if len(k32.Functions) != 11 {
+		t.Fatalf("KERNEL32.dll has %d imports, want 11", len(k32.Functions))
+	}
+	first := k32.Functions[0]
+	if first.Name != "DeleteCriticalSection" || first.Hint != 292 || first.ThunkRVA != 0x9220 {
+		t.Errorf("first KERNEL32 import = %+v, want DeleteCriticalSection hint 292 at IAT 0x9220", first)
+	}
+}
+
+// TestImportsFirstThunkOnly builds a PE32 image whose descriptor has no
+// OriginalFirstThunk and which imports one function by ordinal.
+func TestImportsFirstThunkOnly(t *testing.T) {
+	const rva, raw = 0x1000, 0x400
+	idata := make([]byte, 0x200)
+	put32 := func(off int, v uint32) { binary.LittleEndian.PutUint32(idata[off:], v) }
+
+	// Descriptor at 0x00, terminator at 0x14; IAT at 0x40; name strings after.
+	put32(0x0C, rva+0x80) // Name
+	put32(0x10, rva+0x40) // FirstThunk
+	put32(0x40, rva+0x60) // By name
+	put32(0x44, IMAGE_ORDINAL_FLAG32|7)
+	binary.LittleEndian.PutUint16(idata[0x60:], 3)
+	copy(idata[0x62:], "send\x00")
+	copy(idata[0x80:], "WS2_32.dll\x00")
+
+	data := testImage{
+		sections:    []IMAGE_SECTION_HEADER{newSection(".idata", rva, 0x200, raw, 0x200, 0xC0000040)},
+		directories: map[int]IMAGE_DATA_DIRECTORY{IMAGE_DIRECTORY_ENTRY_IMPORT: {VirtualAddress: rva, Size: 0x28}},
+		sectionData: map[int][]byte{0: idata},
+	}.build(t)
+
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	dlls, err := f.Imports()
+	if err != nil {
+		t.Fatalf("Imports: %v", err)
+	}
+	if len(dlls) != 1 || dlls[0].Name != "WS2_32.dll" {
+		t.Fatalf("imports = %+v, want WS2_32.dll", dlls)
+	}
+	fns := dlls[0].Functions
+	if len(fns) != 2 {
+		t.Fatalf("got %d functions, want 2", len(fns))
+	}
+	if fns[0].Name != "send" || fns[0].Hint != 3 {
+		t.Errorf("fns[0] = %+v, want send hint 3", fns[0])
+	}
+	if !fns[1].ByOrdinal || fns[1].Ordinal != 7 || fns[1].ThunkRVA != rva+0x44 {
+		t.Errorf("fns[1] = %+v, want ordinal 7 at 0x%X", fns[1], rva+0x44)
+	}
+}
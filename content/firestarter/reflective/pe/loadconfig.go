@@ -0,0 +1,351 @@
+package pe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+)
+
+type IMAGE_LOAD_CONFIG_CODE_INTEGRITY struct { //nolint:revive // Windows struct
+	Flags         uint16
+	Catalog       uint16
+	CatalogOffset uint32
+	Reserved      uint32
+}
+
+// IMAGE_LOAD_CONFIG_DIRECTORY32 and IMAGE_LOAD_CONFIG_DIRECTORY64 are the
+// newest layouts. Every release has appended fields, and an image only
+// contains the first Size bytes, so the tail of an older image's directory
+// reads as zero.
+type IMAGE_LOAD_CONFIG_DIRECTORY32 struct { //nolint:revive // Windows struct
+	Size                                     uint32
+	TimeDateStamp                            uint32
+	MajorVersion                             uint16
+	MinorVersion                             uint16
+	GlobalFlagsClear                         uint32
+	GlobalFlagsSet                           uint32
+	CriticalSectionDefaultTimeout            uint32
+	DeCommitFreeBlockThreshold               uint32
+	DeCommitTotalFreeThreshold               uint32
+	LockPrefixTable                          uint32
+	MaximumAllocationSize                    uint32
+	VirtualMemoryThreshold                   uint32
+	ProcessHeapFlags                         uint32
+	ProcessAffinityMask                      uint32
+	CSDVersion                               uint16
+	DependentLoadFlags                       uint16
+	EditList                                 uint32
+	SecurityCookie                           uint32
+	SEHandlerTable                           uint32
+	SEHandlerCount                           uint32
+	GuardCFCheckFunctionPointer              uint32
+	GuardCFDispatchFunctionPointer           uint32
+	GuardCFFunctionTable                     uint32
+	GuardCFFunctionCount                     uint32
+	GuardFlags                               uint32
+	CodeIntegrity                            IMAGE_LOAD_CONFIG_CODE_INTEGRITY
+	GuardAddressTakenIatEntryTable           uint32
+	GuardAddressTakenIatEntryCount           uint32
+	GuardLongJumpTargetTable                 uint32
+	GuardLongJumpTargetCount                 uint32
+	DynamicValueRelocTable                   uint32
+	CHPEMetadataPointer                      uint32
+	GuardRFFailureRoutine                    uint32
+	GuardRFFailureRoutineFunctionPointer     uint32
+	DynamicValueRelocTableOffset             uint32
+	DynamicValueRelocTableSection            uint16
+	Reserved2                                uint16
+	GuardRFVerifyStackPointerFunctionPointer uint32
+	HotPatchTableOffset                      uint32
+	Reserved3                                uint32
+	EnclaveConfigurationPointer              uint32
+	VolatileMetadataPointer                  uint32
+	GuardEHContinuationTable                 uint32
+	GuardEHContinuationCount                 uint32
+	GuardXFGCheckFunctionPointer             uint32
+	GuardXFGDispatchFunctionPointer          uint32
+	GuardXFGTableDispatchFunctionPointer     uint32
+	CastGuardOsDeterminedFailureMode         uint32
+	GuardMemcpyFunctionPointer               uint32
+}
+
+type IMAGE_LOAD_CONFIG_DIRECTORY64 struct { //nolint:revive // Windows struct
+	Size                                     uint32
+	TimeDateStamp                            uint32
+	MajorVersion                             uint16
+	MinorVersion                             uint16
+	GlobalFlagsClear                         uint32
+	GlobalFlagsSet                           uint32
+	CriticalSectionDefaultTimeout            uint32
+	DeCommitFreeBlockThreshold               uint64
+	DeCommitTotalFreeThreshold               uint64
+	LockPrefixTable                          uint64
+	MaximumAllocationSize                    uint64
+	VirtualMemoryThreshold                   uint64
+	ProcessAffinityMask                      uint64
+	ProcessHeapFlags                         uint32
+	CSDVersion                               uint16
+	DependentLoadFlags                       uint16
+	EditList                                 uint64
+	SecurityCookie                           uint64
+	SEHandlerTable                           uint64 // Always zero: x64 unwinds through .pdata instead
+	SEHandlerCount                           uint64
+	GuardCFCheckFunctionPointer              uint64
+	GuardCFDispatchFunctionPointer           uint64
+	GuardCFFunctionTable                     uint64
+	GuardCFFunctionCount                     uint64
+	GuardFlags                               uint32
+	CodeIntegrity                            IMAGE_LOAD_CONFIG_CODE_INTEGRITY
+	GuardAddressTakenIatEntryTable           uint64
+	GuardAddressTakenIatEntryCount           uint64
+	GuardLongJumpTargetTable                 uint64
+	GuardLongJumpTargetCount                 uint64
+	DynamicValueRelocTable                   uint64
+	CHPEMetadataPointer                      uint64
+	GuardRFFailureRoutine                    uint64
+	GuardRFFailureRoutineFunctionPointer     uint64
+	DynamicValueRelocTableOffset             uint32
+	DynamicValueRelocTableSection            uint16
+	Reserved2                                uint16
+	GuardRFVerifyStackPointerFunctionPointer uint64
+	HotPatchTableOffset                      uint32
+	Reserved3                                uint32
+	EnclaveConfigurationPointer              uint64
+	VolatileMetadataPointer                  uint64
+	GuardEHContinuationTable                 uint64
+	GuardEHContinuationCount                 uint64
+	GuardXFGCheckFunctionPointer             uint64
+	GuardXFGDispatchFunctionPointer          uint64
+	GuardXFGTableDispatchFunctionPointer     uint64
+	CastGuardOsDeterminedFailureMode         uint64
+	GuardMemcpyFunctionPointer               uint64
+}
+
+// --- Load Config GuardFlags ---
+const (
+	IMAGE_GUARD_CF_INSTRUMENTED                    = 0x00000100 //nolint:revive // Windows constant
+	IMAGE_GUARD_CFW_INSTRUMENTED                   = 0x00000200 //nolint:revive // Windows constant
+	IMAGE_GUARD_CF_FUNCTION_TABLE_PRESENT          = 0x00000400 //nolint:revive // Windows constant
+	IMAGE_GUARD_SECURITY_COOKIE_UNUSED             = 0x00000800 //nolint:revive // Windows constant
+	IMAGE_GUARD_PROTECT_DELAYLOAD_IAT              = 0x00001000 //nolint:revive // Windows constant
+	IMAGE_GUARD_DELAYLOAD_IAT_IN_ITS_OWN_SECTION   = 0x00002000 //nolint:revive // Windows constant
+	IMAGE_GUARD_CF_EXPORT_SUPPRESSION_INFO_PRESENT = 0x00004000 //nolint:revive // Windows constant
+	IMAGE_GUARD_CF_ENABLE_EXPORT_SUPPRESSION       = 0x00008000 //nolint:revive // Windows constant
+	IMAGE_GUARD_CF_LONGJUMP_TABLE_PRESENT          = 0x00010000 //nolint:revive // Windows constant
+	IMAGE_GUARD_RF_INSTRUMENTED                    = 0x00020000 //nolint:revive // Windows constant
+	IMAGE_GUARD_RF_ENABLE                          = 0x00040000 //nolint:revive // Windows constant
+	IMAGE_GUARD_RF_STRICT                          = 0x00080000 //nolint:revive // Windows constant
+	IMAGE_GUARD_RETPOLINE_PRESENT                  = 0x00100000 //nolint:revive // Windows constant
+	IMAGE_GUARD_EH_CONTINUATION_TABLE_PRESENT      = 0x00400000 //nolint:revive // Windows constant
+	IMAGE_GUARD_XFG_ENABLED                        = 0x00800000 //nolint:revive // Windows constant
+	IMAGE_GUARD_CASTGUARD_PRESENT                  = 0x01000000 //nolint:revive // Windows constant
+	IMAGE_GUARD_MEMCPY_PRESENT                     = 0x02000000 //nolint:revive // Windows constant
+
+	// Each GuardCFFunctionTable entry is an RVA followed by this many bytes of metadata.
+	IMAGE_GUARD_CF_FUNCTION_TABLE_SIZE_MASK  = 0xF0000000 //nolint:revive // Windows constant
+	IMAGE_GUARD_CF_FUNCTION_TABLE_SIZE_SHIFT = 28         //nolint:revive // Windows constant
+)
+
+var guardFlagNames = []flagName{
+	{IMAGE_GUARD_CF_INSTRUMENTED, "CF_INSTRUMENTED"},
+	{IMAGE_GUARD_CFW_INSTRUMENTED, "CFW_INSTRUMENTED"},
+	{IMAGE_GUARD_CF_FUNCTION_TABLE_PRESENT, "CF_FUNCTION_TABLE_PRESENT"},
+	{IMAGE_GUARD_SECURITY_COOKIE_UNUSED, "SECURITY_COOKIE_UNUSED"},
+	{IMAGE_GUARD_PROTECT_DELAYLOAD_IAT, "PROTECT_DELAYLOAD_IAT"},
+	{IMAGE_GUARD_DELAYLOAD_IAT_IN_ITS_OWN_SECTION, "DELAYLOAD_IAT_IN_ITS_OWN_SECTION"},
+	{IMAGE_GUARD_CF_EXPORT_SUPPRESSION_INFO_PRESENT, "CF_EXPORT_SUPPRESSION_INFO_PRESENT"},
+	{IMAGE_GUARD_CF_ENABLE_EXPORT_SUPPRESSION, "CF_ENABLE_EXPORT_SUPPRESSION"},
+	{IMAGE_GUARD_CF_LONGJUMP_TABLE_PRESENT, "CF_LONGJUMP_TABLE_PRESENT"},
+	{IMAGE_GUARD_RF_INSTRUMENTED, "RF_INSTRUMENTED"},
+	{IMAGE_GUARD_RF_ENABLE, "RF_ENABLE"},
+	{IMAGE_GUARD_RF_STRICT, "RF_STRICT"},
+	{IMAGE_GUARD_RETPOLINE_PRESENT, "RETPOLINE_PRESENT"},
+	{IMAGE_GUARD_EH_CONTINUATION_TABLE_PRESENT, "EH_CONTINUATION_TABLE_PRESENT"},
+	{IMAGE_GUARD_XFG_ENABLED, "XFG_ENABLED"},
+	{IMAGE_GUARD_CASTGUARD_PRESENT, "CASTGUARD_PRESENT"},
+	{IMAGE_GUARD_MEMCPY_PRESENT, "MEMCPY_PRESENT"},
+}
+
+// GuardFlagsToStrings decodes the load config GuardFlags. The function
+// table entry size in the top nibble is not a flag and is left out.
+func GuardFlagsToStrings(flags uint32) []string {
+	return decodeFlags(flags&^IMAGE_GUARD_CF_FUNCTION_TABLE_SIZE_MASK, guardFlagNames)
+}
+
+// maxGuardTableEntries bounds the CFG and SafeSEH tables, whose counts come
+// straight from the directory.
+const maxGuardTableEntries = 1 << 20
+
+// LoadConfig is the load config directory widened to 64-bit fields so PE32
+// and PE32+ images look the same. Pointer fields are VAs at the preferred
+// ImageBase. Fields past Size were not in the image and read as zero.
+type LoadConfig struct {
+	Size       uint32 // Bytes of the structure present in the image
+	LastField  string // Last field that fits entirely within Size
+	Truncated  bool   // Size is larger than the data directory or the file allowed
+	Raw32      *IMAGE_LOAD_CONFIG_DIRECTORY32
+	Raw64      *IMAGE_LOAD_CONFIG_DIRECTORY64
+	GuardFlags uint32
+
+	TimeDateStamp                  uint32
+	DependentLoadFlags             uint16
+	SecurityCookie                 uint64 // VA of the /GS cookie
+	SEHandlerTable                 uint64 // VA of the SafeSEH table (x86 only)
+	SEHandlerCount                 uint64
+	GuardCFCheckFunctionPointer    uint64
+	GuardCFDispatchFunctionPointer uint64
+	GuardCFFunctionTable           uint64 // VA of the sorted table of valid indirect call targets
+	GuardCFFunctionCount           uint64
+	GuardLongJumpTargetTable       uint64
+	GuardLongJumpTargetCount       uint64
+	GuardEHContinuationTable       uint64
+	GuardEHContinuationCount       uint64
+	DynamicValueRelocTable         uint64
+	DynamicValueRelocTableOffset   uint32 // Offset of the dynamic relocation table within its section
+	DynamicValueRelocTableSection  uint16 // 1-based section index, 0 if absent
+	CHPEMetadataPointer            uint64
+}
+
+// LoadConfig decodes the load config directory. Returns nil, nil if the
+// image has none.
+func (f *File) LoadConfig() (*LoadConfig, error) {
+	dir := f.DataDirectory(IMAGE_DIRECTORY_ENTRY_LOAD_CONFIG)
+	if dir.VirtualAddress == 0 {
+		return nil, nil
+	}
+	size, err := f.uint32AtRVA(dir.VirtualAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw any = &IMAGE_LOAD_CONFIG_DIRECTORY32{}
+	if f.Is64() {
+		raw = &IMAGE_LOAD_CONFIG_DIRECTORY64{}
+	}
+	full := uint32(binary.Size(raw))
+
+	// Read the Size bytes the image claims, capped at the newest layout we
+	// know and at the raw data backing the RVA; the rest stays zero.
+	lc := &LoadConfig{Size: size}
+	n := min(size, full)
+	a := f.TranslateRVA(dir.VirtualAddress)
+	avail := uint64(len(f.data)) - uint64(a.Offset)
+	if a.Section != nil {
+		avail = min(avail, uint64(a.Section.PointerToRawData)+uint64(a.Section.SizeOfRawData)-uint64(a.Offset))
+	}
+	if uint64(n) > avail {
+		n, lc.Truncated = uint32(avail), true
+	}
+	data := f.data[a.Offset : a.Offset+n]
+	buf := make([]byte, full)
+	copy(buf, data)
+	if err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, raw); err != nil {
+		return nil, &FormatError{Op: "load config directory", Offset: -1, Err: err}
+	}
+	lc.LastField = lastFieldWithin(raw, uint32(len(data)))
+
+	switch d := raw.(type) {
+	case *IMAGE_LOAD_CONFIG_DIRECTORY32:
+		lc.Raw32 = d
+		lc.TimeDateStamp, lc.DependentLoadFlags, lc.GuardFlags = d.TimeDateStamp, d.DependentLoadFlags, d.GuardFlags
+		lc.SecurityCookie, lc.SEHandlerTable, lc.SEHandlerCount = uint64(d.SecurityCookie), uint64(d.SEHandlerTable), uint64(d.SEHandlerCount)
+		lc.GuardCFCheckFunctionPointer, lc.GuardCFDispatchFunctionPointer = uint64(d.GuardCFCheckFunctionPointer), uint64(d.GuardCFDispatchFunctionPointer)
+		lc.GuardCFFunctionTable, lc.GuardCFFunctionCount = uint64(d.GuardCFFunctionTable), uint64(d.GuardCFFunctionCount)
+		lc.GuardLongJumpTargetTable, lc.GuardLongJumpTargetCount = uint64(d.GuardLongJumpTargetTable), uint64(d.GuardLongJumpTargetCount)
+		lc.GuardEHContinuationTable, lc.GuardEHContinuationCount = uint64(d.GuardEHContinuationTable), uint64(d.GuardEHContinuationCount)
+		lc.DynamicValueRelocTable, lc.CHPEMetadataPointer = uint64(d.DynamicValueRelocTable), uint64(d.CHPEMetadataPointer)
+		lc.DynamicValueRelocTableOffset, lc.DynamicValueRelocTableSection = d.DynamicValueRelocTableOffset, d.DynamicValueRelocTableSection
+	case *IMAGE_LOAD_CONFIG_DIRECTORY64:
+		lc.Raw64 = d
+		lc.TimeDateStamp, lc.DependentLoadFlags, lc.GuardFlags = d.TimeDateStamp, d.DependentLoadFlags, d.GuardFlags
+		lc.SecurityCookie, lc.SEHandlerTable, lc.SEHandlerCount = d.SecurityCookie, d.SEHandlerTable, d.SEHandlerCount
+		lc.GuardCFCheckFunctionPointer, lc.GuardCFDispatchFunctionPointer = d.GuardCFCheckFunctionPointer, d.GuardCFDispatchFunctionPointer
+		lc.GuardCFFunctionTable, lc.GuardCFFunctionCount = d.GuardCFFunctionTable, d.GuardCFFunctionCount
+		lc.GuardLongJumpTargetTable, lc.GuardLongJumpTargetCount = d.GuardLongJumpTargetTable, d.GuardLongJumpTargetCount
+		lc.GuardEHContinuationTable, lc.GuardEHContinuationCount = d.GuardEHContinuationTable, d.GuardEHContinuationCount
+		lc.DynamicValueRelocTable, lc.CHPEMetadataPointer = d.DynamicValueRelocTable, d.CHPEMetadataPointer
+		lc.DynamicValueRelocTableOffset, lc.DynamicValueRelocTableSection = d.DynamicValueRelocTableOffset, d.DynamicValueRelocTableSection
+	}
+	return lc, nil
+}
+
+// lastFieldWithin returns the name of the last field of *v that ends at or
+// before size bytes, which identifies the layout version the linker wrote.
+func lastFieldWithin(v any, size uint32) string {
+	t := reflect.TypeOf(v).Elem()
+	zero := reflect.New(t).Elem()
+	last, end := "", uint32(0)
+	for i := 0; i < t.NumField(); i++ {
+		end += uint32(binary.Size(zero.Field(i).Interface()))
+		if end > size {
+			break
+		}
+		last = t.Field(i).Name
+	}
+	return last
+}
+
+// GuardCFFunctionTableEntrySize is the stride of GuardCFFunctionTable: a
+// 4-byte RVA plus the metadata bytes declared in the top nibble of GuardFlags.
+func (lc *LoadConfig) GuardCFFunctionTableEntrySize() uint32 {
+	return 4 + (lc.GuardFlags&IMAGE_GUARD_CF_FUNCTION_TABLE_SIZE_MASK)>>IMAGE_GUARD_CF_FUNCTION_TABLE_SIZE_SHIFT
+}
+
+// GuardCFFunctions returns the RVAs of the valid indirect call targets
+// listed in GuardCFFunctionTable.
+func (f *File) GuardCFFunctions(lc *LoadConfig) ([]uint32, error) {
+	return f.guardTable("GuardCFFunctionTable", lc.GuardCFFunctionTable, lc.GuardCFFunctionCount, lc.GuardCFFunctionTableEntrySize())
+}
+
+// SEHandlers returns the RVAs of the registered exception handlers in the
+// SafeSEH table. Only x86 images have one.
+func (f *File) SEHandlers(lc *LoadConfig) ([]uint32, error) {
+	return f.guardTable("SEHandlerTable", lc.SEHandlerTable, lc.SEHandlerCount, 4)
+}
+
+// guardTable reads count entries of entrySize bytes starting at va and
+// returns the leading RVA of each.
+func (f *File) guardTable(name string, va, count uint64, entrySize uint32) ([]uint32, error) {
+	if va == 0 || count == 0 {
+		return nil, nil
+	}
+	if count > maxGuardTableEntries {
+		return nil, &FormatError{Op: fmt.Sprintf("%s (%d entries)", name, count), Offset: -1, Err: ErrTruncated}
+	}
+	a := f.TranslateVA(va)
+	if a.Location == LocationOutside {
+		return nil, &FormatError{Op: fmt.Sprintf("%s VA 0x%X", name, va), Offset: -1, Err: ErrInvalidRVA}
+	}
+	table, err := f.tableAtRVA(a.RVA, uint32(count), entrySize)
+	if err != nil {
+		return nil, err
+	}
+	rvas := make([]uint32, count)
+	for i := range rvas {
+		rvas[i] = binary.LittleEndian.Uint32(table[uint32(i)*entrySize:])
+	}
+	return rvas, nil
+}
+
+// GuardCF reports whether the image was built with /guard:cf: it opts in
+// through DllCharacteristics and its load config carries CFG instrumentation.
+// Mapping such an image by hand leaves its CFG metadata unregistered, and
+// its check function pointer still targets the linker's no-op stub.
+func (f *File) GuardCF() bool {
+	if f.DllCharacteristics()&IMAGE_DLLCHARACTERISTICS_GUARD_CF == 0 {
+		return false
+	}
+	lc, err := f.LoadConfig()
+	return err == nil && lc != nil && lc.GuardFlags&IMAGE_GUARD_CF_INSTRUMENTED != 0
+}
+
+// CFGWarning returns the warning the mapper labs print for a GuardCF image,
+// or "" when CFG is off.
+func (f *File) CFGWarning() string {
+	if !f.GuardCF() {
+		return ""
+	}
+	return "DLL is built with /guard:cf, but manual mapping neither registers its GuardCFFunctionTable " +
+		"nor points its CFG check at ntdll, so its CFG checks will be no-ops"
+}
@@ -0,0 +1,120 @@
+package pe
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// loadConfigImage places raw (the first len(raw) bytes of a load config
+// directory, followed by any tables) at the start of .rdata (RVA 0x2000)
+// and points the LoadConfig directory at it.
+func loadConfigImage(t *testing.T, is64 bool, dllCharacteristics uint16, raw []byte) *File {
+	t.Helper()
+	return testImage{
+		is64:               is64,
+		dllCharacteristics: dllCharacteristics,
+		sections:           withText(newSection(".rdata", 0x2000, 0x200, 0x600, 0x200, 0x40000040)),
+		directories:        map[int]IMAGE_DATA_DIRECTORY{IMAGE_DIRECTORY_ENTRY_LOAD_CONFIG: {VirtualAddress: 0x2000, Size: 0x40}},
+		sectionData:        map[int][]byte{1: raw},
+	}.parse(t)
+}
+
+func TestLoadConfig32SafeSEH(t *testing.T) {
+	// A Windows XP era directory: 0x48 bytes, ending at SEHandlerCount.
+	const base = 0x10000000
+	raw := make([]byte, 0x200)
+	copy(raw, encode(t, IMAGE_LOAD_CONFIG_DIRECTORY32{Size: 0x48, SecurityCookie: base + 0x2180,
+		SEHandlerTable: base + 0x2100, SEHandlerCount: 2,
+		GuardFlags: IMAGE_GUARD_CF_INSTRUMENTED})) // Past Size: must be ignored
+	copy(raw[0x100:], encode(t, []uint32{0x1010, 0x1050}))
+	f := loadConfigImage(t, false, 0, raw)
+
+	lc, err := f.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if lc.Raw32 == nil || lc.Raw64 != nil || lc.Size != 0x48 || lc.LastField != "SEHandlerCount" || lc.Truncated {
+		t.Errorf("LoadConfig = %+v", lc)
+	}
+	if lc.SecurityCookie != base+0x2180 || lc.GuardFlags != 0 {
+		t.Errorf("SecurityCookie = 0x%X, GuardFlags = 0x%X", lc.SecurityCookie, lc.GuardFlags)
+	}
+	handlers, err := f.SEHandlers(lc)
+	if err != nil || !reflect.DeepEqual(handlers, []uint32{0x1010, 0x1050}) {
+		t.Errorf("SEHandlers = %X, %v", handlers, err)
+	}
+	if f.GuardCF() || f.CFGWarning() != "" {
+		t.Errorf("GuardCF = true, CFGWarning = %q", f.CFGWarning())
+	}
+}
+
+func TestLoadConfig64CFG(t *testing.T) {
+	const base = 0x180000000
+	// One metadata byte per GuardCFFunctionTable entry.
+	flags := uint32(IMAGE_GUARD_CF_INSTRUMENTED|IMAGE_GUARD_CF_FUNCTION_TABLE_PRESENT|IMAGE_GUARD_CF_LONGJUMP_TABLE_PRESENT) |
+		1<<IMAGE_GUARD_CF_FUNCTION_TABLE_SIZE_SHIFT
+	lc64 := IMAGE_LOAD_CONFIG_DIRECTORY64{SecurityCookie: base + 0x2190,
+		GuardCFFunctionTable: base + 0x2180, GuardCFFunctionCount: 3, GuardFlags: flags,
+		DynamicValueRelocTableOffset: 0x40, DynamicValueRelocTableSection: 2}
+	lc64.Size = uint32(binary.Size(lc64))
+	raw := make([]byte, 0x200)
+	copy(raw, encode(t, lc64))
+	copy(raw[0x180:], []byte{0x00, 0x10, 0, 0, 0, 0x40, 0x10, 0, 0, 1, 0x80, 0x10, 0, 0, 0})
+	f := loadConfigImage(t, true, IMAGE_DLLCHARACTERISTICS_GUARD_CF, raw)
+
+	lc, err := f.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if lc.Raw64 == nil || lc.LastField != "GuardMemcpyFunctionPointer" || lc.DynamicValueRelocTableSection != 2 {
+		t.Errorf("LoadConfig = %+v", lc)
+	}
+	if got := lc.GuardCFFunctionTableEntrySize(); got != 5 {
+		t.Errorf("GuardCFFunctionTableEntrySize = %d, want 5", got)
+	}
+	targets, err := f.GuardCFFunctions(lc)
+	if err != nil || !reflect.DeepEqual(targets, []uint32{0x1000, 0x1040, 0x1080}) {
+		t.Errorf("GuardCFFunctions = %X, %v", targets, err)
+	}
+	want := []string{"CF_INSTRUMENTED", "CF_FUNCTION_TABLE_PRESENT", "CF_LONGJUMP_TABLE_PRESENT"}
+	if got := GuardFlagsToStrings(lc.GuardFlags); !reflect.DeepEqual(got, want) {
+		t.Errorf("GuardFlagsToStrings = %v, want %v", got, want)
+	}
+	if !f.GuardCF() || f.CFGWarning() == "" {
+		t.Errorf("GuardCF = false, CFGWarning = %q", f.CFGWarning())
+	}
+}
+
+func TestLoadConfigMalformed(t *testing.T) {
+	// A Size from a newer SDK than ours: decode the fields we know.
+	raw := make([]byte, 0x200)
+	copy(raw, encode(t, IMAGE_LOAD_CONFIG_DIRECTORY64{Size: 0x1000, SecurityCookie: 0x180002000,
+		GuardCFFunctionTable: 0x180002100, GuardCFFunctionCount: 0x7FFFFFFF}))
+	f := loadConfigImage(t, true, 0, raw)
+	lc, err := f.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if lc.Truncated || lc.LastField != "GuardMemcpyFunctionPointer" || lc.SecurityCookie != 0x180002000 {
+		t.Errorf("LoadConfig = %+v", lc)
+	}
+	if _, err := f.GuardCFFunctions(lc); !errors.Is(err, ErrTruncated) {
+		t.Errorf("huge GuardCFFunctionCount: err = %v, want ErrTruncated", err)
+	}
+
+	// The section's raw data ends right after SecurityCookie.
+	f = testImage{
+		is64:        true,
+		sections:    []IMAGE_SECTION_HEADER{newSection(".rdata", 0x2000, 0x200, 0x400, 0x60, 0x40000040)},
+		directories: map[int]IMAGE_DATA_DIRECTORY{IMAGE_DIRECTORY_ENTRY_LOAD_CONFIG: {VirtualAddress: 0x2000, Size: 0x40}},
+		sectionData: map[int][]byte{0: raw},
+	}.parse(t)
+	if lc, err = f.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if !lc.Truncated || lc.LastField != "SecurityCookie" || lc.GuardCFFunctionTable != 0 {
+		t.Errorf("truncated LoadConfig = %+v", lc)
+	}
+}
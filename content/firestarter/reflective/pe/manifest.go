@@ -0,0 +1,76 @@
+package pe
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Manifest summarises an RT_MANIFEST side-by-side assembly manifest.
+type Manifest struct {
+	XML                     []byte // Raw manifest as stored in the resource
+	RequestedExecutionLevel string // asInvoker, highestAvailable or requireAdministrator; "" if absent
+	UIAccess                string // "true"/"false"; "" if absent
+	Dependencies            []ManifestDependency
+}
+
+// ManifestDependency is one dependentAssembly's assemblyIdentity, e.g.
+// Microsoft.Windows.Common-Controls 6.0.0.0.
+type ManifestDependency struct {
+	Type                  string `xml:"type,attr"`
+	Name                  string `xml:"name,attr"`
+	Version               string `xml:"version,attr"`
+	ProcessorArchitecture string `xml:"processorArchitecture,attr"`
+	PublicKeyToken        string `xml:"publicKeyToken,attr"`
+	Language              string `xml:"language,attr"`
+}
+
+// manifestXML picks out the elements we summarise. Tags carry no namespace,
+// so they match whichever of the asm.v1/v2/v3 namespaces the linker used.
+type manifestXML struct {
+	TrustInfo struct {
+		Level struct {
+			Level    string `xml:"level,attr"`
+			UIAccess string `xml:"uiAccess,attr"`
+		} `xml:"security>requestedPrivileges>requestedExecutionLevel"`
+	} `xml:"trustInfo"`
+	Dependencies []ManifestDependency `xml:"dependency>dependentAssembly>assemblyIdentity"`
+}
+
+// Manifest decodes the first RT_MANIFEST resource. Returns nil, nil if the
+// image has none.
+func (f *File) Manifest() (*Manifest, error) {
+	data, err := f.firstResourceOfType(RT_MANIFEST)
+	if data == nil || err != nil {
+		return nil, err
+	}
+	return ParseManifest(data)
+}
+
+// ParseManifest summarises raw manifest XML.
+func ParseManifest(data []byte) (*Manifest, error) {
+	// Resource data is padded, often with NULs, and may start with a BOM.
+	text := bytes.TrimRight(bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF")), "\x00 \r\n\t")
+
+	var doc manifestXML
+	dec := xml.NewDecoder(bytes.NewReader(text))
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		// Manifests are UTF-8 in practice whatever the declaration says
+		// (commonly "UTF-8" with standalone="yes"); reject anything else.
+		if strings.EqualFold(charset, "utf-8") || strings.EqualFold(charset, "us-ascii") {
+			return input, nil
+		}
+		return nil, fmt.Errorf("unsupported manifest encoding %q", charset)
+	}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+	}
+	return &Manifest{
+		XML:                     data,
+		RequestedExecutionLevel: doc.TrustInfo.Level.Level,
+		UIAccess:                doc.TrustInfo.Level.UIAccess,
+		Dependencies:            doc.Dependencies,
+	}, nil
+}
@@ -0,0 +1,42 @@
+package pe
+
+import "bytes"
+
+// SectionNameToString converts a null-padded section name to a string.
+func SectionNameToString(nameBytes [8]byte) string {
+	n := bytes.IndexByte(nameBytes[:], 0)
+	if n == -1 {
+		n = 8
+	}
+	return string(nameBytes[:n])
+}
+
+// MachineTypeToString returns a descriptive name for IMAGE_FILE_HEADER.Machine.
+func MachineTypeToString(machine uint16) string {
+	switch machine {
+	case 0x0:
+		return "Unknown"
+	case 0x14c:
+		return "x86 (I386)"
+	case 0x8664:
+		return "x64 (AMD64)"
+	case 0xaa64:
+		return "ARM64"
+	case 0x1c0:
+		return "ARM"
+	default:
+		return "Other"
+	}
+}
+
+// MagicTypeToString returns a descriptive name for the optional header Magic.
+func MagicTypeToString(magic uint16) string {
+	switch magic {
+	case IMAGE_NT_OPTIONAL_HDR32_MAGIC:
+		return "PE32 (32-bit)"
+	case IMAGE_NT_OPTIONAL_HDR64_MAGIC:
+		return "PE32+ (64-bit)"
+	default:
+		return "Unknown/Invalid"
+	}
+}
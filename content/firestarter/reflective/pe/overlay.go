@@ -0,0 +1,73 @@
+package pe
+
+// Overlay describes bytes appended after the end of the image: anything in
+// the file past the last section's raw data. The loader never maps it, so
+// it is a common place for installers, configs and Authenticode signatures.
+type Overlay struct {
+	Offset  uint32  // File offset where the overlay starts
+	Size    uint32  // Bytes from Offset to the end of the file
+	Entropy float64 // Shannon entropy in bits per byte
+
+	// Authenticode is set when the certificate table (DataDirectory[4])
+	// lies inside the overlay. Signing tools append it there; AppendedSize
+	// counts any overlay bytes outside it, which signature verification
+	// ignores and is therefore a classic place to smuggle data.
+	Authenticode bool
+	AppendedSize uint32
+
+	// SymbolTable is set when the COFF symbol table (FileHeader.
+	// PointerToSymbolTable) starts inside the overlay, as it does for
+	// MinGW builds that were not stripped.
+	SymbolTable bool
+}
+
+// OverlayOffset returns the file offset at which the overlay would start:
+// the end of the furthest section's raw data, or of the headers if that is
+// further.
+func (f *File) OverlayOffset() uint32 {
+	end := uint64(f.SizeOfHeaders())
+	for _, s := range f.Sections {
+		if s.SizeOfRawData == 0 {
+			continue
+		}
+		if e := uint64(s.PointerToRawData) + uint64(s.SizeOfRawData); e > end {
+			end = e
+		}
+	}
+	if end > uint64(len(f.data)) {
+		end = uint64(len(f.data))
+	}
+	return uint32(end)
+}
+
+// OverlayData returns the overlay bytes, or nil if there are none.
+func (f *File) OverlayData() []byte {
+	start := f.OverlayOffset()
+	if int(start) >= len(f.data) {
+		return nil
+	}
+	return f.data[start:]
+}
+
+// Overlay describes the overlay, or returns nil if the file ends with the
+// last section.
+func (f *File) Overlay() *Overlay {
+	data := f.OverlayData()
+	if data == nil {
+		return nil
+	}
+	o := &Overlay{Offset: f.OverlayOffset(), Size: uint32(len(data)), Entropy: Entropy(data)}
+	start, end := uint64(o.Offset), uint64(len(f.data))
+
+	o.AppendedSize = o.Size
+	cert := f.DataDirectory(IMAGE_DIRECTORY_ENTRY_SECURITY)
+	certStart, certEnd := uint64(cert.VirtualAddress), uint64(cert.VirtualAddress)+uint64(cert.Size)
+	if cert.Size != 0 && certStart >= start && certEnd <= end {
+		o.Authenticode = true
+		o.AppendedSize -= cert.Size
+	}
+
+	symbols := uint64(f.FileHeader.PointerToSymbolTable)
+	o.SymbolTable = symbols != 0 && symbols >= start && symbols < end
+	return o
+}
@@ -0,0 +1,54 @@
+package pe
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOverlayCalcDLL(t *testing.T) {
+	data := loadCalcDLL(t)
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	o := f.Overlay()
+	if o == nil {
+		t.Fatal("no overlay, want the COFF symbol table")
+	}
+	if o.Offset != 0x14000 || int(o.Size) != len(data)-0x14000 {
+		t.Errorf("overlay = 0x%X+0x%X, want 0x14000+0x%X", o.Offset, o.Size, len(data)-0x14000)
+	}
+	if !o.SymbolTable || o.Authenticode || o.AppendedSize != o.Size {
+		t.Errorf("overlay flags = %+v, want symbol table only", o)
+	}
+	if !bytes.Equal(f.OverlayData(), data[0x14000:]) {
+		t.Error("OverlayData does not match the file tail")
+	}
+}
+
+func TestOverlayAuthenticode(t *testing.T) {
+	image := testImage{sections: []IMAGE_SECTION_HEADER{
+		newSection(".text", 0x1000, 0x100, 0x400, 0x200, 0x60000020),
+	}}
+	data := image.build(t)
+	if f, _ := Parse(data); f.Overlay() != nil {
+		t.Fatalf("image without appended data has overlay %+v", f.Overlay())
+	}
+
+	// A 0x100-byte certificate table followed by 0x20 bytes of smuggled data.
+	image.directories = map[int]IMAGE_DATA_DIRECTORY{
+		IMAGE_DIRECTORY_ENTRY_SECURITY: {VirtualAddress: uint32(len(data)), Size: 0x100},
+	}
+	data = append(image.build(t), make([]byte, 0x120)...)
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	o := f.Overlay()
+	if o == nil || o.Offset != 0x600 || o.Size != 0x120 {
+		t.Fatalf("overlay = %+v, want 0x600+0x120", o)
+	}
+	if !o.Authenticode || o.AppendedSize != 0x20 || o.SymbolTable {
+		t.Errorf("overlay flags = %+v, want Authenticode with 0x20 appended bytes", o)
+	}
+}
@@ -0,0 +1,55 @@
+package pe
+
+import "fmt"
+
+// HighEntropyThreshold is the entropy, in bits per byte, above which a
+// section is treated as compressed or encrypted. Compiled code rarely gets
+// past 6.8; packed stubs and encrypted payloads sit well above 7.2.
+const HighEntropyThreshold = 7.2
+
+// largeVirtualSize is how much memory a section with no file data has to
+// reserve before it looks like room for an unpacked image rather than an
+// ordinary .bss.
+const largeVirtualSize = 0x10000
+
+// packerSectionNames maps section names that well-known packers and
+// protectors leave behind to the tool that uses them.
+var packerSectionNames = map[string]string{
+	"UPX0": "UPX", "UPX1": "UPX", "UPX2": "UPX",
+	".aspack": "ASPack", ".adata": "ASPack",
+	".MPRESS1": "MPRESS", ".MPRESS2": "MPRESS",
+	".petite": "Petite",
+	".nsp0":   "NsPack", ".nsp1": "NsPack", ".nsp2": "NsPack",
+	".vmp0": "VMProtect", ".vmp1": "VMProtect",
+	".themida": "Themida", ".winlice": "Themida",
+	".enigma1": "Enigma", ".enigma2": "Enigma",
+}
+
+// PackerIndicator is one reason to suspect the image is packed.
+type PackerIndicator struct {
+	Section *Section // Section the indicator is about
+	Reason  string
+}
+
+// PackerIndicators applies simple heuristics for packed images: section
+// names known packers use, executable sections with high entropy, and
+// sections with no data on disk but a large virtual size, which is where a
+// stub decompresses the original image. None of these is proof on its own;
+// several together usually are.
+func (f *File) PackerIndicators() []PackerIndicator {
+	var found []PackerIndicator
+	for _, s := range f.Sections {
+		if packer, ok := packerSectionNames[s.Name]; ok {
+			found = append(found, PackerIndicator{s, fmt.Sprintf("section name used by %s", packer)})
+		}
+		if s.Characteristics&(IMAGE_SCN_MEM_EXECUTE|IMAGE_SCN_CNT_CODE) != 0 {
+			if e := Entropy(f.SectionData(s)); e > HighEntropyThreshold {
+				found = append(found, PackerIndicator{s, fmt.Sprintf("executable section with entropy %.2f (above %.1f)", e, HighEntropyThreshold)})
+			}
+		}
+		if s.SizeOfRawData == 0 && s.VirtualSize >= largeVirtualSize {
+			found = append(found, PackerIndicator{s, fmt.Sprintf("no raw data but 0x%X bytes of virtual size", s.VirtualSize)})
+		}
+	}
+	return found
+}
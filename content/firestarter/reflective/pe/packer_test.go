@@ -0,0 +1,49 @@
+package pe
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestPackerIndicators(t *testing.T) {
+	f, err := Parse(loadCalcDLL(t))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if found := f.PackerIndicators(); len(found) != 0 {
+		t.Errorf("calc_dll.dll has packer indicators %+v, want none", found)
+	}
+
+	// The layout UPX produces: an empty UPX0 that reserves room for the
+	// unpacked image, and a UPX1 holding the compressed data and the stub.
+	const code = IMAGE_SCN_CNT_CODE | IMAGE_SCN_MEM_EXECUTE | IMAGE_SCN_MEM_READ
+	data := testImage{
+		is64: true,
+		sections: []IMAGE_SECTION_HEADER{
+			newSection("UPX0", 0x1000, 0x20000, 0x400, 0, code|IMAGE_SCN_MEM_WRITE),
+			newSection("UPX1", 0x21000, 0x1000, 0x400, 0x1000, code|IMAGE_SCN_MEM_WRITE),
+			newSection(".bss", 0x22000, 0x800, 0, 0, IMAGE_SCN_CNT_UNINITIALIZED_DATA|IMAGE_SCN_MEM_READ|IMAGE_SCN_MEM_WRITE),
+		},
+		sectionData: map[int][]byte{1: allBytes(0x1000)},
+	}.build(t)
+	binary.LittleEndian.PutUint32(data[0x40+4+20+56:], 0x30000) // SizeOfImage
+	f, err = Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	found := f.PackerIndicators()
+	want := []struct{ section, reason string }{
+		{"UPX0", "section name used by UPX"},
+		{"UPX0", "no raw data but 0x20000 bytes of virtual size"},
+		{"UPX1", "section name used by UPX"},
+		{"UPX1", "executable section with entropy 8.00 (above 7.2)"},
+	}
+	if len(found) != len(want) {
+		t.Fatalf("got %d indicators, want %d: %+v", len(found), len(want), found)
+	}
+	for i, w := range want {
+		if found[i].Section.Name != w.section || found[i].Reason != w.reason {
+			t.Errorf("indicator %d = %s: %q, want %s: %q", i, found[i].Section.Name, found[i].Reason, w.section, w.reason)
+		}
+	}
+}
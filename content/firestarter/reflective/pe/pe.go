@@ -0,0 +1,178 @@
+// Package pe parses Portable Executable (PE) images from raw bytes.
+//
+// It holds the PE structures and the header parsing sequence that the lab
+// programs used to re-declare one by one. Nothing in here calls into the
+// Windows API, so the package builds and runs on any platform; only the
+// loaders that map an image into memory are Windows-specific.
+package pe
+
+// --- PE Structures ---
+
+type IMAGE_DOS_HEADER struct { //nolint:revive // Windows struct
+	Magic    uint16     // Magic number (MZ)
+	Cblp     uint16     // Bytes on last page of file
+	Cp       uint16     // Pages in file
+	Crlc     uint16     // Relocations
+	Cparhdr  uint16     // Size of header in paragraphs
+	MinAlloc uint16     // Minimum extra paragraphs needed
+	MaxAlloc uint16     // Maximum extra paragraphs needed
+	Ss       uint16     // Initial (relative) SS value
+	Sp       uint16     // Initial SP value
+	Csum     uint16     // Checksum
+	Ip       uint16     // Initial IP value
+	Cs       uint16     // Initial (relative) CS value
+	Lfarlc   uint16     // File address of relocation table
+	Ovno     uint16     // Overlay number
+	Res      [4]uint16  // Reserved words
+	Oemid    uint16     // OEM identifier (for e_oeminfo)
+	Oeminfo  uint16     // OEM information; e_oemid specific
+	Res2     [10]uint16 // Reserved words
+	Lfanew   int32      // File address of new exe header (PE header offset)
+}
+
+type IMAGE_FILE_HEADER struct { //nolint:revive // Windows struct
+	Machine              uint16 // Architecture type
+	NumberOfSections     uint16 // Number of sections
+	TimeDateStamp        uint32 // Time and date stamp
+	PointerToSymbolTable uint32 // Pointer to symbol table
+	NumberOfSymbols      uint32 // Number of symbols
+	SizeOfOptionalHeader uint16 // Size of optional header
+	Characteristics      uint16 // File characteristics
+}
+
+type IMAGE_DATA_DIRECTORY struct { //nolint:revive // Windows struct
+	VirtualAddress uint32 // RVA of the directory
+	Size           uint32 // Size of the directory
+}
+
+// Note: This is the 32-bit (PE32) version. It carries BaseOfData, which PE32+
+// dropped, and its ImageBase and stack/heap sizes are 32 bits wide.
+type IMAGE_OPTIONAL_HEADER32 struct { //nolint:revive // Windows struct
+	Magic                       uint16 // Magic number (0x10b for PE32)
+	MajorLinkerVersion          uint8
+	MinorLinkerVersion          uint8
+	SizeOfCode                  uint32
+	SizeOfInitializedData       uint32
+	SizeOfUninitializedData     uint32
+	AddressOfEntryPoint         uint32 // RVA of the entry point
+	BaseOfCode                  uint32
+	BaseOfData                  uint32 // RVA of the start of the data section (PE32 only)
+	ImageBase                   uint32 // Preferred base address
+	SectionAlignment            uint32
+	FileAlignment               uint32
+	MajorOperatingSystemVersion uint16
+	MinorOperatingSystemVersion uint16
+	MajorImageVersion           uint16
+	MinorImageVersion           uint16
+	MajorSubsystemVersion       uint16
+	MinorSubsystemVersion       uint16
+	Win32VersionValue           uint32
+	SizeOfImage                 uint32 // Total size of the image in memory
+	SizeOfHeaders               uint32 // Size of headers (DOS + PE + Section Headers)
+	CheckSum                    uint32
+	Subsystem                   uint16
+	DllCharacteristics          uint16
+	SizeOfStackReserve          uint32
+	SizeOfStackCommit           uint32
+	SizeOfHeapReserve           uint32
+	SizeOfHeapCommit            uint32
+	LoaderFlags                 uint32
+	NumberOfRvaAndSizes         uint32
+	DataDirectory               [16]IMAGE_DATA_DIRECTORY // Array of data directories
+}
+
+// Note: This is the 64-bit version
+type IMAGE_OPTIONAL_HEADER64 struct { //nolint:revive // Windows struct
+	Magic                       uint16 // Magic number (0x20b for PE32+)
+	MajorLinkerVersion          uint8
+	MinorLinkerVersion          uint8
+	SizeOfCode                  uint32
+	SizeOfInitializedData       uint32
+	SizeOfUninitializedData     uint32
+	AddressOfEntryPoint         uint32 // RVA of the entry point
+	BaseOfCode                  uint32
+	ImageBase                   uint64 // Preferred base address
+	SectionAlignment            uint32
+	FileAlignment               uint32
+	MajorOperatingSystemVersion uint16
+	MinorOperatingSystemVersion uint16
+	MajorImageVersion           uint16
+	MinorImageVersion           uint16
+	MajorSubsystemVersion       uint16
+	MinorSubsystemVersion       uint16
+	Win32VersionValue           uint32
+	SizeOfImage                 uint32 // Total size of the image in memory
+	SizeOfHeaders               uint32 // Size of headers (DOS + PE + Section Headers)
+	CheckSum                    uint32
+	Subsystem                   uint16
+	DllCharacteristics          uint16
+	SizeOfStackReserve          uint64
+	SizeOfStackCommit           uint64
+	SizeOfHeapReserve           uint64
+	SizeOfHeapCommit            uint64
+	LoaderFlags                 uint32
+	NumberOfRvaAndSizes         uint32
+	DataDirectory               [16]IMAGE_DATA_DIRECTORY // Array of data directories
+}
+
+type IMAGE_SECTION_HEADER struct { //nolint:revive // Windows struct
+	Name                 [8]byte // Section name (null-padded)
+	VirtualSize          uint32  // Actual size used in memory
+	VirtualAddress       uint32  // RVA of the section
+	SizeOfRawData        uint32  // Size of section data on disk
+	PointerToRawData     uint32  // File offset of section data
+	PointerToRelocations uint32  // File offset of relocations
+	PointerToLinenumbers uint32  // File offset of line numbers
+	NumberOfRelocations  uint16  // Number of relocations
+	NumberOfLinenumbers  uint16  // Number of line numbers
+	Characteristics      uint32  // Section characteristics (flags like executable, readable, writable)
+}
+
+type IMAGE_BASE_RELOCATION struct { //nolint:revive // Windows struct
+	VirtualAddress uint32 // RVA of the page this block applies to
+	SizeOfBlock    uint32 // Size of the block including this header
+}
+
+type IMAGE_IMPORT_DESCRIPTOR struct { //nolint:revive // Windows struct
+	OriginalFirstThunk uint32 // RVA of the Import Lookup Table (ILT)
+	TimeDateStamp      uint32
+	ForwarderChain     uint32
+	Name               uint32 // RVA of the DLL name string
+	FirstThunk         uint32 // RVA of the Import Address Table (IAT)
+}
+
+type IMAGE_EXPORT_DIRECTORY struct { //nolint:revive // Windows struct
+	Characteristics       uint32
+	TimeDateStamp         uint32
+	MajorVersion          uint16
+	MinorVersion          uint16
+	Name                  uint32 // RVA of the DLL name string
+	Base                  uint32 // Starting ordinal number
+	NumberOfFunctions     uint32 // Total number of exported functions (Size of EAT)
+	NumberOfNames         uint32 // Number of functions exported by name (Size of ENPT & EOT)
+	AddressOfFunctions    uint32 // RVA of the Export Address Table (EAT)
+	AddressOfNames        uint32 // RVA of the Export Name Pointer Table (ENPT)
+	AddressOfNameOrdinals uint32 // RVA of the Export Ordinal Table (EOT)
+}
+
+// --- Constants ---
+const (
+	IMAGE_DOS_SIGNATURE = 0x5A4D     // "MZ"
+	IMAGE_NT_SIGNATURE  = 0x00004550 // "PE\0\0"
+
+	IMAGE_NT_OPTIONAL_HDR32_MAGIC = 0x10b // PE32
+	IMAGE_NT_OPTIONAL_HDR64_MAGIC = 0x20b // PE32+
+
+	IMAGE_NUMBEROF_DIRECTORY_ENTRIES = 16
+
+	IMAGE_FILE_MACHINE_I386  = 0x14c
+	IMAGE_FILE_MACHINE_ARM   = 0x1c0
+	IMAGE_FILE_MACHINE_AMD64 = 0x8664
+	IMAGE_FILE_MACHINE_ARM64 = 0xaa64
+
+	IMAGE_REL_BASED_ABSOLUTE = 0
+	IMAGE_REL_BASED_DIR64    = 10
+
+	IMAGE_ORDINAL_FLAG32 = 0x80000000
+	IMAGE_ORDINAL_FLAG64 = 1 << 63 // Untyped so it works against uint64 and uintptr thunks
+)
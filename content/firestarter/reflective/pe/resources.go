@@ -0,0 +1,285 @@
+package pe
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+type IMAGE_RESOURCE_DIRECTORY struct { //nolint:revive // Windows struct
+	Characteristics      uint32
+	TimeDateStamp        uint32
+	MajorVersion         uint16
+	MinorVersion         uint16
+	NumberOfNamedEntries uint16 // Named entries come first, sorted by name
+	NumberOfIdEntries    uint16 // Followed by ID entries, sorted by ID
+}
+
+type IMAGE_RESOURCE_DIRECTORY_ENTRY struct { //nolint:revive // Windows struct
+	Name         uint32 // ID, or IMAGE_RESOURCE_NAME_IS_STRING | offset of an IMAGE_RESOURCE_DIR_STRING_U
+	OffsetToData uint32 // IMAGE_RESOURCE_DATA_IS_DIRECTORY | offset of a subdirectory, or offset of a data entry
+}
+
+type IMAGE_RESOURCE_DATA_ENTRY struct { //nolint:revive // Windows struct
+	OffsetToData uint32 // RVA of the resource bytes (not relative to the resource directory)
+	Size         uint32
+	CodePage     uint32
+	Reserved     uint32
+}
+
+const (
+	IMAGE_RESOURCE_NAME_IS_STRING    = 0x80000000 //nolint:revive // Windows constant
+	IMAGE_RESOURCE_DATA_IS_DIRECTORY = 0x80000000 //nolint:revive // Windows constant
+)
+
+// Predefined resource types (winuser.h).
+const (
+	RT_CURSOR       = 1  //nolint:revive // Windows constant
+	RT_BITMAP       = 2  //nolint:revive // Windows constant
+	RT_ICON         = 3  //nolint:revive // Windows constant
+	RT_MENU         = 4  //nolint:revive // Windows constant
+	RT_DIALOG       = 5  //nolint:revive // Windows constant
+	RT_STRING       = 6  //nolint:revive // Windows constant
+	RT_FONTDIR      = 7  //nolint:revive // Windows constant
+	RT_FONT         = 8  //nolint:revive // Windows constant
+	RT_ACCELERATOR  = 9  //nolint:revive // Windows constant
+	RT_RCDATA       = 10 //nolint:revive // Windows constant
+	RT_MESSAGETABLE = 11 //nolint:revive // Windows constant
+	RT_GROUP_CURSOR = 12 //nolint:revive // Windows constant
+	RT_GROUP_ICON   = 14 //nolint:revive // Windows constant
+	RT_VERSION      = 16 //nolint:revive // Windows constant
+	RT_DLGINCLUDE   = 17 //nolint:revive // Windows constant
+	RT_PLUGPLAY     = 19 //nolint:revive // Windows constant
+	RT_VXD          = 20 //nolint:revive // Windows constant
+	RT_ANICURSOR    = 21 //nolint:revive // Windows constant
+	RT_ANIICON      = 22 //nolint:revive // Windows constant
+	RT_HTML         = 23 //nolint:revive // Windows constant
+	RT_MANIFEST     = 24 //nolint:revive // Windows constant
+)
+
+var resourceTypeNames = map[uint32]string{
+	RT_CURSOR: "RT_CURSOR", RT_BITMAP: "RT_BITMAP", RT_ICON: "RT_ICON", RT_MENU: "RT_MENU",
+	RT_DIALOG: "RT_DIALOG", RT_STRING: "RT_STRING", RT_FONTDIR: "RT_FONTDIR", RT_FONT: "RT_FONT",
+	RT_ACCELERATOR: "RT_ACCELERATOR", RT_RCDATA: "RT_RCDATA", RT_MESSAGETABLE: "RT_MESSAGETABLE",
+	RT_GROUP_CURSOR: "RT_GROUP_CURSOR", RT_GROUP_ICON: "RT_GROUP_ICON", RT_VERSION: "RT_VERSION",
+	RT_DLGINCLUDE: "RT_DLGINCLUDE", RT_PLUGPLAY: "RT_PLUGPLAY", RT_VXD: "RT_VXD",
+	RT_ANICURSOR: "RT_ANICURSOR", RT_ANIICON: "RT_ANIICON", RT_HTML: "RT_HTML", RT_MANIFEST: "RT_MANIFEST",
+}
+
+// maxResourceDepth bounds the walk. Windows only uses three levels (type,
+// name, language); anything much deeper is malformed or hostile.
+const maxResourceDepth = 8
+
+// maxResourceEntries bounds the total number of directory entries a walk
+// visits. Subdirectories may legitimately be shared between entries, so a
+// small hostile tree could otherwise fan out exponentially.
+const maxResourceEntries = 1 << 16
+
+// ResourceID names one level of the resource tree: either a numeric ID or,
+// when Name is non-empty, a string.
+type ResourceID struct {
+	ID   uint32
+	Name string
+}
+
+func (id ResourceID) String() string {
+	if id.Name != "" {
+		return id.Name
+	}
+	return "#" + strconv.FormatUint(uint64(id.ID), 10)
+}
+
+// ResourceTypeName returns the RT_* name for a predefined type, or "#id".
+func ResourceTypeName(id ResourceID) string {
+	if id.Name == "" {
+		if name, ok := resourceTypeNames[id.ID]; ok {
+			return name
+		}
+	}
+	return id.String()
+}
+
+// Resource is one leaf of the resource tree.
+type Resource struct {
+	IMAGE_RESOURCE_DATA_ENTRY
+	Path      []ResourceID // Type, name and language for a standard three-level tree
+	Offset    uint32       // File offset of the data, valid when HasOffset is set
+	HasOffset bool
+}
+
+// Type, Name and Language return the standard levels of the path, or the
+// zero ResourceID when the tree is shallower than usual.
+func (r Resource) Type() ResourceID     { return r.level(0) }
+func (r Resource) Name() ResourceID     { return r.level(1) }
+func (r Resource) Language() ResourceID { return r.level(2) }
+
+func (r Resource) level(i int) ResourceID {
+	if i < len(r.Path) {
+		return r.Path[i]
+	}
+	return ResourceID{}
+}
+
+// PathString formats the path as "RT_MANIFEST/#1/#1033", the form
+// FindResource accepts.
+func (r Resource) PathString() string {
+	parts := make([]string, len(r.Path))
+	for i, id := range r.Path {
+		parts[i] = id.String()
+	}
+	if len(parts) > 0 {
+		parts[0] = ResourceTypeName(r.Path[0])
+	}
+	return strings.Join(parts, "/")
+}
+
+// Resources walks the resource directory and returns every data entry in
+// tree order. Directory offsets are relative to the start of the resource
+// directory, except the final data entry's OffsetToData, which is an RVA.
+//
+// Two entries may share a subdirectory, and Windows resolves both. A
+// subdirectory offset pointing back at one of its own ancestors would make a
+// naive walker recurse forever; it is reported as ErrResourceLoop. Trees
+// with more than maxResourceEntries entries, counting shared ones each time
+// they are reached, are reported as ErrTooManyResources.
+func (f *File) Resources() ([]Resource, error) {
+	dir := f.DataDirectory(IMAGE_DIRECTORY_ENTRY_RESOURCE)
+	if dir.VirtualAddress == 0 {
+		return nil, nil
+	}
+	w := resourceWalker{f: f, base: dir.VirtualAddress, ancestors: make(map[uint32]bool)}
+	err := w.walk(0, nil)
+	return w.resources, err
+}
+
+type resourceWalker struct {
+	f         *File
+	base      uint32          // RVA of the root directory
+	ancestors map[uint32]bool // Directories on the path from the root to the current one
+	entries   int             // Entries visited so far, bounded by maxResourceEntries
+	resources []Resource
+}
+
+func (w *resourceWalker) walk(offset uint32, path []ResourceID) error {
+	if w.ancestors[offset] || len(path) >= maxResourceDepth {
+		return &FormatError{Op: fmt.Sprintf("resource directory +0x%X", offset), Offset: -1, Err: ErrResourceLoop}
+	}
+	w.ancestors[offset] = true
+	defer delete(w.ancestors, offset)
+
+	var d IMAGE_RESOURCE_DIRECTORY
+	if err := w.f.structAtRVA(w.base+offset, &d); err != nil {
+		return err
+	}
+	entrySize := uint32(binary.Size(IMAGE_RESOURCE_DIRECTORY_ENTRY{}))
+	first := offset + uint32(binary.Size(d))
+	count := uint32(d.NumberOfNamedEntries) + uint32(d.NumberOfIdEntries)
+	if _, err := w.f.tableAtRVA(w.base+first, count, entrySize); err != nil {
+		return err
+	}
+
+	for i := uint32(0); i < count; i++ {
+		if w.entries++; w.entries > maxResourceEntries {
+			return &FormatError{Op: fmt.Sprintf("resource directory +0x%X", offset), Offset: -1, Err: ErrTooManyResources}
+		}
+		var e IMAGE_RESOURCE_DIRECTORY_ENTRY
+		if err := w.f.structAtRVA(w.base+first+i*entrySize, &e); err != nil {
+			return err
+		}
+		id := ResourceID{ID: e.Name}
+		if e.Name&IMAGE_RESOURCE_NAME_IS_STRING != 0 {
+			name, err := w.f.resourceString(w.base + e.Name&^IMAGE_RESOURCE_NAME_IS_STRING)
+			if err != nil {
+				return err
+			}
+			id = ResourceID{Name: name}
+		}
+		// Copy so sibling entries don't share a backing array.
+		child := append(append([]ResourceID(nil), path...), id)
+
+		if e.OffsetToData&IMAGE_RESOURCE_DATA_IS_DIRECTORY != 0 {
+			if err := w.walk(e.OffsetToData&^IMAGE_RESOURCE_DATA_IS_DIRECTORY, child); err != nil {
+				return err
+			}
+			continue
+		}
+		r := Resource{Path: child}
+		if err := w.f.structAtRVA(w.base+e.OffsetToData, &r.IMAGE_RESOURCE_DATA_ENTRY); err != nil {
+			return err
+		}
+		if a := w.f.TranslateRVA(r.OffsetToData); a.HasOffset {
+			r.Offset, r.HasOffset = a.Offset, true
+		}
+		w.resources = append(w.resources, r)
+	}
+	return nil
+}
+
+// resourceString reads an IMAGE_RESOURCE_DIR_STRING_U: a uint16 length in
+// characters followed by that many UTF-16LE code units, not null-terminated.
+func (f *File) resourceString(rva uint32) (string, error) {
+	n, err := f.uint16AtRVA(rva)
+	if err != nil {
+		return "", err
+	}
+	b, err := f.bytesAtRVA(rva+2, uint32(n)*2)
+	if err != nil {
+		return "", err
+	}
+	return decodeUTF16(b), nil
+}
+
+// decodeUTF16 decodes little-endian UTF-16 bytes.
+func decodeUTF16(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// ResourceData returns the bytes of a resource.
+func (f *File) ResourceData(r Resource) ([]byte, error) {
+	return f.bytesAtRVA(r.OffsetToData, r.Size)
+}
+
+// FindResource returns the first resource matching path, written as
+// "type/name[/language]". Each component is an RT_* name (type only), a
+// number with or without a leading "#", or a string name; the language may
+// be omitted to take the first one available.
+func (f *File) FindResource(path string) (*Resource, error) {
+	want := strings.Split(path, "/")
+	resources, err := f.Resources()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range resources {
+		if len(want) > len(r.Path) {
+			continue
+		}
+		match := true
+		for i, component := range want {
+			if !resourceComponentMatches(component, r.Path[i], i == 0) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return &r, nil
+		}
+	}
+	return nil, fmt.Errorf("pe: resource %q: %w", path, ErrResourceNotFound)
+}
+
+func resourceComponentMatches(component string, id ResourceID, isType bool) bool {
+	if id.Name != "" {
+		return strings.EqualFold(component, id.Name)
+	}
+	if isType && strings.EqualFold(component, ResourceTypeName(id)) {
+		return true
+	}
+	n, err := strconv.ParseUint(strings.TrimPrefix(component, "#"), 0, 32)
+	return err == nil && uint32(n) == id.ID
+}
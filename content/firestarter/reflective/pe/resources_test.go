@@ -0,0 +1,186 @@
+package pe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// resourceImage places rsrc at RVA 0x2000 in a PE32+ test image and points
+// the Resource directory at it.
+func resourceImage(t *testing.T, rsrc []byte) *File {
+	t.Helper()
+	return testImage{
+		is64:        true,
+		sections:    withText(newSection(".rsrc", 0x2000, uint32(len(rsrc)), 0x600, uint32(len(rsrc)), 0x40000040)),
+		directories: map[int]IMAGE_DATA_DIRECTORY{IMAGE_DIRECTORY_ENTRY_RESOURCE: {VirtualAddress: 0x2000, Size: uint32(len(rsrc))}},
+		sectionData: map[int][]byte{1: rsrc},
+	}.parse(t)
+}
+
+// rsrcBuilder writes resource structures at fixed offsets in a buffer.
+type rsrcBuilder []byte
+
+func (b rsrcBuilder) dir(off, named, ids int) {
+	binary.LittleEndian.PutUint16(b[off+12:], uint16(named))
+	binary.LittleEndian.PutUint16(b[off+14:], uint16(ids))
+}
+
+func (b rsrcBuilder) entry(dirOff, index int, name, offsetToData uint32) {
+	off := dirOff + 16 + index*8
+	binary.LittleEndian.PutUint32(b[off:], name)
+	binary.LittleEndian.PutUint32(b[off+4:], offsetToData)
+}
+
+func (b rsrcBuilder) data(off int, rva, size, codepage uint32) {
+	binary.LittleEndian.PutUint32(b[off:], rva)
+	binary.LittleEndian.PutUint32(b[off+4:], size)
+	binary.LittleEndian.PutUint32(b[off+8:], codepage)
+}
+
+func (b rsrcBuilder) str(off int, s string) {
+	binary.LittleEndian.PutUint16(b[off:], uint16(len(s)))
+	for i, c := range s {
+		binary.LittleEndian.PutUint16(b[off+2+i*2:], uint16(c))
+	}
+}
+
+// buildResources lays out:
+//
+//	CONFIG/MAIN/#0      (named type and name) -> "cfg=1"
+//	RT_MANIFEST/#1/#1033                       -> "<assembly/>"
+func buildResources() rsrcBuilder {
+	const dirBit = IMAGE_RESOURCE_DATA_IS_DIRECTORY
+	const strBit = IMAGE_RESOURCE_NAME_IS_STRING
+	b := make(rsrcBuilder, 0x200)
+	b.dir(0x00, 1, 1) // root: CONFIG, 24
+	b.entry(0x00, 0, strBit|0x140, dirBit|0x20)
+	b.entry(0x00, 1, RT_MANIFEST, dirBit|0x38)
+	b.dir(0x20, 1, 0) // CONFIG: MAIN
+	b.entry(0x20, 0, strBit|0x150, dirBit|0x50)
+	b.dir(0x38, 0, 1) // RT_MANIFEST: #1
+	b.entry(0x38, 0, 1, dirBit|0x68)
+	b.dir(0x50, 0, 1) // MAIN: lang 0
+	b.entry(0x50, 0, 0, 0x100)
+	b.dir(0x68, 0, 1) // #1: lang 1033
+	b.entry(0x68, 0, 1033, 0x110)
+	b.data(0x100, 0x2000+0x180, 5, 0)
+	b.data(0x110, 0x2000+0x190, 11, 1252)
+	b.str(0x140, "CONFIG")
+	b.str(0x150, "MAIN")
+	copy(b[0x180:], "cfg=1")
+	copy(b[0x190:], "<assembly/>")
+	return b
+}
+
+func TestResources(t *testing.T) {
+	f := resourceImage(t, buildResources())
+	resources, err := f.Resources()
+	if err != nil {
+		t.Fatalf("Resources: %v", err)
+	}
+	want := []struct {
+		path     string
+		size     uint32
+		codepage uint32
+		offset   uint32
+	}{
+		{"CONFIG/MAIN/#0", 5, 0, 0x600 + 0x180},
+		{"RT_MANIFEST/#1/#1033", 11, 1252, 0x600 + 0x190},
+	}
+	if len(resources) != len(want) {
+		t.Fatalf("got %d resources, want %d", len(resources), len(want))
+	}
+	for i, w := range want {
+		r := resources[i]
+		if r.PathString() != w.path || r.Size != w.size || r.CodePage != w.codepage || !r.HasOffset || r.Offset != w.offset {
+			t.Errorf("resource %d = %s size %d cp %d offset 0x%X, want %+v", i, r.PathString(), r.Size, r.CodePage, r.Offset, w)
+		}
+	}
+	if got := resources[1].Type(); got.ID != RT_MANIFEST || got.Name != "" {
+		t.Errorf("Type = %+v, want RT_MANIFEST", got)
+	}
+
+	for path, content := range map[string]string{
+		"RT_MANIFEST/1": "<assembly/>",
+		"24/#1/1033":    "<assembly/>",
+		"config/main":   "cfg=1",
+		"CONFIG/MAIN/0": "cfg=1",
+	} {
+		r, err := f.FindResource(path)
+		if err != nil {
+			t.Errorf("FindResource(%q): %v", path, err)
+			continue
+		}
+		data, err := f.ResourceData(*r)
+		if err != nil || !bytes.Equal(data, []byte(content)) {
+			t.Errorf("ResourceData(%q) = %q, %v; want %q", path, data, err, content)
+		}
+	}
+	if _, err := f.FindResource("RT_MANIFEST/2"); !errors.Is(err, ErrResourceNotFound) {
+		t.Errorf("FindResource(missing) error = %v, want ErrResourceNotFound", err)
+	}
+}
+
+func TestResourcesLoop(t *testing.T) {
+	b := buildResources()
+	// Point RT_MANIFEST/#1 back at the root directory.
+	b.entry(0x38, 0, 1, IMAGE_RESOURCE_DATA_IS_DIRECTORY|0x00)
+	f := resourceImage(t, b)
+	if _, err := f.Resources(); !errors.Is(err, ErrResourceLoop) {
+		t.Errorf("Resources error = %v, want ErrResourceLoop", err)
+	}
+}
+
+func TestResourcesSharedDirectory(t *testing.T) {
+	// RT_HTML and RT_MANIFEST share one name directory: not a loop.
+	const dirBit = IMAGE_RESOURCE_DATA_IS_DIRECTORY
+	b := make(rsrcBuilder, 0x100)
+	b.dir(0x00, 0, 2)
+	b.entry(0x00, 0, RT_HTML, dirBit|0x20)
+	b.entry(0x00, 1, RT_MANIFEST, dirBit|0x20)
+	b.dir(0x20, 0, 1) // #1
+	b.entry(0x20, 0, 1, dirBit|0x38)
+	b.dir(0x38, 0, 1) // lang 1033
+	b.entry(0x38, 0, 1033, 0x60)
+	b.data(0x60, 0x2000+0x80, 11, 0)
+	copy(b[0x80:], "<assembly/>")
+
+	resources, err := resourceImage(t, b).Resources()
+	if err != nil {
+		t.Fatalf("Resources: %v", err)
+	}
+	var paths []string
+	for _, r := range resources {
+		paths = append(paths, r.PathString())
+	}
+	want := []string{"RT_HTML/#1/#1033", "RT_MANIFEST/#1/#1033"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths = %q, want %q", paths, want)
+	}
+}
+
+func TestResourcesTooManyEntries(t *testing.T) {
+	// Three levels of 64 entries, each level all pointing at the next
+	// directory, fan out to 64^3 resources.
+	const dirBit = IMAGE_RESOURCE_DATA_IS_DIRECTORY
+	const n, size = 64, 16 + 64*8
+	b := make(rsrcBuilder, 3*size+0x20)
+	for level := 0; level < 3; level++ {
+		off := level * size
+		b.dir(off, 0, n)
+		for i := 0; i < n; i++ {
+			target := uint32(dirBit | (off + size))
+			if level == 2 {
+				target = uint32(3 * size)
+			}
+			b.entry(off, i, uint32(i), target)
+		}
+	}
+	b.data(3*size, 0x2000, 1, 0)
+	if _, err := resourceImage(t, b).Resources(); !errors.Is(err, ErrTooManyResources) {
+		t.Errorf("Resources error = %v, want ErrTooManyResources", err)
+	}
+}
@@ -0,0 +1,161 @@
+package pe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+// Rich header markers. "Rich" is stored in clear text; "DanS" only appears
+// once the block has been XORed with the key that follows "Rich".
+const (
+	richSignature = 0x68636952 // "Rich"
+	dansSignature = 0x536E6144 // "DanS"
+)
+
+// RichHeader is the undocumented block the Microsoft linker writes between
+// the DOS stub and the NT headers. Each entry records one tool (compiler,
+// assembler, linker, ...) that produced objects for the image, so it
+// identifies the toolchain even when everything else has been stripped.
+// Other linkers (MinGW, LLVM lld in its default mode) don't emit one.
+type RichHeader struct {
+	Offset           uint32   // File offset of the (encoded) "DanS" marker
+	Size             uint32   // Bytes from "DanS" through the key after "Rich"
+	Key              uint32   // XOR key, which doubles as the stored checksum
+	Entries          []CompID // In the order the linker wrote them
+	ComputedChecksum uint32   // Checksum recomputed from the DOS header and entries
+}
+
+// ChecksumValid reports whether the stored key matches the recomputed
+// checksum. A mismatch usually means the header was edited or transplanted
+// from another binary.
+func (r *RichHeader) ChecksumValid() bool {
+	return r.Key == r.ComputedChecksum
+}
+
+// CompID is one decoded Rich header entry: a tool identified by product ID
+// and build number, and how many objects it contributed.
+type CompID struct {
+	ProductID uint16
+	Build     uint16
+	Count     uint32
+}
+
+// Value returns the packed comp.id as stored: ProductID<<16 | Build.
+func (c CompID) Value() uint32 {
+	return uint32(c.ProductID)<<16 | uint32(c.Build)
+}
+
+func (c CompID) String() string {
+	return fmt.Sprintf("ProdID 0x%04X  Build %5d  Count %4d  (%s)", c.ProductID, c.Build, c.Count, c.VisualStudioVersion())
+}
+
+// VisualStudioVersion maps the entry to the Visual Studio release that
+// shipped the tool. Product IDs are allocated in blocks per toolset; from
+// VS2015 on every release reuses the 14.x IDs, so the build number is used
+// to tell them apart.
+func (c CompID) VisualStudioVersion() string {
+	switch id := c.ProductID; {
+	case id == 0x0000:
+		return "Unmarked object"
+	case id == 0x0001:
+		return "Imports (Import0)"
+	case id < 0x0019:
+		return "VS97/VS6 (5.x-6.0)"
+	case id < 0x0046:
+		return "VS2002 (7.0)"
+	case id < 0x006D:
+		return "VS2003 (7.10)"
+	case id < 0x0083:
+		return "VS2005 (8.0)"
+	case id < 0x0098:
+		return "VS2008 (9.0)"
+	case id < 0x00B1:
+		return "VS2010 (10.0)"
+	case id < 0x00C7:
+		return "VS2012 (11.0)"
+	case id < 0x00FD:
+		return "VS2013 (12.0)"
+	case id < 0x0110:
+		switch {
+		case c.Build < 25017:
+			return "VS2015 (14.0)"
+		case c.Build < 27508:
+			return "VS2017 (14.1x)"
+		case c.Build < 30705:
+			return "VS2019 (14.2x)"
+		default:
+			return "VS2022 (14.3x)"
+		}
+	default:
+		return "Unknown"
+	}
+}
+
+// RichHeader locates and decodes the Rich header. It returns nil, nil when
+// the image has none.
+//
+// Layout, once XOR-decoded with the key stored after "Rich":
+//
+//	"DanS" 0 0 0 | comp.id count | comp.id count | ... | "Rich" key
+//
+// where "Rich" and the key itself are stored in clear text.
+func (f *File) RichHeader() (*RichHeader, error) {
+	end := int(f.DosHeader.Lfanew)
+	if end > len(f.data) {
+		end = len(f.data)
+	}
+	stub := f.data[:end]
+
+	richOffset := bytes.LastIndex(stub, []byte("Rich"))
+	if richOffset == -1 || richOffset+8 > len(stub) {
+		return nil, nil
+	}
+	key := binary.LittleEndian.Uint32(stub[richOffset+4:])
+
+	// Walk back one dword at a time until the key turns a word into "DanS".
+	start := -1
+	for off := richOffset - 4; off >= 0; off -= 4 {
+		if binary.LittleEndian.Uint32(stub[off:])^key == dansSignature {
+			start = off
+			break
+		}
+	}
+	if start == -1 {
+		return nil, &FormatError{Op: "Rich header", Offset: int64(richOffset), Err: ErrInvalidRichHeader}
+	}
+
+	// "DanS" is followed by three padding dwords that decode to zero.
+	entriesStart := start + 16
+	if entriesStart > richOffset || (richOffset-entriesStart)%8 != 0 {
+		return nil, &FormatError{Op: "Rich header", Offset: int64(start), Err: ErrInvalidRichHeader}
+	}
+
+	r := &RichHeader{Offset: uint32(start), Size: uint32(richOffset + 8 - start), Key: key}
+	for off := entriesStart; off < richOffset; off += 8 {
+		value := binary.LittleEndian.Uint32(stub[off:]) ^ key
+		count := binary.LittleEndian.Uint32(stub[off+4:]) ^ key
+		r.Entries = append(r.Entries, CompID{ProductID: uint16(value >> 16), Build: uint16(value), Count: count})
+	}
+	r.ComputedChecksum = richChecksum(f.data[:start], r.Entries)
+	return r, nil
+}
+
+// richChecksum reproduces the linker's checksum: the offset of "DanS", plus
+// every byte before it rotated left by its own offset (skipping e_lfanew,
+// which isn't known yet when the linker computes it), plus each comp.id
+// rotated left by its count.
+func richChecksum(dos []byte, entries []CompID) uint32 {
+	sum := uint32(len(dos))
+	for i, b := range dos {
+		if i >= 0x3C && i < 0x40 {
+			continue
+		}
+		sum += bits.RotateLeft32(uint32(b), i%32)
+	}
+	for _, e := range entries {
+		sum += bits.RotateLeft32(e.Value(), int(e.Count%32))
+	}
+	return sum
+}
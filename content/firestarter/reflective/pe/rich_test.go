@@ -0,0 +1,125 @@
+package pe
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"testing"
+)
+
+// withRichHeader overwrites calc_dll.dll's DOS stub with a Rich header built
+// the way the MSVC linker does it, and returns the image and the key.
+func withRichHeader(t *testing.T, entries []CompID) ([]byte, uint32) {
+	t.Helper()
+	data := loadCalcDLL(t)
+	const start = 0x40
+
+	key := uint32(start)
+	for i, b := range data[:start] {
+		if i < 0x3C || i >= 0x40 {
+			key += bits.RotateLeft32(uint32(b), i)
+		}
+	}
+	for _, e := range entries {
+		key += bits.RotateLeft32(e.Value(), int(e.Count%32))
+	}
+
+	words := []uint32{dansSignature, 0, 0, 0}
+	for _, e := range entries {
+		words = append(words, e.Value(), e.Count)
+	}
+	off := start
+	for _, w := range words {
+		binary.LittleEndian.PutUint32(data[off:], w^key)
+		off += 4
+	}
+	copy(data[off:], "Rich")
+	binary.LittleEndian.PutUint32(data[off+4:], key)
+	return data, key
+}
+
+func TestRichHeader(t *testing.T) {
+	entries := []CompID{
+		{ProductID: 0x0105, Build: 30795, Count: 12}, // C++ compiler, VS2022
+		{ProductID: 0x0102, Build: 30795, Count: 1},  // Linker, VS2022
+		{ProductID: 0x0001, Build: 0, Count: 97},     // Import0
+	}
+	data, key := withRichHeader(t, entries)
+
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	r, err := f.RichHeader()
+	if err != nil || r == nil {
+		t.Fatalf("RichHeader = %v, %v", r, err)
+	}
+	if r.Offset != 0x40 || r.Key != key || r.Size != 16+3*8+8 {
+		t.Errorf("Offset/Key/Size = 0x%X/0x%X/%d", r.Offset, r.Key, r.Size)
+	}
+	if !r.ChecksumValid() {
+		t.Errorf("checksum 0x%X, key 0x%X: want valid", r.ComputedChecksum, r.Key)
+	}
+	if len(r.Entries) != len(entries) {
+		t.Fatalf("Entries = %v, want %v", r.Entries, entries)
+	}
+	for i := range entries {
+		if r.Entries[i] != entries[i] {
+			t.Errorf("Entries[%d] = %+v, want %+v", i, r.Entries[i], entries[i])
+		}
+	}
+	if got := r.Entries[0].VisualStudioVersion(); got != "VS2022 (14.3x)" {
+		t.Errorf("VisualStudioVersion = %q, want VS2022 (14.3x)", got)
+	}
+
+	// Bumping a use count leaves the entries decodable but breaks the checksum.
+	data[0x40+16+4] ^= 1
+	f, _ = Parse(data)
+	if r, _ := f.RichHeader(); r == nil || r.ChecksumValid() {
+		t.Errorf("tampered header: want checksum mismatch, got %+v", r)
+	}
+}
+
+func TestRichHeaderAbsent(t *testing.T) {
+	// calc_dll.dll is built with MinGW, which doesn't write a Rich header.
+	f, err := Parse(loadCalcDLL(t))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if r, err := f.RichHeader(); r != nil || err != nil {
+		t.Errorf("RichHeader = %+v, %v; want nil, nil", r, err)
+	}
+}
+
+func TestRichHeaderMissingDanS(t *testing.T) {
+	data := loadCalcDLL(t)
+	copy(data[0x60:], "Rich\x11\x22\x33\x44")
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if _, err := f.RichHeader(); !errors.Is(err, ErrInvalidRichHeader) {
+		t.Errorf("RichHeader error = %v, want ErrInvalidRichHeader", err)
+	}
+}
+
+func TestVisualStudioVersion(t *testing.T) {
+	tests := []struct {
+		id   CompID
+		want string
+	}{
+		{CompID{ProductID: 0x0001}, "Imports (Import0)"},
+		{CompID{ProductID: 0x005D, Build: 3077}, "VS2003 (7.10)"},
+		{CompID{ProductID: 0x0091, Build: 30729}, "VS2008 (9.0)"},
+		{CompID{ProductID: 0x00AB, Build: 40219}, "VS2010 (10.0)"},
+		{CompID{ProductID: 0x0102, Build: 24215}, "VS2015 (14.0)"},
+		{CompID{ProductID: 0x0102, Build: 27045}, "VS2017 (14.1x)"},
+		{CompID{ProductID: 0x0102, Build: 30133}, "VS2019 (14.2x)"},
+		{CompID{ProductID: 0x0300}, "Unknown"},
+	}
+	for _, tt := range tests {
+		if got := tt.id.VisualStudioVersion(); got != tt.want {
+			t.Errorf("%+v: VisualStudioVersion = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package pe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// sectionForRVA returns the section whose virtual range contains rva, or nil.
+// A section's virtual extent is VirtualSize, falling back to SizeOfRawData
+// for linkers that leave VirtualSize zero.
+func (f *File) sectionForRVA(rva uint32) *Section {
+	for _, s := range f.Sections {
+		size := s.VirtualSize
+		if size == 0 {
+			size = s.SizeOfRawData
+		}
+		if uint64(rva) >= uint64(s.VirtualAddress) && uint64(rva) < uint64(s.VirtualAddress)+uint64(size) {
+			return s
+		}
+	}
+	return nil
+}
+
+// RVAToOffset translates a relative virtual address into a file offset by
+// finding the section that contains it. RVAs inside the headers map to the
+// same offset. An RVA that lands in a section's zero-filled tail (past
+// SizeOfRawData) or outside the image has no bytes on disk and is reported
+// as ErrInvalidRVA; use TranslateRVA to find out which case applies.
+func (f *File) RVAToOffset(rva uint32) (uint32, error) {
+	a := f.TranslateRVA(rva)
+	if !a.HasOffset {
+		return 0, &FormatError{Op: fmt.Sprintf("RVA 0x%X", rva), Offset: -1, Err: ErrInvalidRVA}
+	}
+	return a.Offset, nil
+}
+
+// bytesAtRVA returns n bytes of file data starting at rva.
+func (f *File) bytesAtRVA(rva uint32, n uint32) ([]byte, error) {
+	offset, err := f.RVAToOffset(rva)
+	if err != nil {
+		return nil, err
+	}
+	end := uint64(offset) + uint64(n)
+	if end > uint64(len(f.data)) {
+		return nil, &FormatError{Op: fmt.Sprintf("RVA 0x%X", rva), Offset: int64(offset), Err: ErrTruncated}
+	}
+	return f.data[offset:end], nil
+}
+
+// tableAtRVA returns the bytes of an array of count entries of entrySize
+// bytes at rva. The whole table must be backed by file data, which bounds
+// loops driven by counts taken from the headers.
+func (f *File) tableAtRVA(rva uint32, count, entrySize uint32) ([]byte, error) {
+	n := uint64(count) * uint64(entrySize)
+	if n > uint64(len(f.data)) {
+		return nil, &FormatError{Op: fmt.Sprintf("table at RVA 0x%X (%d entries)", rva, count), Offset: -1, Err: ErrTruncated}
+	}
+	return f.bytesAtRVA(rva, uint32(n))
+}
+
+// uint16AtRVA, uint32AtRVA and uint64AtRVA read little-endian integers at rva.
+func (f *File) uint16AtRVA(rva uint32) (uint16, error) {
+	b, err := f.bytesAtRVA(rva, 2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(b), nil
+}
+
+func (f *File) uint32AtRVA(rva uint32) (uint32, error) {
+	b, err := f.bytesAtRVA(rva, 4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+func (f *File) uint64AtRVA(rva uint32) (uint64, error) {
+	b, err := f.bytesAtRVA(rva, 8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+// structAtRVA decodes a fixed-size structure at rva into v.
+func (f *File) structAtRVA(rva uint32, v any) error {
+	b, err := f.bytesAtRVA(rva, uint32(binary.Size(v)))
+	if err != nil {
+		return err
+	}
+	return binary.Read(bytes.NewReader(b), binary.LittleEndian, v)
+}
+
+// stringAtRVA reads a null-terminated ASCII string at rva. The string may not
+// run past the end of the raw data backing the RVA.
+func (f *File) stringAtRVA(rva uint32) (string, error) {
+	offset, err := f.RVAToOffset(rva)
+	if err != nil {
+		return "", err
+	}
+	limit := uint64(len(f.data))
+	if s := f.sectionForRVA(rva); s != nil {
+		if end := uint64(s.PointerToRawData) + uint64(s.SizeOfRawData); end < limit {
+			limit = end
+		}
+	}
+	n := -1
+	if uint64(offset) < limit {
+		n = bytes.IndexByte(f.data[offset:limit], 0)
+	}
+	if n == -1 {
+		return "", &FormatError{Op: fmt.Sprintf("string at RVA 0x%X", rva), Offset: int64(offset), Err: ErrTruncated}
+	}
+	return string(f.data[offset : uint64(offset)+uint64(n)]), nil
+}
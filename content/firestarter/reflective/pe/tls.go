@@ -0,0 +1,117 @@
+package pe
+
+import "fmt"
+
+type IMAGE_TLS_DIRECTORY32 struct { //nolint:revive // Windows struct
+	StartAddressOfRawData uint32 // VA of the TLS template data
+	EndAddressOfRawData   uint32 // VA one past the end of the template data
+	AddressOfIndex        uint32 // VA of the DWORD that receives the TLS slot index
+	AddressOfCallBacks    uint32 // VA of a null-terminated array of callback VAs
+	SizeOfZeroFill        uint32 // Zero bytes appended after the template in each thread's copy
+	Characteristics       uint32 // IMAGE_SCN_ALIGN_* of the TLS block
+}
+
+type IMAGE_TLS_DIRECTORY64 struct { //nolint:revive // Windows struct
+	StartAddressOfRawData uint64
+	EndAddressOfRawData   uint64
+	AddressOfIndex        uint64
+	AddressOfCallBacks    uint64
+	SizeOfZeroFill        uint32
+	Characteristics       uint32
+}
+
+// maxTLSCallbacks bounds the walk of the callback array, which has no count
+// and ends at the first null entry.
+const maxTLSCallbacks = 1024
+
+// TLSDirectory is the TLS directory widened to 64-bit addresses so PE32 and
+// PE32+ images look the same. Every address in it is a VA at the preferred
+// ImageBase, not an RVA: the loader relocates them like any other pointer.
+type TLSDirectory struct {
+	StartAddressOfRawData uint64
+	EndAddressOfRawData   uint64
+	AddressOfIndex        uint64
+	AddressOfCallBacks    uint64
+	SizeOfZeroFill        uint32
+	Characteristics       uint32
+	Callbacks             []TLSCallback
+}
+
+// TLSCallback is one PIMAGE_TLS_CALLBACK, resolved back to the image.
+type TLSCallback struct {
+	VA      uint64
+	Address Address // Location is LocationOutside if VA is not in the image
+}
+
+// RawDataSize is the size of the TLS template copied into each thread's
+// TLS block, before SizeOfZeroFill bytes of zeros are appended.
+func (t *TLSDirectory) RawDataSize() uint64 {
+	if t.EndAddressOfRawData < t.StartAddressOfRawData {
+		return 0
+	}
+	return t.EndAddressOfRawData - t.StartAddressOfRawData
+}
+
+// TLSDirectory decodes the TLS directory and its callback array. Returns
+// nil, nil if the image has no TLS directory.
+func (f *File) TLSDirectory() (*TLSDirectory, error) {
+	dir := f.DataDirectory(IMAGE_DIRECTORY_ENTRY_TLS)
+	if dir.VirtualAddress == 0 {
+		return nil, nil
+	}
+
+	t := &TLSDirectory{}
+	if f.Is64() {
+		var d IMAGE_TLS_DIRECTORY64
+		if err := f.structAtRVA(dir.VirtualAddress, &d); err != nil {
+			return nil, err
+		}
+		t.StartAddressOfRawData, t.EndAddressOfRawData = d.StartAddressOfRawData, d.EndAddressOfRawData
+		t.AddressOfIndex, t.AddressOfCallBacks = d.AddressOfIndex, d.AddressOfCallBacks
+		t.SizeOfZeroFill, t.Characteristics = d.SizeOfZeroFill, d.Characteristics
+	} else {
+		var d IMAGE_TLS_DIRECTORY32
+		if err := f.structAtRVA(dir.VirtualAddress, &d); err != nil {
+			return nil, err
+		}
+		t.StartAddressOfRawData, t.EndAddressOfRawData = uint64(d.StartAddressOfRawData), uint64(d.EndAddressOfRawData)
+		t.AddressOfIndex, t.AddressOfCallBacks = uint64(d.AddressOfIndex), uint64(d.AddressOfCallBacks)
+		t.SizeOfZeroFill, t.Characteristics = d.SizeOfZeroFill, d.Characteristics
+	}
+
+	if t.AddressOfCallBacks == 0 {
+		return t, nil
+	}
+	array := f.TranslateVA(t.AddressOfCallBacks)
+	if array.Location == LocationOutside {
+		return t, &FormatError{Op: fmt.Sprintf("TLS callback array VA 0x%X", t.AddressOfCallBacks), Offset: -1, Err: ErrInvalidRVA}
+	}
+	for i := uint32(0); i < maxTLSCallbacks; i++ {
+		va, err := f.pointerAtRVA(array.RVA + i*f.pointerSize())
+		if err != nil {
+			return t, err
+		}
+		if va == 0 {
+			return t, nil
+		}
+		t.Callbacks = append(t.Callbacks, TLSCallback{VA: va, Address: f.TranslateVA(va)})
+	}
+	return t, &FormatError{Op: "TLS callback array", Offset: -1, Err: fmt.Errorf("%w: no terminator within %d entries", ErrTruncated, maxTLSCallbacks)}
+}
+
+// pointerSize is the size of a VA in the image: 8 for PE32+, 4 for PE32.
+func (f *File) pointerSize() uint32 {
+	if f.Is64() {
+		return 8
+	}
+	return 4
+}
+
+// pointerAtRVA reads a pointer-sized VA at rva.
+func (f *File) pointerAtRVA(rva uint32) (uint64, error) {
+	if f.Is64() {
+		return f.uint64AtRVA(rva)
+	}
+	v, err := f.uint32AtRVA(rva)
+	return uint64(v), err
+}
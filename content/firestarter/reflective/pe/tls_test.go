@@ -0,0 +1,111 @@
+package pe
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestTLSDirectorySignedEXE(t *testing.T) {
+	f, err := Parse(loadSignedEXE(t))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	tls, err := f.TLSDirectory()
+	if err != nil {
+		t.Fatalf("TLSDirectory: %v", err)
+	}
+	if tls == nil {
+		t.Fatal("TLSDirectory = nil")
+	}
+	if tls.StartAddressOfRawData != 0x406000 || tls.RawDataSize() != 4 || tls.AddressOfIndex != 0x405060 ||
+		tls.AddressOfCallBacks != 0x403314 || tls.SizeOfZeroFill != 0 {
+		t.Errorf("TLS directory = %+v", tls)
+	}
+	want := []uint32{0x1510, 0x1590}
+	if len(tls.Callbacks) != len(want) {
+		t.Fatalf("got %d callbacks, want %d", len(tls.Callbacks), len(want))
+	}
+	for i, cb := range tls.Callbacks {
+		if cb.Address.RVA != want[i] || cb.Address.Section == nil || cb.Address.Section.Name != ".text" {
+			t.Errorf("callback %d = %s, want RVA 0x%X in .text", i, cb.Address, want[i])
+		}
+	}
+}
+
+func TestTLSDirectoryCalcDLL(t *testing.T) {
+	// MinGW always links a TLS directory with its own two callbacks.
+	f, err := Parse(loadCalcDLL(t))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	tls, err := f.TLSDirectory()
+	if err != nil {
+		t.Fatalf("TLSDirectory: %v", err)
+	}
+	if tls == nil || len(tls.Callbacks) != 2 {
+		t.Fatalf("TLSDirectory = %+v, want 2 callbacks", tls)
+	}
+	for i, cb := range tls.Callbacks {
+		if cb.Address.Section == nil || cb.Address.Section.Name != ".text" {
+			t.Errorf("callback %d = %s, want .text", i, cb.Address)
+		}
+	}
+}
+
+// tlsImage builds a PE32+ image whose .rdata (RVA 0x2000) holds an
+// IMAGE_TLS_DIRECTORY64 at 0 and the callback array at 0x40.
+func tlsImage(t *testing.T, callbacks ...uint64) *File {
+	t.Helper()
+	const base = 0x180000000
+	rdata := make([]byte, 0x200)
+	copy(rdata, encode(t, IMAGE_TLS_DIRECTORY64{
+		StartAddressOfRawData: base + 0x3000, EndAddressOfRawData: base + 0x3010,
+		AddressOfIndex: base + 0x3100, AddressOfCallBacks: base + 0x2040, SizeOfZeroFill: 0x20,
+	}))
+	for i, cb := range callbacks {
+		binary.LittleEndian.PutUint64(rdata[0x40+i*8:], cb)
+	}
+	return testImage{
+		is64: true,
+		sections: withText(
+			newSection(".rdata", 0x2000, 0x200, 0x600, 0x200, 0x40000040),
+			newSection(".tls", 0x3000, 0x200, 0x800, 0x200, 0xC0000040),
+		),
+		directories: map[int]IMAGE_DATA_DIRECTORY{IMAGE_DIRECTORY_ENTRY_TLS: {VirtualAddress: 0x2000, Size: 0x28}},
+		sectionData: map[int][]byte{1: rdata},
+	}.parse(t)
+}
+
+func TestTLSDirectory64(t *testing.T) {
+	f := tlsImage(t, 0x180001000, 0x180001080, 0x7FF700001000)
+	tls, err := f.TLSDirectory()
+	if err != nil {
+		t.Fatalf("TLSDirectory: %v", err)
+	}
+	if tls.RawDataSize() != 0x10 || tls.SizeOfZeroFill != 0x20 || tls.AddressOfIndex != 0x180003100 {
+		t.Errorf("TLS directory = %+v", tls)
+	}
+	if len(tls.Callbacks) != 3 {
+		t.Fatalf("got %d callbacks, want 3", len(tls.Callbacks))
+	}
+	if a := tls.Callbacks[1].Address; a.RVA != 0x1080 || a.Location != LocationSection || a.Section.Name != ".text" {
+		t.Errorf("callback 1 = %s", a)
+	}
+	if a := tls.Callbacks[2].Address; a.Location != LocationOutside {
+		t.Errorf("callback outside the image resolved to %s", a)
+	}
+}
+
+func TestTLSDirectoryMalformed(t *testing.T) {
+	// 0x1C0 bytes of the 0x200 .rdata hold the array: 56 callbacks with
+	// no terminator, so the walk runs off the end of the section.
+	callbacks := make([]uint64, 56)
+	for i := range callbacks {
+		callbacks[i] = 0x180001000
+	}
+	f := tlsImage(t, callbacks...)
+	if _, err := f.TLSDirectory(); !errors.Is(err, ErrInvalidRVA) {
+		t.Errorf("unterminated array: err = %v, want ErrInvalidRVA", err)
+	}
+}
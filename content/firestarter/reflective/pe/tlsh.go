@@ -0,0 +1,216 @@
+package pe
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// TLSH (Trend Micro Locality Sensitive Hash) is a fuzzy hash: similar inputs
+// get digests a small distance apart, so rebuilt or lightly patched variants
+// of a file can be clustered where MD5 and SHA-256 change completely. This
+// is the standard 128-bucket variant with a 1-byte checksum, printed with
+// the "T1" version prefix, as produced by the reference tlsh tool.
+const (
+	tlshWindow        = 5
+	tlshBuckets       = 128
+	tlshCodeSize      = tlshBuckets / 4 // Two bits per bucket
+	tlshMinDataLength = 50
+)
+
+// tlshPearson is the Pearson hashing permutation TLSH uses to map byte
+// triplets to buckets.
+var tlshPearson = [256]byte{
+	1, 87, 49, 12, 176, 178, 102, 166, 121, 193, 6, 84, 249, 230, 44, 163,
+	14, 197, 213, 181, 161, 85, 218, 80, 64, 239, 24, 226, 236, 142, 38, 200,
+	110, 177, 104, 103, 141, 253, 255, 50, 77, 101, 81, 18, 45, 96, 31, 222,
+	25, 107, 190, 70, 86, 237, 240, 34, 72, 242, 20, 214, 244, 227, 149, 235,
+	97, 234, 57, 22, 60, 250, 82, 175, 208, 5, 127, 199, 111, 62, 135, 248,
+	174, 169, 211, 58, 66, 154, 106, 195, 245, 171, 17, 187, 182, 179, 0, 243,
+	132, 56, 148, 75, 128, 133, 158, 100, 130, 126, 91, 13, 153, 246, 216, 219,
+	119, 68, 223, 78, 83, 88, 201, 99, 122, 11, 92, 32, 136, 114, 52, 10,
+	138, 30, 48, 183, 156, 35, 61, 26, 143, 74, 251, 94, 129, 162, 63, 152,
+	170, 7, 115, 167, 241, 206, 3, 150, 55, 59, 151, 220, 90, 53, 23, 131,
+	125, 173, 15, 238, 79, 95, 89, 16, 105, 137, 225, 224, 217, 160, 37, 123,
+	118, 73, 2, 157, 46, 116, 9, 145, 134, 228, 207, 212, 202, 215, 69, 229,
+	27, 188, 67, 124, 168, 252, 42, 4, 29, 108, 21, 247, 19, 205, 39, 203,
+	233, 40, 186, 147, 198, 192, 155, 33, 164, 191, 98, 204, 165, 180, 117, 76,
+	140, 36, 210, 172, 41, 54, 159, 8, 185, 232, 113, 196, 231, 47, 146, 120,
+	51, 65, 28, 144, 254, 221, 93, 189, 194, 139, 112, 43, 71, 109, 184, 209,
+}
+
+// tlshMapping hashes a salt and three bytes into a bucket index.
+func tlshMapping(salt, i, j, k byte) byte {
+	h := tlshPearson[salt]
+	h = tlshPearson[h^i]
+	h = tlshPearson[h^j]
+	return tlshPearson[h^k]
+}
+
+// TLSHDigest is a decoded TLSH digest.
+type TLSHDigest struct {
+	Checksum byte
+	LValue   byte // Log-scaled input length
+	Q1Ratio  byte // Quartile ratios, 4 bits each
+	Q2Ratio  byte
+	Code     [tlshCodeSize]byte // Bucket quartiles, bucket 0 in the low bits of Code[0]
+}
+
+// TLSH computes the digest of data. Inputs shorter than 50 bytes, or
+// without enough variety to fill more than half the buckets (such as a
+// section of zero padding), have no digest and return ErrTLSHInput.
+func TLSH(data []byte) (*TLSHDigest, error) {
+	if len(data) < tlshMinDataLength {
+		return nil, fmt.Errorf("%w: %d bytes, need at least %d", ErrTLSHInput, len(data), tlshMinDataLength)
+	}
+
+	// Every position past the first four contributes six triplets from the
+	// 5-byte window ending there.
+	var buckets [256]uint32
+	var checksum byte
+	for n := tlshWindow - 1; n < len(data); n++ {
+		b0, b1, b2, b3, b4 := data[n], data[n-1], data[n-2], data[n-3], data[n-4]
+		checksum = tlshMapping(0, b0, b1, checksum)
+		buckets[tlshMapping(2, b0, b1, b2)]++
+		buckets[tlshMapping(3, b0, b1, b3)]++
+		buckets[tlshMapping(5, b0, b2, b3)]++
+		buckets[tlshMapping(7, b0, b2, b4)]++
+		buckets[tlshMapping(11, b0, b1, b4)]++
+		buckets[tlshMapping(13, b0, b3, b4)]++
+	}
+
+	// Only the first 128 buckets are used; each is coded by which quartile
+	// of the counts it falls in.
+	sorted := make([]uint32, tlshBuckets)
+	copy(sorted, buckets[:tlshBuckets])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	q1, q2, q3 := sorted[tlshBuckets/4-1], sorted[tlshBuckets/2-1], sorted[tlshBuckets-tlshBuckets/4-1]
+	nonZero := 0
+	for _, c := range buckets[:tlshBuckets] {
+		if c > 0 {
+			nonZero++
+		}
+	}
+	if q3 == 0 || nonZero <= tlshBuckets/2 {
+		return nil, fmt.Errorf("%w: only %d of %d buckets used", ErrTLSHInput, nonZero, tlshBuckets)
+	}
+
+	d := &TLSHDigest{Checksum: checksum, LValue: tlshLength(len(data)),
+		Q1Ratio: byte(uint64(q1) * 100 / uint64(q3) % 16), Q2Ratio: byte(uint64(q2) * 100 / uint64(q3) % 16)}
+	for i := range d.Code {
+		var h byte
+		for j := 0; j < 4; j++ {
+			switch c := buckets[4*i+j]; {
+			case c > q3:
+				h |= 3 << (j * 2)
+			case c > q2:
+				h |= 2 << (j * 2)
+			case c > q1:
+				h |= 1 << (j * 2)
+			}
+		}
+		d.Code[i] = h
+	}
+	return d, nil
+}
+
+// tlshLength maps the input length onto a byte with a log scale whose step
+// widens for larger inputs.
+func tlshLength(n int) byte {
+	l := math.Log(float64(n))
+	var i int
+	switch {
+	case n <= 656:
+		i = int(math.Floor(l / 0.4054651))
+	case n <= 3199:
+		i = int(math.Floor(l/0.26236426 - 8.72777))
+	default:
+		i = int(math.Floor(l/0.0953102 - 62.5472))
+	}
+	return byte(i & 0xFF)
+}
+
+// swapNibbles is how the reference implementation writes the header bytes.
+func swapNibbles(b byte) byte {
+	return b<<4 | b>>4
+}
+
+// String returns the digest in the usual 72-character form: "T1", the
+// header bytes with their nibbles swapped, then the code from the last
+// byte to the first.
+func (d *TLSHDigest) String() string {
+	raw := make([]byte, 0, 3+tlshCodeSize)
+	raw = append(raw, swapNibbles(d.Checksum), swapNibbles(d.LValue), d.Q1Ratio<<4|d.Q2Ratio)
+	for i := tlshCodeSize - 1; i >= 0; i-- {
+		raw = append(raw, d.Code[i])
+	}
+	return "T1" + strings.ToUpper(hex.EncodeToString(raw))
+}
+
+// ParseTLSH decodes a digest produced by String or the reference tool. The
+// "T1" prefix is optional, as older tools omit it.
+func ParseTLSH(s string) (*TLSHDigest, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "T1"), "t1")
+	raw, err := hex.DecodeString(s)
+	if err != nil || len(raw) != 3+tlshCodeSize {
+		return nil, fmt.Errorf("%w: malformed digest %q", ErrTLSHInput, s)
+	}
+	d := &TLSHDigest{Checksum: swapNibbles(raw[0]), LValue: swapNibbles(raw[1]), Q1Ratio: raw[2] >> 4, Q2Ratio: raw[2] & 0xF}
+	for i := range d.Code {
+		d.Code[i] = raw[3+tlshCodeSize-1-i]
+	}
+	return d, nil
+}
+
+// tlshModDiff is the distance between x and y on a ring of size r.
+func tlshModDiff(x, y, r int) int {
+	d := x - y
+	if d < 0 {
+		d = -d
+	}
+	if r-d < d {
+		return r - d
+	}
+	return d
+}
+
+// Distance scores how different two digests are, counting the length
+// difference. 0 means (nearly) identical input; rebuilds of the same source
+// typically score below 50, and unrelated files well above 100.
+func (d *TLSHDigest) Distance(o *TLSHDigest) int {
+	diff := 0
+	switch l := tlshModDiff(int(d.LValue), int(o.LValue), 256); {
+	case l <= 1:
+		diff += l
+	default:
+		diff += l * 12
+	}
+	for _, q := range [][2]byte{{d.Q1Ratio, o.Q1Ratio}, {d.Q2Ratio, o.Q2Ratio}} {
+		if qd := tlshModDiff(int(q[0]), int(q[1]), 16); qd <= 1 {
+			diff += qd
+		} else {
+			diff += (qd - 1) * 12
+		}
+	}
+	if d.Checksum != o.Checksum {
+		diff++
+	}
+	// Buckets two quartiles apart cost 2, but opposite extremes cost 6.
+	for i := range d.Code {
+		x, y := d.Code[i], o.Code[i]
+		for j := 0; j < 4; j++ {
+			a, b := int(x>>(j*2)&3), int(y>>(j*2)&3)
+			bd := a - b
+			if bd < 0 {
+				bd = -bd
+			}
+			if bd == 3 {
+				bd = 6
+			}
+			diff += bd
+		}
+	}
+	return diff
+}
@@ -0,0 +1,84 @@
+package pe
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestTLSHPearsonTable(t *testing.T) {
+	var seen [256]bool
+	for _, v := range tlshPearson {
+		if seen[v] {
+			t.Fatalf("tlshPearson repeats %d; it must be a permutation", v)
+		}
+		seen[v] = true
+	}
+}
+
+func TestTLSH(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	a := make([]byte, 8192)
+	rng.Read(a)
+
+	da, err := TLSH(a)
+	if err != nil {
+		t.Fatalf("TLSH: %v", err)
+	}
+	s := da.String()
+	if len(s) != 72 || s[:2] != "T1" {
+		t.Errorf("digest %q: want 72 characters starting with T1", s)
+	}
+	parsed, err := ParseTLSH(s)
+	if err != nil || *parsed != *da {
+		t.Errorf("ParseTLSH(%q) = %+v, %v; want %+v", s, parsed, err, da)
+	}
+	if d := da.Distance(da); d != 0 {
+		t.Errorf("distance to itself = %d, want 0", d)
+	}
+
+	// A few patched bytes stay close; unrelated data of the same size does not.
+	b := append([]byte(nil), a...)
+	for _, off := range []int{100, 2000, 5000} {
+		b[off] ^= 0xFF
+	}
+	db, err := TLSH(b)
+	if err != nil {
+		t.Fatalf("TLSH(patched): %v", err)
+	}
+	c := make([]byte, len(a))
+	rng.Read(c)
+	dc, err := TLSH(c)
+	if err != nil {
+		t.Fatalf("TLSH(unrelated): %v", err)
+	}
+	near, far := da.Distance(db), da.Distance(dc)
+	if near >= 30 || far <= 100 {
+		t.Errorf("distance to patched copy = %d, to unrelated data = %d; want < 30 and > 100", near, far)
+	}
+	if db.Distance(da) != near {
+		t.Errorf("Distance is not symmetric")
+	}
+
+	for _, data := range [][]byte{allBytes(49), make([]byte, 4096)} {
+		if _, err := TLSH(data); !errors.Is(err, ErrTLSHInput) {
+			t.Errorf("TLSH(%d bytes) error = %v, want ErrTLSHInput", len(data), err)
+		}
+	}
+	if _, err := ParseTLSH("T1ABC"); !errors.Is(err, ErrTLSHInput) {
+		t.Errorf("ParseTLSH(short) error = %v, want ErrTLSHInput", err)
+	}
+}
+
+func TestSectionTLSH(t *testing.T) {
+	f, err := Parse(loadCalcDLL(t))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if h := f.SectionHashes(f.Section(".text")); h.TLSH == "" {
+		t.Errorf(".text has no TLSH digest")
+	}
+	if _, err := TLSH(f.Bytes()); err != nil {
+		t.Errorf("TLSH(whole file): %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package pe
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MaxSections is the section count limit from the PE/COFF specification
+// ("the Windows loader limits the number of sections to 96").
+const MaxSections = 96
+
+// MaxSizeOfImage caps the SizeOfImage Parse accepts. The labs pass this
+// straight to VirtualAlloc, so a hostile 0xFFFFFFFF would otherwise reserve
+// 4 GiB before anything else gets checked.
+const MaxSizeOfImage = 0x40000000 // 1 GiB
+
+// validate checks the header fields a mapper trusts when copying the image
+// into memory: SizeOfHeaders and every section's raw range must lie inside
+// the file, and every section must fit inside SizeOfImage without
+// overlapping its neighbours.
+func (f *File) validate() error {
+	size := uint64(len(f.data))
+
+	sizeOfImage := uint64(f.SizeOfImage())
+	if sizeOfImage == 0 || sizeOfImage > MaxSizeOfImage {
+		return &FormatError{Op: fmt.Sprintf("SizeOfImage 0x%X", sizeOfImage), Offset: -1, Err: ErrSizeOfImage}
+	}
+	headers := uint64(f.SizeOfHeaders())
+	if headers > size {
+		return &FormatError{Op: fmt.Sprintf("SizeOfHeaders 0x%X", headers), Offset: -1, Err: ErrTruncated}
+	}
+	if headers > sizeOfImage {
+		return &FormatError{Op: fmt.Sprintf("SizeOfHeaders 0x%X", headers), Offset: -1, Err: ErrSizeOfImage}
+	}
+
+	for _, s := range f.Sections {
+		if s.SizeOfRawData != 0 && uint64(s.PointerToRawData)+uint64(s.SizeOfRawData) > size {
+			return &FormatError{Op: fmt.Sprintf("section '%s'", s.Name), Offset: int64(s.PointerToRawData), Err: ErrSectionPastEOF}
+		}
+		if uint64(s.VirtualAddress)+mappedSize(s) > sizeOfImage {
+			return &FormatError{Op: fmt.Sprintf("section '%s'", s.Name), Offset: -1, Err: ErrSizeOfImage}
+		}
+	}
+
+	// Sort a copy by RVA so each section only needs comparing with the next.
+	sorted := append([]*Section(nil), f.Sections...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].VirtualAddress < sorted[j].VirtualAddress })
+	for i := 1; i < len(sorted); i++ {
+		prev, s := sorted[i-1], sorted[i]
+		if uint64(prev.VirtualAddress)+mappedSize(prev) > uint64(s.VirtualAddress) {
+			return &FormatError{Op: fmt.Sprintf("section '%s' and '%s'", prev.Name, s.Name), Offset: -1, Err: ErrSectionOverlap}
+		}
+	}
+	return nil
+}
+
+// mappedSize is how many bytes a section occupies once mapped: VirtualSize,
+// or SizeOfRawData when that is larger, since the labs copy the whole raw
+// range to VirtualAddress.
+func mappedSize(s *Section) uint64 {
+	if s.SizeOfRawData > s.VirtualSize {
+		return uint64(s.SizeOfRawData)
+	}
+	return uint64(s.VirtualSize)
+}
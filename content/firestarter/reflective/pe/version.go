@@ -0,0 +1,201 @@
+package pe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+type VS_FIXEDFILEINFO struct { //nolint:revive // Windows struct
+	Signature        uint32 // VS_FFI_SIGNATURE
+	StrucVersion     uint32
+	FileVersionMS    uint32 // Major << 16 | Minor
+	FileVersionLS    uint32 // Build << 16 | Revision
+	ProductVersionMS uint32
+	ProductVersionLS uint32
+	FileFlagsMask    uint32
+	FileFlags        uint32 // VS_FF_DEBUG, VS_FF_PRERELEASE, ...
+	FileOS           uint32
+	FileType         uint32 // VFT_APP, VFT_DLL, VFT_DRV, ...
+	FileSubtype      uint32
+	FileDateMS       uint32
+	FileDateLS       uint32
+}
+
+const VS_FFI_SIGNATURE = 0xFEEF04BD //nolint:revive // Windows constant
+
+// FileVersion and ProductVersion format the binary versions as "a.b.c.d".
+func (v *VS_FIXEDFILEINFO) FileVersion() string {
+	return formatVersion(v.FileVersionMS, v.FileVersionLS)
+}
+
+func (v *VS_FIXEDFILEINFO) ProductVersion() string {
+	return formatVersion(v.ProductVersionMS, v.ProductVersionLS)
+}
+
+func formatVersion(ms, ls uint32) string {
+	return fmt.Sprintf("%d.%d.%d.%d", ms>>16, ms&0xFFFF, ls>>16, ls&0xFFFF)
+}
+
+// VersionInfo is a decoded RT_VERSION resource.
+type VersionInfo struct {
+	Fixed        *VS_FIXEDFILEINFO    // nil if the resource carries no fixed block
+	StringTables []VersionStringTable // StringFileInfo, one table per language/codepage
+}
+
+// VersionStringTable is one StringFileInfo table, such as "040904B0" (US
+// English, Unicode), with its strings in file order.
+type VersionStringTable struct {
+	Language string
+	Strings  []VersionString
+}
+
+// VersionString is one key/value pair, e.g. CompanyName.
+type VersionString struct {
+	Key   string
+	Value string
+}
+
+// String returns the value of key from the first table that has it, such as
+// "OriginalFilename", or "" if no table does.
+func (v *VersionInfo) String(key string) string {
+	for _, t := range v.StringTables {
+		for _, s := range t.Strings {
+			if s.Key == key {
+				return s.Value
+			}
+		}
+	}
+	return ""
+}
+
+// VersionInfo decodes the first RT_VERSION resource. Returns nil, nil if the
+// image has none.
+func (f *File) VersionInfo() (*VersionInfo, error) {
+	data, err := f.firstResourceOfType(RT_VERSION)
+	if data == nil || err != nil {
+		return nil, err
+	}
+	return ParseVersionInfo(data)
+}
+
+// firstResourceOfType returns the data of the first resource with a numeric
+// type, or nil if there is none.
+func (f *File) firstResourceOfType(typ uint32) ([]byte, error) {
+	resources, err := f.Resources()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range resources {
+		if t := r.Type(); t.Name == "" && t.ID == typ {
+			return f.ResourceData(r)
+		}
+	}
+	return nil, nil
+}
+
+// versionNode is one block of the VS_VERSIONINFO tree. Every block has the
+// same header, with szKey and Value each padded to a 32-bit boundary:
+//
+//	WORD wLength; WORD wValueLength; WORD wType; WCHAR szKey[]; Value; Children[]
+type versionNode struct {
+	key      string
+	value    []byte // Raw for binary nodes (wType 0), UTF-16 text without terminator for wType 1
+	text     bool
+	children []versionNode
+}
+
+// ParseVersionInfo decodes raw VS_VERSIONINFO bytes.
+func ParseVersionInfo(data []byte) (*VersionInfo, error) {
+	root, _, err := parseVersionNode(data, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if root.key != "VS_VERSION_INFO" {
+		return nil, fmt.Errorf("%w: root key %q", ErrInvalidVersionInfo, root.key)
+	}
+
+	v := new(VersionInfo)
+	if len(root.value) >= binary.Size(VS_FIXEDFILEINFO{}) {
+		fixed := new(VS_FIXEDFILEINFO)
+		binary.Read(bytes.NewReader(root.value), binary.LittleEndian, fixed)
+		if fixed.Signature != VS_FFI_SIGNATURE {
+			return nil, fmt.Errorf("%w: VS_FIXEDFILEINFO signature 0x%X", ErrInvalidVersionInfo, fixed.Signature)
+		}
+		v.Fixed = fixed
+	}
+	for _, child := range root.children {
+		if child.key != "StringFileInfo" {
+			continue // VarFileInfo only repeats the translations
+		}
+		for _, table := range child.children {
+			t := VersionStringTable{Language: table.key}
+			for _, s := range table.children {
+				t.Strings = append(t.Strings, VersionString{Key: s.key, Value: decodeUTF16(s.value)})
+			}
+			v.StringTables = append(v.StringTables, t)
+		}
+	}
+	return v, nil
+}
+
+// parseVersionNode decodes the block at offset and returns it with the
+// offset of the next sibling. depth bounds recursion on hostile input.
+func parseVersionNode(data []byte, offset, depth int) (versionNode, int, error) {
+	var n versionNode
+	if depth > 8 || offset+6 > len(data) {
+		return n, 0, fmt.Errorf("%w: block at 0x%X", ErrInvalidVersionInfo, offset)
+	}
+	length := int(binary.LittleEndian.Uint16(data[offset:]))
+	valueLength := int(binary.LittleEndian.Uint16(data[offset+2:]))
+	n.text = binary.LittleEndian.Uint16(data[offset+4:]) == 1
+	end := offset + length
+	if length < 6 || end > len(data) {
+		return n, 0, fmt.Errorf("%w: block at 0x%X has length %d", ErrInvalidVersionInfo, offset, length)
+	}
+
+	// szKey: null-terminated UTF-16.
+	pos := offset + 6
+	keyEnd := pos
+	for keyEnd+1 < end && (data[keyEnd] != 0 || data[keyEnd+1] != 0) {
+		keyEnd += 2
+	}
+	n.key = decodeUTF16(data[pos:keyEnd])
+	pos = align4(keyEnd + 2)
+
+	// Value. Text lengths are in WCHARs (some tools write bytes), so read
+	// text up to its terminator instead of trusting wValueLength.
+	if valueLength > 0 && pos < end {
+		size := valueLength
+		if n.text {
+			size *= 2
+		}
+		if pos+size > end {
+			size = end - pos
+		}
+		n.value = data[pos : pos+size]
+		if n.text {
+			for i := 0; i+1 < len(n.value); i += 2 {
+				if n.value[i] == 0 && n.value[i+1] == 0 {
+					n.value = n.value[:i]
+					break
+				}
+			}
+		}
+		pos = align4(pos + size)
+	}
+
+	for pos < end {
+		child, next, err := parseVersionNode(data, pos, depth+1)
+		if err != nil {
+			return n, 0, err
+		}
+		n.children = append(n.children, child)
+		pos = align4(next)
+	}
+	return n, end, nil
+}
+
+func align4(v int) int {
+	return (v + 3) &^ 3
+}
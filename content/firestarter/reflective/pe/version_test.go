@@ -0,0 +1,145 @@
+package pe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"unicode/utf16"
+)
+
+// versionBlock encodes one VS_VERSIONINFO block the way rc.exe does: text
+// values have wValueLength in WCHARs including the terminator, and key,
+// value and children are each padded to 32 bits.
+func versionBlock(t testing.TB, key string, text bool, value []byte, children ...[]byte) []byte {
+	var b bytes.Buffer
+	b.Write(make([]byte, 6)) // Header, patched below
+	b.Write(utf16z(t, key))
+	pad := func() { b.Write(make([]byte, align4(b.Len())-b.Len())) }
+	pad()
+	valueLength := len(value)
+	if text {
+		valueLength /= 2
+	}
+	b.Write(value)
+	for _, c := range children {
+		pad()
+		b.Write(c)
+	}
+	out := b.Bytes()
+	binary.LittleEndian.PutUint16(out[0:], uint16(len(out)))
+	binary.LittleEndian.PutUint16(out[2:], uint16(valueLength))
+	if text {
+		binary.LittleEndian.PutUint16(out[4:], 1)
+	}
+	return out
+}
+
+func utf16z(t testing.TB, s string) []byte {
+	return encode(t, utf16.Encode([]rune(s+"\x00")))
+}
+
+func buildVersionInfo(t testing.TB) []byte {
+	fixed := encode(t, VS_FIXEDFILEINFO{Signature: VS_FFI_SIGNATURE, StrucVersion: 0x10000,
+		FileVersionMS: 1<<16 | 2, FileVersionLS: 3<<16 | 4, ProductVersionMS: 5 << 16, ProductVersionLS: 0, FileType: 2})
+	str := func(k, v string) []byte { return versionBlock(t, k, true, utf16z(t, v)) }
+	return versionBlock(t, "VS_VERSION_INFO", false, fixed,
+		versionBlock(t, "StringFileInfo", true, nil,
+			versionBlock(t, "040904B0", true, nil,
+				str("CompanyName", "Lab Corp"),
+				str("OriginalFilename", "calc_dll.dll"),
+				str("InternalName", "calc"))),
+		versionBlock(t, "VarFileInfo", true, nil,
+			versionBlock(t, "Translation", false, []byte{0x09, 0x04, 0xB0, 0x04})))
+}
+
+func TestParseVersionInfo(t *testing.T) {
+	v, err := ParseVersionInfo(buildVersionInfo(t))
+	if err != nil {
+		t.Fatalf("ParseVersionInfo: %v", err)
+	}
+	if v.Fixed == nil || v.Fixed.FileVersion() != "1.2.3.4" || v.Fixed.ProductVersion() != "5.0.0.0" {
+		t.Fatalf("Fixed = %+v", v.Fixed)
+	}
+	if len(v.StringTables) != 1 || v.StringTables[0].Language != "040904B0" || len(v.StringTables[0].Strings) != 3 {
+		t.Fatalf("StringTables = %+v", v.StringTables)
+	}
+	for key, want := range map[string]string{"CompanyName": "Lab Corp", "OriginalFilename": "calc_dll.dll", "InternalName": "calc", "Missing": ""} {
+		if got := v.String(key); got != want {
+			t.Errorf("String(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestParseVersionInfoMalformed(t *testing.T) {
+	good := buildVersionInfo(t)
+	tests := map[string][]byte{
+		"empty":          nil,
+		"length too big": append([]byte{0xFF, 0xFF}, good[2:]...),
+		"truncated":      good[:len(good)/2],
+		"bad signature":  append(append([]byte(nil), good[:40]...), append([]byte{0, 0, 0, 0}, good[44:]...)...),
+	}
+	for name, data := range tests {
+		if _, err := ParseVersionInfo(data); !errors.Is(err, ErrInvalidVersionInfo) {
+			t.Errorf("%s: error = %v, want ErrInvalidVersionInfo", name, err)
+		}
+	}
+}
+
+func TestVersionInfoAndManifestResources(t *testing.T) {
+	version := buildVersionInfo(t)
+	manifest := []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\r\n" +
+		`<assembly xmlns="urn:schemas-microsoft-com:asm.v1" manifestVersion="1.0">` +
+		`<dependency><dependentAssembly><assemblyIdentity type="win32" name="Microsoft.Windows.Common-Controls"` +
+		` version="6.0.0.0" processorArchitecture="*" publicKeyToken="6595b64144ccf1df" language="*"/>` +
+		`</dependentAssembly></dependency>` +
+		`<trustInfo xmlns="urn:schemas-microsoft-com:asm.v3"><security><requestedPrivileges>` +
+		`<requestedExecutionLevel level="requireAdministrator" uiAccess="false"/>` +
+		`</requestedPrivileges></security></trustInfo></assembly>` + "\x00\x00")
+
+	const dirBit = IMAGE_RESOURCE_DATA_IS_DIRECTORY
+	b := make(rsrcBuilder, 0x1000)
+	b.dir(0x00, 0, 2)
+	b.entry(0x00, 0, RT_VERSION, dirBit|0x20)
+	b.entry(0x00, 1, RT_MANIFEST, dirBit|0x38)
+	b.dir(0x20, 0, 1)
+	b.entry(0x20, 0, 1, dirBit|0x50)
+	b.dir(0x38, 0, 1)
+	b.entry(0x38, 0, 1, dirBit|0x68)
+	b.dir(0x50, 0, 1)
+	b.entry(0x50, 0, 1033, 0x100)
+	b.dir(0x68, 0, 1)
+	b.entry(0x68, 0, 1033, 0x110)
+	b.data(0x100, 0x2000+0x200, uint32(len(version)), 0)
+	b.data(0x110, 0x2000+0x600, uint32(len(manifest)), 0)
+	copy(b[0x200:], version)
+	copy(b[0x600:], manifest)
+	f := resourceImage(t, b)
+
+	v, err := f.VersionInfo()
+	if err != nil || v == nil {
+		t.Fatalf("VersionInfo = %v, %v", v, err)
+	}
+	if got := v.String("OriginalFilename"); got != "calc_dll.dll" {
+		t.Errorf("OriginalFilename = %q", got)
+	}
+
+	m, err := f.Manifest()
+	if err != nil || m == nil {
+		t.Fatalf("Manifest = %v, %v", m, err)
+	}
+	if m.RequestedExecutionLevel != "requireAdministrator" || m.UIAccess != "false" {
+		t.Errorf("execution level = %q uiAccess %q", m.RequestedExecutionLevel, m.UIAccess)
+	}
+	want := ManifestDependency{Type: "win32", Name: "Microsoft.Windows.Common-Controls", Version: "6.0.0.0",
+		ProcessorArchitecture: "*", PublicKeyToken: "6595b64144ccf1df", Language: "*"}
+	if len(m.Dependencies) != 1 || m.Dependencies[0] != want {
+		t.Errorf("Dependencies = %+v, want %+v", m.Dependencies, want)
+	}
+}
+
+func TestParseManifestMalformed(t *testing.T) {
+	if _, err := ParseManifest([]byte("<assembly><trustInfo>")); !errors.Is(err, ErrInvalidManifest) {
+		t.Errorf("error = %v, want ErrInvalidManifest", err)
+	}
+}